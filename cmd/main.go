@@ -2,13 +2,20 @@ package main
 
 import (
 	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
 	"gin-chat-room/internal/database"
 	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/mailer"
 	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/oidc"
+	"gin-chat-room/internal/passwordhash"
+	"gin-chat-room/internal/rbac"
 	"gin-chat-room/internal/services"
 	"gin-chat-room/pkg/logger"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -21,6 +28,31 @@ func main() {
 	// 初始化日志
 	logger.InitLogger()
 
+	// 加载 JWT 签名密钥
+	if err := auth.InitKeys(); err != nil {
+		log.Fatal("Failed to load JWT signing keys:", err)
+	}
+
+	// 初始化邮件发送器
+	if err := mailer.InitMailer(); err != nil {
+		log.Fatal("Failed to initialize mailer:", err)
+	}
+
+	// 初始化密码哈希算法
+	if err := passwordhash.InitHasher(); err != nil {
+		log.Fatal("Failed to initialize password hasher:", err)
+	}
+
+	// 加载泄露密码列表
+	if err := auth.InitBreachedPasswords(); err != nil {
+		log.Fatal("Failed to load breached password list:", err)
+	}
+
+	// 注册外部登录提供方
+	if err := oidc.InitProviders(); err != nil {
+		log.Fatal("Failed to initialize login providers:", err)
+	}
+
 	// 初始化数据库
 	if err := database.InitDB(); err != nil {
 		log.Fatal("Failed to initialize database:", err)
@@ -36,12 +68,26 @@ func main() {
 	hub := services.NewHub()
 	go hub.Run()
 
+	// 定期删除注销宽限期已结束的账号
+	go services.RunAccountDeletions(hub, time.Hour)
+
+	// 定期删除无活动的访客
+	go services.RunGuestCleanup(hub, 10*time.Minute)
+
+	// 彻底删除超过保留期的房间
+	go services.RunRoomPurge(time.Hour)
+
 	// 设置 Gin 模式
 	gin.SetMode(config.AppConfig.Server.Mode)
 
 	// 创建路由
 	router := gin.Default()
 
+	// 只信任配置的反向代理，默认不信任任何代理，客户端 IP 取连接地址
+	if err := router.SetTrustedProxies(config.AppConfig.Server.TrustedProxies); err != nil {
+		log.Fatal("Invalid trusted proxies:", err)
+	}
+
 	// 配置 CORS
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
@@ -62,6 +108,9 @@ func main() {
 		})
 	})
 
+	// JWT 公钥集合，供其他服务独立验证 token
+	router.GET("/.well-known/jwks.json", handlers.GetJWKS)
+
 	// API 路由组
 	api := router.Group("/api/v1")
 	{
@@ -70,6 +119,20 @@ func main() {
 		{
 			auth.POST("/register", handlers.Register)
 			auth.POST("/login", handlers.Login)
+			auth.POST("/guest", handlers.CreateGuestSession)
+			auth.POST("/2fa/verify", handlers.VerifyTwoFactorLogin)
+			auth.POST("/refresh", handlers.RefreshToken)
+			auth.POST("/logout", middleware.AuthMiddleware(), handlers.Logout(hub))
+			auth.POST("/password/forgot", handlers.ForgotPassword)
+			auth.POST("/password/reset", handlers.ResetPassword(hub))
+			auth.GET("/email/verify", handlers.VerifyEmail)
+			auth.POST("/email/resend", middleware.AuthMiddleware(), handlers.ResendVerificationEmail)
+
+			// 外部登录（OpenID Connect）
+			auth.GET("/oidc/providers", handlers.ListOIDCProviders)
+			auth.GET("/oidc/:provider/login", handlers.OIDCLogin)
+			auth.GET("/oidc/:provider/callback", handlers.OIDCCallback)
+			auth.POST("/oidc/token", handlers.ExchangeOIDCLoginCode)
 		}
 
 		// 需要认证的路由
@@ -79,20 +142,106 @@ func main() {
 			// 用户相关
 			protected.GET("/profile", handlers.GetProfile)
 			protected.PUT("/profile", handlers.UpdateProfile)
+			protected.PUT("/profile/password", handlers.ChangePassword(hub))
+			protected.GET("/profile/export", handlers.ExportProfile)
+			protected.DELETE("/profile", handlers.DeleteAccount(hub))
+
+			// 登录会话
+			protected.GET("/sessions", handlers.GetSessions)
+			protected.DELETE("/sessions", handlers.RevokeOtherSessions(hub))
+			protected.DELETE("/sessions/:id", handlers.RevokeSession(hub))
+
+			// 两步验证
+			protected.POST("/2fa/enroll", handlers.EnrollTwoFactor)
+			protected.POST("/2fa/confirm", handlers.ConfirmTwoFactor)
+			protected.POST("/2fa/disable", handlers.DisableTwoFactor)
+			protected.POST("/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
 
 			// 聊天室相关
-			protected.GET("/rooms", handlers.GetRooms)
-			protected.POST("/rooms", handlers.CreateRoom)
-			protected.GET("/rooms/:id", handlers.GetRoom)
-			protected.POST("/rooms/:id/join", handlers.JoinRoom)
+			protected.POST("/rooms", middleware.RequirePermission(rbac.PermRoomsCreate), middleware.RequireVerifiedEmail(), handlers.CreateRoom)
+			protected.POST("/rooms/:id/join", middleware.RequirePermission(rbac.PermRoomsJoin), handlers.JoinRoom)
 			protected.POST("/rooms/:id/leave", handlers.LeaveRoom)
+			protected.PUT("/rooms/:id", handlers.UpdateRoom(hub))
+			protected.DELETE("/rooms/:id", handlers.DeleteRoom(hub))
+			protected.POST("/rooms/:id/restore", handlers.RestoreRoom(hub))
+			protected.PUT("/rooms/:id/password", handlers.UpdateRoomPassword)
+			protected.DELETE("/rooms/:id/password", handlers.RemoveRoomPassword)
+
+			// 房间邀请码
+			protected.POST("/rooms/:id/invites", handlers.CreateRoomInvite)
+			protected.GET("/rooms/:id/invites", handlers.GetRoomInvites)
+			protected.DELETE("/rooms/:id/invites/:invite_id", handlers.RevokeRoomInvite)
+			protected.GET("/invites/:code", handlers.GetInvite)
+			protected.POST("/invites/:code/accept", middleware.RequirePermission(rbac.PermRoomsJoin), handlers.AcceptInvite)
+
+			// 房间加入申请
+			protected.POST("/rooms/:id/join-requests", middleware.RequirePermission(rbac.PermRoomsJoin), handlers.CreateJoinRequest(hub))
+			protected.GET("/rooms/:id/join-requests", handlers.GetJoinRequests)
+			protected.POST("/rooms/:id/join-requests/:request_id/approve", handlers.ApproveJoinRequest(hub))
+			protected.POST("/rooms/:id/join-requests/:request_id/deny", handlers.DenyJoinRequest(hub))
+
+			// 房间成员管理
+			protected.DELETE("/rooms/:id/members/:user_id", handlers.KickMember(hub))
+			protected.POST("/rooms/:id/members/:user_id/ban", handlers.BanMember(hub))
+			protected.DELETE("/rooms/:id/members/:user_id/ban", handlers.UnbanMember)
+			protected.POST("/rooms/:id/members/:user_id/mute", handlers.MuteMember)
+			protected.DELETE("/rooms/:id/members/:user_id/mute", handlers.UnmuteMember)
+			protected.PUT("/rooms/:id/members/:user_id/role", handlers.UpdateMemberRole)
+			protected.GET("/rooms/:id/bans", handlers.GetRoomBans)
+
+			// 房间转让
+			protected.POST("/rooms/:id/transfer", handlers.TransferRoom)
+			protected.GET("/rooms/:id/transfer", handlers.GetRoomTransfer)
+			protected.DELETE("/rooms/:id/transfer", handlers.CancelRoomTransfer)
+			protected.POST("/rooms/:id/transfer/accept", handlers.AcceptRoomTransfer(hub))
+
+			// 私信，消息通过房间消息接口和 WebSocket 收发
+			protected.POST("/direct-messages", middleware.RequirePermission(rbac.PermRoomsJoin), middleware.RequireVerifiedEmail(), handlers.CreateDirectConversation)
+			protected.GET("/direct-messages", handlers.GetDirectConversations)
+
+			// WebSocket 连接票据
+			protected.POST("/ws/ticket", handlers.CreateWebSocketTicket)
+		}
+
+		// 机器人也可以使用 API 密钥访问的路由，密钥需要拥有对应的权限
+		botAccessible := api.Group("/")
+		botAccessible.Use(middleware.AuthMiddleware(middleware.AcceptAPIKeys()))
+		{
+			// 聊天室相关
+			botAccessible.GET("/rooms", middleware.RequireScope(models.ScopeRoomsRead), middleware.RequirePermission(rbac.PermRoomsView), handlers.GetRooms)
+			botAccessible.GET("/rooms/:id", middleware.RequireScope(models.ScopeRoomsRead), middleware.RequirePermission(rbac.PermRoomsView), handlers.GetRoom)
+			botAccessible.GET("/rooms/:id/members", middleware.RequireScope(models.ScopeRoomsRead), middleware.RequirePermission(rbac.PermRoomsView), handlers.GetRoomMembers(hub))
 
 			// 消息相关
-			protected.GET("/rooms/:id/messages", handlers.GetMessages)
+			botAccessible.GET("/rooms/:id/messages", middleware.RequireScope(models.ScopeMessagesRead), middleware.RequirePermission(rbac.PermMessagesRead), handlers.GetMessages)
+			// 发言权限与房间有关（访客只能在开启访客发言的房间发言），在处理函数中检查
+			botAccessible.POST("/rooms/:id/messages", middleware.RequireScope(models.ScopeMessagesWrite), handlers.SendMessage(hub))
+		}
+
+		// 管理接口
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthMiddleware())
+		{
+			admin.GET("/login-attempts", middleware.RequirePermission(rbac.PermAuditRead), handlers.GetLoginAttempts)
+
+			// 全局角色
+			admin.GET("/roles", middleware.RequirePermission(rbac.PermUsersManage), handlers.GetRoles)
+			admin.PUT("/users/:id/role", middleware.RequirePermission(rbac.PermUsersManage), handlers.UpdateUserRole)
+
+			// 为原创建者无法操作的房间指定新的创建者
+			admin.PUT("/rooms/:id/owner", middleware.RequirePermission(rbac.PermRoomsManage), handlers.AssignRoomOwner(hub))
+
+			// 机器人账号和 API 密钥
+			bots := admin.Group("/", middleware.RequirePermission(rbac.PermBotsManage))
+			bots.POST("/bots", handlers.CreateBot)
+			bots.GET("/bots", handlers.GetBots)
+			bots.POST("/bots/:id/api-keys", handlers.CreateAPIKey)
+			bots.GET("/api-keys", handlers.GetAPIKeys)
+			bots.DELETE("/api-keys/:id", handlers.RevokeAPIKey(hub))
 		}
 
-		// WebSocket 连接
-		api.GET("/ws", middleware.AuthMiddleware(), handlers.HandleWebSocket(hub))
+		// WebSocket 连接（浏览器无法设置 Authorization 头，在握手处理函数中完成认证）
+		api.GET("/ws", handlers.HandleWebSocket(hub))
 	}
 
 	// 启动服务器
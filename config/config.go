@@ -4,22 +4,32 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 // Config 应用配置结构
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Database DatabaseConfig `json:"database"`
-	Redis    RedisConfig    `json:"redis"`
-	JWT      JWTConfig      `json:"jwt"`
+	Server   ServerConfig         `json:"server"`
+	Database DatabaseConfig       `json:"database"`
+	Redis    RedisConfig          `json:"redis"`
+	JWT      JWTConfig            `json:"jwt"`
+	Auth     AuthConfig           `json:"auth"`
+	Mail     MailConfig           `json:"mail"`
+	Guest    GuestConfig          `json:"guest"`
+	Room     RoomConfig           `json:"room"`
+	OIDC     []OIDCProviderConfig `json:"oidc"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port string `json:"port"`
-	Mode string `json:"mode"` // debug, release, test
+	Port    string `json:"port"`
+	Mode    string `json:"mode"`     // debug, release, test
+	BaseURL string `json:"base_url"` // 对外访问地址，用于生成邮件中的链接
+
+	// 信任的反向代理地址或网段，只有来自这些地址的请求才使用 X-Forwarded-For 确定客户端 IP
+	TrustedProxies []string `json:"trusted_proxies"`
 }
 
 // DatabaseConfig 数据库配置
@@ -43,8 +53,83 @@ type RedisConfig struct {
 
 // JWTConfig JWT 配置
 type JWTConfig struct {
-	Secret     string `json:"secret"`
-	ExpireTime int    `json:"expire_time"` // 小时
+	Secret            string `json:"secret"`
+	ExpireTime        int    `json:"expire_time"`         // 小时
+	RefreshExpireTime int    `json:"refresh_expire_time"` // 小时
+	KeysDir           string `json:"keys_dir"`            // 非对称签名密钥目录，为空时使用 HS256
+	ActiveKeyID       string `json:"active_key_id"`       // 当前用于签名的密钥 ID
+}
+
+// AuthConfig 账号安全配置
+type AuthConfig struct {
+	PasswordResetExpire      int  `json:"password_reset_expire"`      // 分钟
+	EmailVerificationExpire  int  `json:"email_verification_expire"`  // 小时
+	RequireEmailVerification bool `json:"require_email_verification"` // 未验证邮箱的用户不能发言和创建房间
+
+	// 暴力破解防护
+	LoginMaxFailures   int      `json:"login_max_failures"`    // 同一账号失败多少次后开始锁定
+	LoginIPMaxFailures int      `json:"login_ip_max_failures"` // 同一 IP 失败多少次后开始锁定
+	LoginLockoutBase   int      `json:"login_lockout_base"`    // 首次锁定时长（分钟），之后每次失败翻倍
+	LoginLockoutMax    int      `json:"login_lockout_max"`     // 最长锁定时长（分钟）
+	RegisterIPLimit    int      `json:"register_ip_limit"`     // 同一 IP 每小时最多注册次数
+	AdminUsernames     []string `json:"admin_usernames"`       // 启动时设为管理员的用户名
+
+	// 密码策略，数值为 0 时不检查对应规则
+	PasswordMinLength       int    `json:"password_min_length"`       // 最小长度（字符）
+	PasswordMaxLength       int    `json:"password_max_length"`       // 最大长度（字符）
+	PasswordMinCharClasses  int    `json:"password_min_char_classes"` // 至少包含几类字符：小写字母、大写字母、数字、符号
+	PasswordCheckSimilarity bool   `json:"password_check_similarity"` // 密码不能包含用户名或邮箱
+	PasswordBreachListFile  string `json:"password_breach_list_file"` // 泄露密码列表文件，每行一个 SHA-1 哈希
+
+	// 密码哈希，登录时会把旧算法或旧参数生成的哈希升级为当前配置
+	PasswordHashAlgorithm string `json:"password_hash_algorithm"` // argon2id, bcrypt
+	Argon2Memory          int    `json:"argon2_memory"`           // KiB
+	Argon2Iterations      int    `json:"argon2_iterations"`
+	Argon2Parallelism     int    `json:"argon2_parallelism"`
+	BcryptCost            int    `json:"bcrypt_cost"`
+
+	// 账号注销
+	AccountDeletionGraceDays int `json:"account_deletion_grace_days"` // 申请注销后保留账号的天数，期间重新登录即可撤销
+
+	// 两步验证密钥在数据库中加密存储，留空时由 JWT_SECRET 派生
+	TOTPEncryptionKey string `json:"totp_encryption_key"`
+}
+
+// GuestConfig 访客配置
+type GuestConfig struct {
+	Enabled      bool `json:"enabled"`       // 允许不注册以访客身份进入
+	IPLimit      int  `json:"ip_limit"`      // 同一 IP 每小时最多创建访客次数
+	MessageLimit int  `json:"message_limit"` // 访客每分钟最多发送消息数
+	IdleTimeout  int  `json:"idle_timeout"`  // 访客无活动多久后删除（分钟）
+}
+
+// RoomConfig 房间配置
+type RoomConfig struct {
+	RestoreDays       int `json:"restore_days"`        // 删除的房间保留多少天，期间可以恢复，0 表示永久保留
+	JoinRequestExpire int `json:"join_request_expire"` // 加入申请的有效期（小时）
+}
+
+// MailConfig 邮件配置
+type MailConfig struct {
+	Driver   string `json:"driver"` // smtp, log
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+	FilePath string `json:"file_path"` // log 驱动写入的文件，为空时输出到日志
+}
+
+// OIDCProviderConfig OpenID Connect 登录提供方配置
+type OIDCProviderConfig struct {
+	Name          string   `json:"name"`         // 提供方标识，用于回调地址
+	DisplayName   string   `json:"display_name"` // 登录按钮上显示的名称
+	Issuer        string   `json:"issuer"`       // 通过 <issuer>/.well-known/openid-configuration 发现端点
+	ClientID      string   `json:"client_id"`
+	ClientSecret  string   `json:"client_secret"` // 公共客户端可以为空，仅使用 PKCE
+	RedirectURL   string   `json:"redirect_url"`  // 为空时使用 APP_BASE_URL 生成回调地址
+	Scopes        []string `json:"scopes"`
+	AutoProvision bool     `json:"auto_provision"` // 没有匹配的本地账号时自动创建
 }
 
 var AppConfig *Config
@@ -58,8 +143,11 @@ func LoadConfig() {
 
 	AppConfig = &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Mode: getEnv("GIN_MODE", "debug"),
+			Port:    getEnv("SERVER_PORT", "8080"),
+			Mode:    getEnv("GIN_MODE", "debug"),
+			BaseURL: getEnv("APP_BASE_URL", "http://localhost:8080"),
+
+			TrustedProxies: getEnvAsList("TRUSTED_PROXIES"),
 		},
 		Database: DatabaseConfig{
 			Type:     getEnv("DB_TYPE", "sqlite"),
@@ -77,10 +165,82 @@ func LoadConfig() {
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
 		JWT: JWTConfig{
-			Secret:     getEnv("JWT_SECRET", "your-secret-key"),
-			ExpireTime: getEnvAsInt("JWT_EXPIRE_TIME", 24),
+			Secret:            getEnv("JWT_SECRET", "your-secret-key"),
+			ExpireTime:        getEnvAsInt("JWT_EXPIRE_TIME", 24),
+			RefreshExpireTime: getEnvAsInt("JWT_REFRESH_EXPIRE_TIME", 720),
+			KeysDir:           getEnv("JWT_KEYS_DIR", ""),
+			ActiveKeyID:       getEnv("JWT_ACTIVE_KEY_ID", ""),
 		},
+		Auth: AuthConfig{
+			PasswordResetExpire:      getEnvAsInt("PASSWORD_RESET_EXPIRE", 30),
+			EmailVerificationExpire:  getEnvAsInt("EMAIL_VERIFICATION_EXPIRE", 24),
+			RequireEmailVerification: getEnvAsBool("REQUIRE_EMAIL_VERIFICATION", false),
+			LoginMaxFailures:         getEnvAsInt("LOGIN_MAX_FAILURES", 5),
+			LoginIPMaxFailures:       getEnvAsInt("LOGIN_IP_MAX_FAILURES", 20),
+			LoginLockoutBase:         getEnvAsInt("LOGIN_LOCKOUT_BASE", 1),
+			LoginLockoutMax:          getEnvAsInt("LOGIN_LOCKOUT_MAX", 60),
+			RegisterIPLimit:          getEnvAsInt("REGISTER_IP_LIMIT", 10),
+			AdminUsernames:           strings.Fields(strings.ReplaceAll(getEnv("ADMIN_USERNAMES", ""), ",", " ")),
+			PasswordMinLength:        getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+			PasswordMaxLength:        getEnvAsInt("PASSWORD_MAX_LENGTH", 64),
+			PasswordMinCharClasses:   getEnvAsInt("PASSWORD_MIN_CHAR_CLASSES", 2),
+			PasswordCheckSimilarity:  getEnvAsBool("PASSWORD_CHECK_SIMILARITY", true),
+			PasswordBreachListFile:   getEnv("PASSWORD_BREACH_LIST_FILE", ""),
+			PasswordHashAlgorithm:    getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+			Argon2Memory:             getEnvAsInt("ARGON2_MEMORY", 19456),
+			Argon2Iterations:         getEnvAsInt("ARGON2_ITERATIONS", 2),
+			Argon2Parallelism:        getEnvAsInt("ARGON2_PARALLELISM", 1),
+			BcryptCost:               getEnvAsInt("BCRYPT_COST", 10),
+			AccountDeletionGraceDays: getEnvAsInt("ACCOUNT_DELETION_GRACE_DAYS", 14),
+
+			TOTPEncryptionKey: getEnv("TOTP_ENCRYPTION_KEY", ""),
+		},
+		Mail: MailConfig{
+			Driver:   getEnv("MAIL_DRIVER", "log"),
+			Host:     getEnv("MAIL_HOST", "localhost"),
+			Port:     getEnvAsInt("MAIL_PORT", 587),
+			Username: getEnv("MAIL_USERNAME", ""),
+			Password: getEnv("MAIL_PASSWORD", ""),
+			From:     getEnv("MAIL_FROM", "noreply@chatroom.com"),
+			FilePath: getEnv("MAIL_FILE_PATH", ""),
+		},
+		Guest: GuestConfig{
+			Enabled:      getEnvAsBool("GUEST_ACCESS_ENABLED", false),
+			IPLimit:      getEnvAsInt("GUEST_IP_LIMIT", 10),
+			MessageLimit: getEnvAsInt("GUEST_MESSAGE_LIMIT", 5),
+			IdleTimeout:  getEnvAsInt("GUEST_IDLE_TIMEOUT", 60),
+		},
+		Room: RoomConfig{
+			RestoreDays:       getEnvAsInt("ROOM_RESTORE_DAYS", 30),
+			JoinRequestExpire: getEnvAsInt("ROOM_JOIN_REQUEST_EXPIRE", 72),
+		},
+		OIDC: loadOIDCProviders(),
+	}
+}
+
+// loadOIDCProviders 加载 OIDC_PROVIDERS 中列出的登录提供方
+// 每个提供方的配置使用 OIDC_<NAME>_ 前缀，例如 OIDC_COMPANY_ISSUER
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProviderConfig{
+			Name:          name,
+			DisplayName:   getEnv(prefix+"DISPLAY_NAME", name),
+			Issuer:        getEnv(prefix+"ISSUER", ""),
+			ClientID:      getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret:  getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:   getEnv(prefix+"REDIRECT_URL", ""),
+			Scopes:        strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+			AutoProvision: getEnvAsBool(prefix+"AUTO_PROVISION", true),
+		})
 	}
+	return providers
 }
 
 // getEnv 获取环境变量，如果不存在则返回默认值
@@ -91,6 +251,17 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvAsList 获取以逗号分隔的环境变量列表，忽略空项
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // getEnvAsInt 获取环境变量并转换为整数
 func getEnvAsInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
@@ -100,3 +271,13 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsBool 获取环境变量并转换为布尔值
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix API 密钥的固定前缀，用于和 JWT 区分
+const APIKeyPrefix = "gcr_"
+
+// apiKeyTouchInterval 最近使用时间的更新间隔
+const apiKeyTouchInterval = time.Minute
+
+// API 密钥相关错误
+var (
+	ErrInvalidAPIKey  = errors.New("invalid or revoked api key")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrNotBotAccount  = errors.New("api keys can only be issued to bot accounts")
+)
+
+// IsAPIKey 判断凭证是否为 API 密钥
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// ValidateScopes 校验权限范围，返回去重后的权限列表
+func ValidateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+
+	seen := make(map[string]bool)
+	var result []string
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !validScope(scope) {
+			return nil, fmt.Errorf("invalid scope %q", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	return result, nil
+}
+
+// validScope 权限必须是已知的权限范围，或已知权限加房间 ID
+func validScope(scope string) bool {
+	for _, known := range models.APIScopes {
+		if scope == known {
+			return true
+		}
+		if roomID, ok := strings.CutPrefix(scope, known+":"); ok {
+			id, err := strconv.ParseUint(roomID, 10, 32)
+			return err == nil && id > 0
+		}
+	}
+	return false
+}
+
+// CreateBotUser 创建机器人账号，机器人没有可用的密码
+func CreateBotUser(username, nickname string) (*models.User, error) {
+	randomPassword, err := GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := models.User{
+		Username:        username,
+		Email:           username + "@bots.invalid", // 机器人不接收邮件
+		Nickname:        nickname,
+		IsBot:           true,
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+	}
+	if err := user.SetPassword(randomPassword); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// CreateAPIKey 为机器人账号创建 API 密钥，密钥明文只在创建时返回一次
+func CreateAPIKey(bot *models.User, name string, scopes []string, createdByID uint, expiresAt *time.Time) (*models.APIKey, string, error) {
+	if !bot.IsBot {
+		return nil, "", ErrNotBotAccount
+	}
+
+	scopes, err := ValidateScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	key := APIKeyPrefix + secret
+
+	apiKey := models.APIKey{
+		UserID:      bot.ID,
+		Name:        name,
+		Prefix:      key[:12],
+		KeyHash:     HashToken(key),
+		Scopes:      strings.Join(scopes, " "),
+		CreatedByID: createdByID,
+		ExpiresAt:   expiresAt,
+	}
+	if err := database.DB.Create(&apiKey).Error; err != nil {
+		return nil, "", err
+	}
+
+	return &apiKey, key, nil
+}
+
+// AuthenticateAPIKey 校验 API 密钥，返回密钥和所属的机器人账号
+func AuthenticateAPIKey(key string) (*models.APIKey, *models.User, error) {
+	var apiKey models.APIKey
+	if err := database.DB.Where("key_hash = ?", HashToken(key)).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+	if !apiKey.IsActive() {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, apiKey.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+	if !user.IsBot {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	if now := time.Now(); apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval {
+		database.DB.Model(&apiKey).Update("last_used_at", &now)
+		apiKey.LastUsedAt = &now
+	}
+
+	return &apiKey, &user, nil
+}
+
+// RevokeAPIKey 吊销 API 密钥
+func RevokeAPIKey(id uint) error {
+	now := time.Now()
+	result := database.DB.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", &now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
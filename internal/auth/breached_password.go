@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"gin-chat-room/config"
+	"log"
+	"os"
+	"strings"
+)
+
+// breachedPrefixLength 哈希前缀长度，与 Have I Been Pwned 的范围查询一致
+const breachedPrefixLength = 5
+
+// BreachedPasswordList 泄露密码列表
+// 按 SHA-1 哈希的前 5 位分组保存后缀，查询时先取前缀范围再比较后缀
+type BreachedPasswordList struct {
+	ranges map[string]map[string]struct{}
+	count  int
+}
+
+// breachedPasswords 启动时加载的泄露密码列表，为 nil 时不检查
+var breachedPasswords *BreachedPasswordList
+
+// InitBreachedPasswords 加载配置的泄露密码列表
+func InitBreachedPasswords() error {
+	path := config.AppConfig.Auth.PasswordBreachListFile
+	if path == "" {
+		breachedPasswords = nil
+		return nil
+	}
+
+	list, err := LoadBreachedPasswordList(path)
+	if err != nil {
+		return err
+	}
+	breachedPasswords = list
+
+	log.Printf("Loaded %d breached password hashes from %s", list.Len(), path)
+	return nil
+}
+
+// LoadBreachedPasswordList 从文件加载泄露密码列表
+// 每行一个大写或小写的 SHA-1 十六进制哈希，可以带 ":出现次数" 后缀
+// （与 Have I Been Pwned 下载工具的输出格式相同），空行和 # 开头的行会被忽略
+func LoadBreachedPasswordList(path string) (*BreachedPasswordList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &BreachedPasswordList{ranges: make(map[string]map[string]struct{})}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(strings.TrimSpace(hash))
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-1 hash", path, lineNumber)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-1 hash", path, lineNumber)
+		}
+
+		list.add(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// add 添加一个大写的 SHA-1 哈希
+func (l *BreachedPasswordList) add(hash string) {
+	prefix, suffix := hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+	suffixes, ok := l.ranges[prefix]
+	if !ok {
+		suffixes = make(map[string]struct{})
+		l.ranges[prefix] = suffixes
+	}
+	if _, exists := suffixes[suffix]; !exists {
+		suffixes[suffix] = struct{}{}
+		l.count++
+	}
+}
+
+// Len 返回列表中的哈希数量
+func (l *BreachedPasswordList) Len() int {
+	return l.count
+}
+
+// Contains 检查密码是否在泄露列表中
+func (l *BreachedPasswordList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	_, found := l.ranges[hash[:breachedPrefixLength]][hash[breachedPrefixLength:]]
+	return found
+}
+
+// IsBreachedPassword 检查密码是否出现在已加载的泄露密码列表中
+func IsBreachedPassword(password string) bool {
+	return breachedPasswords != nil && breachedPasswords.Contains(password)
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/oidc"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 外部登录相关错误
+var (
+	ErrExternalEmailMissing     = errors.New("identity provider did not return an email address")
+	ErrExternalEmailNotVerified = errors.New("identity provider has not verified the email address")
+	ErrLocalEmailNotVerified    = errors.New("an account with this email exists but its email is not verified")
+	ErrExternalAccountNotFound  = errors.New("no account is linked to this identity")
+)
+
+// usernameDisallowed 生成用户名时需要去掉的字符
+var usernameDisallowed = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// ResolveExternalIdentity 根据外部身份查找或创建本地用户
+// 查找顺序：已关联的身份、邮箱相同且双方都已验证的本地用户、自动创建新用户
+func ResolveExternalIdentity(provider string, identity *oidc.Identity, autoProvision bool) (*models.User, error) {
+	var user models.User
+
+	var link models.UserIdentity
+	err := database.DB.Where("provider = ? AND subject = ?", provider, identity.Subject).First(&link).Error
+	if err == nil {
+		if err := database.DB.First(&user, link.UserID).Error; err != nil {
+			return nil, err
+		}
+		if identity.Email != "" && identity.Email != link.Email {
+			database.DB.Model(&link).Update("email", identity.Email)
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if identity.Email == "" {
+		return nil, ErrExternalEmailMissing
+	}
+	// 只信任提供方已验证的邮箱，否则任何人都可以通过外部账号接管同邮箱的本地账号
+	if !identity.EmailVerified {
+		return nil, ErrExternalEmailNotVerified
+	}
+
+	err = database.DB.Where("LOWER(email) = ?", identity.Email).First(&user).Error
+	if err == nil {
+		// 本地账号邮箱未验证时，注册者不一定拥有该邮箱，不能自动关联
+		if !user.EmailVerified {
+			return nil, ErrLocalEmailNotVerified
+		}
+		if err := linkIdentity(database.DB, user.ID, provider, identity); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if !autoProvision {
+		return nil, ErrExternalAccountNotFound
+	}
+
+	return provisionUser(provider, identity)
+}
+
+// linkIdentity 将外部身份关联到本地用户
+func linkIdentity(tx *gorm.DB, userID uint, provider string, identity *oidc.Identity) error {
+	return tx.Create(&models.UserIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}).Error
+}
+
+// provisionUser 为外部身份创建本地用户
+// 新用户没有本地密码，需要时可以通过忘记密码设置
+func provisionUser(provider string, identity *oidc.Identity) (*models.User, error) {
+	now := time.Now()
+	user := models.User{
+		Email:           identity.Email,
+		Password:        "!", // 不是有效的哈希，需要通过忘记密码设置密码后才能使用密码登录
+		Nickname:        identity.Name,
+		Avatar:          identity.Picture,
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		username, err := availableUsername(tx, identity)
+		if err != nil {
+			return err
+		}
+		user.Username = username
+
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return linkIdentity(tx, user.ID, provider, identity)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// availableUsername 根据外部身份生成一个未被占用的用户名
+func availableUsername(tx *gorm.DB, identity *oidc.Identity) (string, error) {
+	base := identity.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	base = usernameDisallowed.ReplaceAllString(base, "")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	if len(base) < 3 {
+		base = "user" + base
+	}
+
+	candidate := base
+	for i := 0; i < 10; i++ {
+		var count int64
+		// 软删除的用户仍然占用唯一索引
+		if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 && !models.IsReservedUsername(candidate) {
+			return candidate, nil
+		}
+
+		suffix, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s_%04d", base, suffix.Int64())
+	}
+
+	return "", errors.New("failed to generate a unique username")
+}
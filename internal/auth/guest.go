@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"math/big"
+	"time"
+)
+
+// guestNicknameMax 访客昵称中随机编号的上限
+const guestNicknameMax = 10000
+
+// CreateGuestUser 创建访客账号，用户名和昵称随机生成
+// 访客使用 guest 角色，没有可用的密码，只能通过访客入口获得令牌
+func CreateGuestUser() (*models.User, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	number, err := rand.Int(rand.Reader, big.NewInt(guestNicknameMax))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	username := "guest_" + hex.EncodeToString(suffix)
+	user := models.User{
+		Username:        username,
+		Email:           username + "@guests.invalid", // 访客不接收邮件
+		Nickname:        fmt.Sprintf("访客%04d", number.Int64()),
+		Password:        "!", // 不是有效的哈希，不能使用密码登录
+		Role:            "guest",
+		IsGuest:         true,
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
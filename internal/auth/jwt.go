@@ -3,30 +3,51 @@ package auth
 import (
 	"errors"
 	"gin-chat-room/config"
+	"gin-chat-room/internal/models"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // Claims JWT 声明结构
+// RegisteredClaims.ID 即 jti，用于在退出登录后吊销单个 token
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	UserID       uint   `json:"user_id"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	SessionID    string `json:"sid,omitempty"` // 登录会话 ID，会话被注销后 token 失效
+	TokenVersion uint   `json:"ver"`           // 签发时用户的令牌版本，修改密码后旧版本的 token 失效
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成 JWT token
+// GenerateToken 生成不属于任何会话的 JWT token，令牌版本为 0
+// 用户修改过密码后需要使用 GenerateSessionToken 签发
 func GenerateToken(userID uint, username, email string) (string, error) {
+	tokenString, _, err := generateToken(userID, username, email, "", 0)
+	return tokenString, err
+}
+
+// GenerateSessionToken 为用户的登录会话生成 JWT token，同时返回 token 的 jti
+// sessionID 为空时生成不属于任何会话的 token
+func GenerateSessionToken(user *models.User, sessionID string) (string, string, error) {
+	return generateToken(user.ID, user.Username, user.Email, sessionID, user.TokenVersion)
+}
+
+// generateToken 签发访问令牌
+func generateToken(userID uint, username, email, sessionID string, tokenVersion uint) (string, string, error) {
 	// 设置过期时间
 	expirationTime := time.Now().Add(time.Duration(config.AppConfig.JWT.ExpireTime) * time.Hour)
 
 	// 创建声明
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Email:    email,
+		UserID:       userID,
+		Username:     username,
+		Email:        email,
+		SessionID:    sessionID,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -35,54 +56,28 @@ func GenerateToken(userID uint, username, email string) (string, error) {
 		},
 	}
 
-	// 创建 token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// 签名 token
-	tokenString, err := token.SignedString([]byte(config.AppConfig.JWT.Secret))
+	// 创建并签名 token
+	tokenString, err := signToken(claims)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return tokenString, nil
+	return tokenString, claims.ID, nil
 }
 
 // ParseToken 解析 JWT token
 func ParseToken(tokenString string) (*Claims, error) {
 	// 解析 token
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// 验证签名方法
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(config.AppConfig.JWT.Secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, verificationKey)
 
 	if err != nil {
 		return nil, err
 	}
 
-	// 验证 token 是否有效
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	// 验证 token 是否有效，带有 Audience 的用途限定令牌不能作为访问令牌
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && len(claims.Audience) == 0 {
 		return claims, nil
 	}
 
 	return nil, errors.New("invalid token")
 }
-
-// RefreshToken 刷新 token
-func RefreshToken(tokenString string) (string, error) {
-	// 解析旧 token
-	claims, err := ParseToken(tokenString)
-	if err != nil {
-		return "", err
-	}
-
-	// 检查 token 是否即将过期（在过期前 1 小时内可以刷新）
-	if time.Until(claims.ExpiresAt.Time) > time.Hour {
-		return "", errors.New("token is not close to expiration")
-	}
-
-	// 生成新 token
-	return GenerateToken(claims.UserID, claims.Username, claims.Email)
-}
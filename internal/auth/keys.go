@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"gin-chat-room/config"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey JWT 签名密钥
+// 只有公钥的密钥仅用于验证轮换前签发的 token
+type signingKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// keySet 当前加载的密钥集合
+type keySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+var (
+	// keys 为 nil 时使用 JWT.Secret 进行 HS256 签名
+	keys      *keySet
+	keysMutex sync.RWMutex
+)
+
+// InitKeys 从 JWT.KeysDir 加载非对称签名密钥
+// 目录中每个 <kid>.pem 文件是一个密钥，JWT.ActiveKeyID 指定用于签名的私钥，
+// 其余密钥只用于验证，从而支持多个密钥同时生效的平滑轮换
+func InitKeys() error {
+	cfg := config.AppConfig.JWT
+
+	keysMutex.Lock()
+	defer keysMutex.Unlock()
+
+	if cfg.KeysDir == "" {
+		keys = nil // 未配置密钥目录，使用 HS256
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(cfg.KeysDir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	set := &keySet{keys: make(map[string]*signingKey)}
+	for _, file := range files {
+		key, err := loadKeyFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to load key %s: %w", file, err)
+		}
+		set.keys[key.ID] = key
+	}
+
+	if len(set.keys) == 0 {
+		return fmt.Errorf("no keys found in %s", cfg.KeysDir)
+	}
+
+	activeID := cfg.ActiveKeyID
+	if activeID == "" {
+		// 未指定时，只有一个私钥的情况下自动选用
+		for id, key := range set.keys {
+			if key.PrivateKey == nil {
+				continue
+			}
+			if activeID != "" {
+				return errors.New("JWT_ACTIVE_KEY_ID is required when several private keys are present")
+			}
+			activeID = id
+		}
+	}
+
+	active, ok := set.keys[activeID]
+	if !ok || active.PrivateKey == nil {
+		return fmt.Errorf("active key %q not found or has no private key", activeID)
+	}
+	set.active = active
+
+	keys = set
+	return nil
+}
+
+// loadKeyFile 从 PEM 文件加载密钥，文件名（不含扩展名）作为 kid
+func loadKeyFile(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	key := &signingKey{
+		ID: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.PrivateKey, key.PublicKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.PublicKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.PrivateKey, key.PublicKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.PublicKey = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, only RSA and Ed25519 are supported", parsed)
+	}
+
+	if rsaKey, ok := key.PublicKey.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must be at least 2048 bits")
+	}
+
+	return key, nil
+}
+
+// signToken 使用当前密钥签名 token
+func signToken(claims jwt.Claims) (string, error) {
+	keysMutex.RLock()
+	set := keys
+	keysMutex.RUnlock()
+
+	if set == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(config.AppConfig.JWT.Secret))
+	}
+
+	token := jwt.NewWithClaims(set.active.Method, claims)
+	token.Header["kid"] = set.active.ID
+	return token.SignedString(set.active.PrivateKey)
+}
+
+// verificationKey 根据 token 头部选择验证密钥
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	keysMutex.RLock()
+	set := keys
+	keysMutex.RUnlock()
+
+	if set == nil {
+		// 验证签名方法
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(config.AppConfig.JWT.Secret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := set.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	// 签名方法必须与密钥类型一致，防止算法混淆攻击
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	return key.PublicKey, nil
+}
+
+// JWKS 返回当前所有验证密钥的 JSON Web Key Set
+// 使用 HS256 时共享密钥不能公开，返回空集合
+func JWKS() map[string]interface{} {
+	keysMutex.RLock()
+	set := keys
+	keysMutex.RUnlock()
+
+	jwks := []map[string]interface{}{}
+	if set != nil {
+		ids := make([]string, 0, len(set.keys))
+		for id := range set.keys {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			jwks = append(jwks, publicJWK(set.keys[id]))
+		}
+	}
+
+	return map[string]interface{}{
+		"keys": jwks,
+	}
+}
+
+// publicJWK 将公钥转换为 JWK 格式
+func publicJWK(key *signingKey) map[string]interface{} {
+	jwk := map[string]interface{}{
+		"kid": key.ID,
+		"alg": key.Method.Alg(),
+		"use": "sig",
+	}
+
+	switch k := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk["kty"] = "OKP"
+		jwk["crv"] = "Ed25519"
+		jwk["x"] = base64.RawURLEncoding.EncodeToString(k)
+	}
+
+	return jwk
+}
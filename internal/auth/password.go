@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"errors"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// 修改密码相关错误
+var (
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrPasswordUnchanged = errors.New("new password must be different from the current password")
+)
+
+// ChangePassword 校验当前密码后设置新密码，并使用户已签发的所有令牌失效
+// 成功后 user 中的密码和令牌版本均为最新值
+func ChangePassword(user *models.User, currentPassword, newPassword string) error {
+	if !user.CheckPassword(currentPassword) {
+		return ErrIncorrectPassword
+	}
+	if err := ValidatePassword(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+	if user.CheckPassword(newPassword) {
+		return ErrPasswordUnchanged
+	}
+
+	if err := user.SetPassword(newPassword); err != nil {
+		return err
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("password", user.Password).Error; err != nil {
+			return err
+		}
+		return revokeAllUserTokens(tx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return database.DB.First(user, user.ID).Error
+}
+
+// RehashPasswordIfNeeded 登录成功后把旧算法或旧参数生成的密码哈希升级为当前配置
+// 升级失败不影响登录，只记录日志
+func RehashPasswordIfNeeded(user *models.User, password string) {
+	if !user.PasswordNeedsRehash() {
+		return
+	}
+
+	oldHash := user.Password
+	if err := user.SetPassword(password); err != nil {
+		log.Printf("Failed to rehash password for user %d: %v", user.ID, err)
+		user.Password = oldHash
+		return
+	}
+
+	// 条件更新，避免覆盖同时修改的密码
+	if err := database.DB.Model(&models.User{}).
+		Where("id = ? AND password = ?", user.ID, oldHash).
+		Update("password", user.Password).Error; err != nil {
+		log.Printf("Failed to save rehashed password for user %d: %v", user.ID, err)
+	}
+}
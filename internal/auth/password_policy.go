@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"fmt"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/passwordhash"
+	"strings"
+	"unicode"
+)
+
+// 密码策略规则名，客户端可以据此显示本地化的提示
+const (
+	PasswordRuleMinLength   = "min_length"
+	PasswordRuleMaxLength   = "max_length"
+	PasswordRuleCharClasses = "char_classes"
+	PasswordRuleUsername    = "similar_to_username"
+	PasswordRuleEmail       = "similar_to_email"
+	PasswordRuleBreached    = "breached"
+)
+
+// similarityMinLength 用户名或邮箱短于该长度时不做相似度检查
+const similarityMinLength = 3
+
+// PasswordViolation 未通过的密码规则
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError 密码不符合密码策略，Violations 列出所有未通过的规则
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+// Error 实现 error 接口
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return "password does not meet the policy: " + strings.Join(messages, "; ")
+}
+
+// ValidatePassword 按密码策略检查新密码，返回所有未通过的规则
+// username 和 email 用于相似度检查，可以为空
+func ValidatePassword(password, username, email string) error {
+	cfg := config.AppConfig.Auth
+	var violations []PasswordViolation
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, PasswordViolation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := len([]rune(password))
+	minLength := cfg.PasswordMinLength
+	if minLength < 1 {
+		minLength = 1
+	}
+	if length < minLength {
+		add(PasswordRuleMinLength, "password must be at least %d characters", minLength)
+	}
+	if cfg.PasswordMaxLength > 0 && length > cfg.PasswordMaxLength {
+		add(PasswordRuleMaxLength, "password must be at most %d characters", cfg.PasswordMaxLength)
+	} else if maxBytes := passwordhash.MaxPasswordBytes(); maxBytes > 0 && len(password) > maxBytes {
+		add(PasswordRuleMaxLength, "password must be at most %d bytes", maxBytes)
+	}
+
+	if cfg.PasswordMinCharClasses > 0 && countCharClasses(password) < cfg.PasswordMinCharClasses {
+		add(PasswordRuleCharClasses, "password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", cfg.PasswordMinCharClasses)
+	}
+
+	if cfg.PasswordCheckSimilarity {
+		if containsFolded(password, username) {
+			add(PasswordRuleUsername, "password must not contain the username")
+		}
+		localPart, _, _ := strings.Cut(email, "@")
+		if containsFolded(password, localPart) {
+			add(PasswordRuleEmail, "password must not contain the email address")
+		}
+	}
+
+	if IsBreachedPassword(password) {
+		add(PasswordRuleBreached, "password has appeared in a data breach")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// countCharClasses 统计密码包含几类字符
+func countCharClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+// containsFolded 忽略大小写检查密码是否包含 s 或 s 的倒序
+func containsFolded(password, s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len([]rune(s)) < similarityMinLength {
+		return false
+	}
+
+	password = strings.ToLower(password)
+	if strings.Contains(password, s) {
+		return true
+	}
+
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return strings.Contains(password, string(runes))
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidResetToken 重置令牌不存在、已过期或已使用
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CreatePasswordResetToken 为用户创建密码重置令牌，之前未使用的令牌同时失效
+func CreatePasswordResetToken(userID uint) (string, error) {
+	tokenString, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", userID).
+			Update("used_at", &now).Error; err != nil {
+			return err
+		}
+
+		resetToken := models.PasswordResetToken{
+			UserID:    userID,
+			TokenHash: HashToken(tokenString),
+			ExpiresAt: now.Add(time.Duration(config.AppConfig.Auth.PasswordResetExpire) * time.Minute),
+		}
+		return tx.Create(&resetToken).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+// ResetPassword 使用重置令牌设置新密码，并使用户已签发的所有令牌失效
+func ResetPassword(tokenString, newPassword string) (*models.User, error) {
+	var user models.User
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var resetToken models.PasswordResetToken
+		if err := tx.Where("token_hash = ?", HashToken(tokenString)).First(&resetToken).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+
+		if !resetToken.IsValid() {
+			return ErrInvalidResetToken
+		}
+
+		// 条件更新保证令牌只能使用一次
+		now := time.Now()
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", resetToken.ID).
+			Update("used_at", &now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		if err := tx.First(&user, resetToken.UserID).Error; err != nil {
+			return err
+		}
+
+		// 新密码不符合策略时回滚，重置令牌仍然可以使用
+		if err := ValidatePassword(newPassword, user.Username, user.Email); err != nil {
+			return err
+		}
+
+		if err := user.SetPassword(newPassword); err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("password", user.Password).Error; err != nil {
+			return err
+		}
+
+		return revokeAllUserTokens(tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
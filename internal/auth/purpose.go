@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// 用途限定令牌的用途名称
+const (
+	PurposeEmailVerification  = "email_verification"
+	PurposeTwoFactorChallenge = "two_factor_challenge"
+)
+
+// PurposeClaims 用途限定的短期令牌声明
+// Audience 固定为用途名称，ParseToken 会拒绝带有 Audience 的令牌，因此不能当作访问令牌使用
+type PurposeClaims struct {
+	UserID uint   `json:"user_id"`
+	Value  string `json:"value,omitempty"` // 与用途相关的附加数据，如待验证的邮箱
+	jwt.RegisteredClaims
+}
+
+// GeneratePurposeToken 签发用途限定的短期令牌
+func GeneratePurposeToken(purpose string, userID uint, value string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &PurposeClaims{
+		UserID: userID,
+		Value:  value,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "gin-chat-room",
+			Audience:  jwt.ClaimStrings{purpose},
+		},
+	}
+
+	return signToken(claims)
+}
+
+// ParsePurposeToken 解析用途限定的令牌，用途不匹配时返回错误
+func ParsePurposeToken(purpose, tokenString string) (*PurposeClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &PurposeClaims{}, verificationKey, jwt.WithAudience(purpose))
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*PurposeClaims); ok && token.Valid {
+		return claims, nil
+	}
+
+	return nil, errors.New("invalid token")
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"errors"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 刷新令牌相关错误
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token has expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+// IssueRefreshToken 签发新的刷新令牌，familyID 为空时开启一个新的令牌家族
+func IssueRefreshToken(userID uint, familyID string) (string, error) {
+	return issueRefreshToken(database.DB, userID, familyID)
+}
+
+// issueRefreshToken 在指定的数据库会话中签发刷新令牌
+func issueRefreshToken(tx *gorm.DB, userID uint, familyID string) (string, error) {
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	tokenString, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	refreshToken := models.RefreshToken{
+		UserID:    userID,
+		TokenHash: HashToken(tokenString),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(time.Duration(config.AppConfig.JWT.RefreshExpireTime) * time.Hour),
+	}
+	if err := tx.Create(&refreshToken).Error; err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+// RotateRefreshToken 使用刷新令牌换取同一家族中的新刷新令牌
+// 每个刷新令牌只能使用一次，如果已使用或已吊销的令牌被重放，整个家族都会被吊销
+func RotateRefreshToken(tokenString string) (*models.RefreshToken, string, error) {
+	var current models.RefreshToken
+	var newToken string
+	reused := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ?", HashToken(tokenString)).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if current.UsedAt != nil || current.RevokedAt != nil {
+			reused = true
+			return ErrRefreshTokenReused
+		}
+
+		if current.IsExpired() {
+			return ErrRefreshTokenExpired
+		}
+
+		// 条件更新保证并发请求中只有一个能成功使用该令牌
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", current.ID).
+			Update("used_at", &now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return ErrRefreshTokenReused
+		}
+
+		var err error
+		newToken, err = issueRefreshToken(tx, current.UserID, current.FamilyID)
+		return err
+	})
+
+	if reused {
+		// 令牌被重放，说明令牌可能已泄露，吊销整个家族
+		if err := RevokeRefreshTokenFamily(current.FamilyID); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &current, newToken, nil
+}
+
+// RevokeRefreshTokenFamily 吊销同一家族中的所有刷新令牌
+func RevokeRefreshTokenFamily(familyID string) error {
+	now := time.Now()
+	return database.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", &now).Error
+}
+
+// RevokeRefreshToken 吊销用户持有的刷新令牌所在的整个家族
+func RevokeRefreshToken(tokenString string, userID uint) error {
+	var refreshToken models.RefreshToken
+	if err := database.DB.Where("token_hash = ? AND user_id = ?", HashToken(tokenString), userID).First(&refreshToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+
+	return RevokeRefreshTokenFamily(refreshToken.FamilyID)
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RevokeAllUserTokens 使用户已签发的所有访问令牌和刷新令牌失效，并注销所有会话
+// 用于重置密码等需要让所有已登录设备重新登录的场景
+func RevokeAllUserTokens(userID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return revokeAllUserTokens(tx, userID)
+	})
+}
+
+// revokeAllUserTokens 在指定的数据库会话中吊销用户的所有令牌
+func revokeAllUserTokens(tx *gorm.DB, userID uint) error {
+	now := time.Now()
+
+	// 递增令牌版本，之前签发的访问令牌在中间件中会被拒绝
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", &now).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", &now).Error
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"errors"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// sessionTouchInterval 最近使用时间的更新间隔，避免每个请求都写数据库
+const sessionTouchInterval = time.Minute
+
+// 登录会话相关错误
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionRevoked  = errors.New("session has been revoked")
+)
+
+// SessionTokens 登录会话签发的令牌
+type SessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// CreateSession 为一次登录创建会话，并签发访问令牌和新家族的刷新令牌
+func CreateSession(user *models.User, userAgent, ip string) (*models.Session, *SessionTokens, error) {
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		FamilyID:   uuid.New().String(),
+		UserAgent:  userAgent,
+		IP:         ip,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(time.Duration(config.AppConfig.JWT.RefreshExpireTime) * time.Hour),
+	}
+
+	accessToken, jti, err := GenerateSessionToken(user, session.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	session.TokenID = jti
+
+	var refreshToken string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		refreshToken, err = issueRefreshToken(tx, user.ID, session.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &session, &SessionTokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// RefreshSession 刷新令牌轮换后为对应的会话签发新的访问令牌
+// 没有会话的旧刷新令牌家族签发不带会话 ID 的访问令牌
+func RefreshSession(user *models.User, familyID, ip string) (string, error) {
+	var session models.Session
+	err := database.DB.Where("family_id = ?", familyID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		accessToken, _, err := GenerateSessionToken(user, "")
+		return accessToken, err
+	}
+	if err != nil {
+		return "", err
+	}
+	if session.RevokedAt != nil {
+		return "", ErrSessionRevoked
+	}
+
+	accessToken, jti, err := GenerateSessionToken(user, session.ID)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = database.DB.Model(&session).Updates(map[string]interface{}{
+		"token_id":     jti,
+		"ip":           ip,
+		"last_used_at": now,
+		"expires_at":   now.Add(time.Duration(config.AppConfig.JWT.RefreshExpireTime) * time.Hour),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+
+	return accessToken, nil
+}
+
+// ValidateSession 校验访问令牌所属的会话仍然有效，并更新最近使用时间
+func ValidateSession(sessionID string, userID uint) (*models.Session, error) {
+	var session models.Session
+	if err := database.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	if session.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+
+	if now := time.Now(); now.Sub(session.LastUsedAt) >= sessionTouchInterval {
+		database.DB.Model(&session).Update("last_used_at", now)
+		session.LastUsedAt = now
+	}
+
+	return &session, nil
+}
+
+// ListSessions 获取用户所有有效的会话，最近使用的在前
+func ListSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := database.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession 注销用户的一个会话，同时吊销该会话的刷新令牌
+func RevokeSession(userID uint, sessionID string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		if err := tx.Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).First(&session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSessionNotFound
+			}
+			return err
+		}
+
+		return revokeSessions(tx, []models.Session{session})
+	})
+}
+
+// RevokeOtherSessions 注销用户除 keepSessionID 以外的所有会话，返回被注销的会话 ID
+func RevokeOtherSessions(userID uint, keepSessionID string) ([]string, error) {
+	var ids []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var sessions []models.Session
+		if err := tx.Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepSessionID).Find(&sessions).Error; err != nil {
+			return err
+		}
+
+		for _, session := range sessions {
+			ids = append(ids, session.ID)
+		}
+		return revokeSessions(tx, sessions)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// revokeSessions 在指定的数据库会话中注销会话并吊销对应的刷新令牌家族
+func revokeSessions(tx *gorm.DB, sessions []models.Session) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(sessions))
+	families := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+		families = append(families, session.FamilyID)
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.Session{}).Where("id IN ?", ids).Update("revoked_at", &now).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.RefreshToken{}).
+		Where("family_id IN ? AND revoked_at IS NULL", families).
+		Update("revoked_at", &now).Error
+}
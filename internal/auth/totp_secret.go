@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"gin-chat-room/config"
+	"strings"
+)
+
+// encryptedTOTPPrefix 加密存储的 TOTP 密钥前缀，更换加密方式时递增版本号
+const encryptedTOTPPrefix = "enc:v1:"
+
+// ErrInvalidTOTPSecret 存储的 TOTP 密钥无法解密，通常是加密密钥被修改
+var ErrInvalidTOTPSecret = errors.New("cannot decrypt two-factor secret")
+
+// totpCipher 使用配置的加密密钥创建 AES-256-GCM，未配置时由 JWT 密钥派生
+func totpCipher() (cipher.AEAD, error) {
+	key := config.AppConfig.Auth.TOTPEncryptionKey
+	if key == "" {
+		key = "totp:" + config.AppConfig.JWT.Secret
+	}
+	sum := sha256.Sum256([]byte(key))
+
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptTOTPSecret 加密 TOTP 密钥用于存储
+func EncryptTOTPSecret(secret string) (string, error) {
+	aead, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(secret), nil)
+	return encryptedTOTPPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptTOTPSecret 解密存储的 TOTP 密钥
+func DecryptTOTPSecret(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedTOTPPrefix) {
+		return "", ErrInvalidTOTPSecret
+	}
+
+	aead, err := totpCipher()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedTOTPPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidTOTPSecret
+	}
+
+	secret, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrInvalidTOTPSecret
+	}
+	return string(secret), nil
+}
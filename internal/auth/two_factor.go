@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount 每次生成的恢复码数量
+const recoveryCodeCount = 10
+
+// normalizeRecoveryCode 统一恢复码格式，忽略大小写、空格和连字符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// GenerateRecoveryCodes 为用户生成新的恢复码，之前的恢复码全部作废
+// 恢复码明文只在生成时返回一次
+func GenerateRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		secret, err := GenerateTOTPSecret()
+		if err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(secret[:10])
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		records = append(records, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: HashToken(raw),
+		})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// useRecoveryCode 使用恢复码，每个恢复码只能使用一次
+func useRecoveryCode(userID uint, code string) (bool, error) {
+	now := time.Now()
+	result := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", &now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// VerifyTwoFactorCode 校验两步验证码或恢复码
+// 6 位数字按 TOTP 验证码处理，同一时间步的验证码只能使用一次；其他输入按恢复码处理
+func VerifyTwoFactorCode(user *models.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if code == "" || user.TOTPSecret == "" {
+		return false, nil
+	}
+
+	if len(code) != totpDigits {
+		return useRecoveryCode(user.ID, code)
+	}
+
+	secret, err := DecryptTOTPSecret(user.TOTPSecret)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	// 条件更新防止并发请求重放同一个验证码
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	user.TOTPLastStep = step
+	return true, nil
+}
+
+// DisableTwoFactor 关闭两步验证并删除所有恢复码
+func DisableTwoFactor(userID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_secret":    "",
+			"totp_enabled":   false,
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
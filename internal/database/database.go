@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"gin-chat-room/config"
 	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/passwordhash"
 	"log"
 
 	"gorm.io/driver/postgres"
@@ -63,12 +64,74 @@ func InitDB() error {
 
 // AutoMigrate 自动迁移数据库表
 func AutoMigrate() error {
-	return DB.AutoMigrate(
+	// 添加邮箱验证字段之前注册的用户没有机会验证邮箱，需要在迁移前记录是否已有该字段
+	backfillEmailVerified := DB.Migrator().HasTable(&models.User{}) && !DB.Migrator().HasColumn(&models.User{}, "email_verified")
+
+	if err := DB.AutoMigrate(
 		&models.User{},
 		&models.Room{},
 		&models.RoomMember{},
 		&models.Message{},
-	)
+		&models.RefreshToken{},
+		&models.PasswordResetToken{},
+		&models.RecoveryCode{},
+		&models.UserIdentity{},
+		&models.LoginAttempt{},
+		&models.Session{},
+		&models.APIKey{},
+		&models.RoomInvite{},
+		&models.RoomBan{},
+		&models.RoomOwnershipTransfer{},
+		&models.RoomJoinRequest{},
+	); err != nil {
+		return err
+	}
+
+	if backfillEmailVerified {
+		if err := migrateEmailVerified(); err != nil {
+			return err
+		}
+	}
+
+	return migrateRoomPasswords()
+}
+
+// migrateEmailVerified 将邮箱验证功能上线前注册的用户标记为已验证，开启 REQUIRE_EMAIL_VERIFICATION 后不影响这些用户
+func migrateEmailVerified() error {
+	result := DB.Unscoped().Model(&models.User{}).Where("1 = 1").Update("email_verified", true)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	log.Printf("Marked %d existing users as email verified", result.RowsAffected)
+	return nil
+}
+
+// migrateRoomPasswords 将旧版本明文存储的房间密码转换为哈希
+func migrateRoomPasswords() error {
+	var rooms []models.Room
+	if err := DB.Unscoped().Where("password <> ?", "").Find(&rooms).Error; err != nil {
+		return err
+	}
+
+	migrated := 0
+	for _, room := range rooms {
+		if passwordhash.IsHash(room.Password) {
+			continue
+		}
+		if err := room.SetPassword(room.Password); err != nil {
+			return err
+		}
+		if err := DB.Unscoped().Model(&room).Update("password", room.Password).Error; err != nil {
+			return err
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		log.Printf("Hashed %d plaintext room passwords", migrated)
+	}
+	return nil
 }
 
 // CreateDefaultData 创建默认数据
@@ -79,9 +142,10 @@ func CreateDefaultData() error {
 	if count == 0 {
 		// 创建系统用户
 		systemUser := &models.User{
-			Username: "system",
-			Email:    "system@chatroom.com",
-			Nickname: "系统",
+			Username:      "system",
+			Email:         "system@chatroom.com",
+			Nickname:      "系统",
+			EmailVerified: true,
 		}
 		systemUser.SetPassword("system123")
 		if err := DB.Create(systemUser).Error; err != nil {
@@ -114,6 +178,13 @@ func CreateDefaultData() error {
 		log.Println("Default data created successfully")
 	}
 
+	// 将配置中的用户设为站点管理员
+	if usernames := config.AppConfig.Auth.AdminUsernames; len(usernames) > 0 {
+		if err := DB.Model(&models.User{}).Where("username IN ?", usernames).Update("role", "site_admin").Error; err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
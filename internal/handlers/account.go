@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeleteAccountRequest 注销账号请求结构
+// 有密码的账号需要确认密码；没有密码的账号（如外部登录创建的账号）开启了两步验证时需要验证码，否则需要最近重新登录
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// reauthenticationWindow 没有密码也没有开启两步验证的账号，在重新登录后多长时间内可以进行敏感操作
+const reauthenticationWindow = 10 * time.Minute
+
+// ExportProfile 导出当前用户的个人数据（zip 压缩包）
+func ExportProfile(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := services.ExportAccount(&buf, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export account data",
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("chatroom-export-%d-%s.zip", user.ID, time.Now().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// DeleteAccount 申请注销当前账号
+// 需要确认身份，所有设备立即退出登录，宽限期结束后删除账号，期间重新登录即可撤销
+func DeleteAccount(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := middleware.GetCurrentUser(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "User not found",
+			})
+			return
+		}
+
+		var req DeleteAccountRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request data: " + err.Error(),
+			})
+			return
+		}
+
+		if user.HasPassword() {
+			if !confirmPassword(c, user, req.Password) {
+				return
+			}
+		} else if !confirmWithoutPassword(c, user, req.Code) {
+			return
+		}
+
+		deleteAt, err := services.ScheduleAccountDeletion(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to schedule account deletion",
+			})
+			return
+		}
+
+		// 断开所有 WebSocket 连接
+		hub.DisconnectUser(user.ID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":               "Account scheduled for deletion, log in again before the deadline to cancel",
+			"deletion_scheduled_at": deleteAt,
+		})
+	}
+}
+
+// confirmWithoutPassword 为没有密码的账号确认身份
+// 开启两步验证时校验验证码或恢复码，否则要求当前会话是最近重新登录创建的，失败时已写入响应
+func confirmWithoutPassword(c *gin.Context, user *models.User, code string) bool {
+	if user.TOTPEnabled {
+		ok, err := auth.VerifyTwoFactorCode(user, code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to verify code",
+			})
+			return false
+		}
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Invalid verification code",
+			})
+			return false
+		}
+		return true
+	}
+
+	claims, _ := middleware.GetCurrentClaims(c)
+	if claims == nil || claims.SessionID == "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Please log in again to confirm",
+		})
+		return false
+	}
+	session, err := auth.ValidateSession(claims.SessionID, user.ID)
+	if err != nil || time.Since(session.CreatedAt) > reauthenticationWindow {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Please log in again to confirm",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UpdateUserRoleRequest 修改用户角色请求结构
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// GetLoginAttempts 管理员查看登录失败记录，支持按用户名、用户 ID 和 IP 筛选
+func GetLoginAttempts(c *gin.Context) {
+	// 分页参数
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 50
+	}
+
+	offset := (page - 1) * pageSize
+
+	query := database.DB.Model(&models.LoginAttempt{})
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var attempts []models.LoginAttempt
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get login attempts",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"attempts": attempts,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// GetRoles 查看所有全局角色及其权限
+func GetRoles(c *gin.Context) {
+	roles := make([]gin.H, 0, len(rbac.Roles()))
+	for _, role := range rbac.Roles() {
+		roles = append(roles, gin.H{
+			"role":        role,
+			"permissions": rbac.Permissions(string(role)),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles": roles,
+	})
+}
+
+// UpdateUserRole 修改用户的全局角色
+func UpdateUserRole(c *gin.Context) {
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID",
+		})
+		return
+	}
+
+	var req UpdateUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+	if !rbac.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid role",
+		})
+		return
+	}
+
+	// 不能修改自己的角色，避免站点失去最后一个管理员
+	if currentID, _ := middleware.GetCurrentUserID(c); currentID == uint(targetID) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Cannot change your own role",
+		})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, targetID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "User not found",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database error",
+			})
+		}
+		return
+	}
+
+	if err := database.DB.Model(&user).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update role",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user.ToJSON(),
+	})
+}
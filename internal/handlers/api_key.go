@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateBotRequest 创建机器人账号请求
+type CreateBotRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Nickname string `json:"nickname" binding:"max=50"`
+}
+
+// CreateAPIKeyRequest 创建 API 密钥请求
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0"` // 0 表示永不过期
+}
+
+// CreateBot 管理员创建机器人账号
+func CreateBot(c *gin.Context) {
+	var req CreateBotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if models.IsReservedUsername(req.Username) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Username is reserved",
+		})
+		return
+	}
+
+	var existingUser models.User
+	if err := database.DB.Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Username already exists",
+		})
+		return
+	}
+
+	nickname := req.Nickname
+	if nickname == "" {
+		nickname = req.Username
+	}
+
+	bot, err := auth.CreateBotUser(req.Username, nickname)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create bot",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"bot": bot.ToJSON(),
+	})
+}
+
+// GetBots 管理员查看所有机器人账号
+func GetBots(c *gin.Context) {
+	var bots []models.User
+	if err := database.DB.Where("is_bot = ?", true).Order("id").Find(&bots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get bots",
+		})
+		return
+	}
+
+	botList := make([]map[string]interface{}, 0, len(bots))
+	for i := range bots {
+		botList = append(botList, bots[i].ToJSON())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bots": botList,
+	})
+}
+
+// CreateAPIKey 管理员为机器人账号创建 API 密钥，密钥明文只在响应中出现一次
+func CreateAPIKey(c *gin.Context) {
+	botID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid bot ID",
+		})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var bot models.User
+	if err := database.DB.Where("is_bot = ?", true).First(&bot, botID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Bot not found",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database error",
+			})
+		}
+		return
+	}
+
+	if _, err := auth.ValidateScopes(req.Scopes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	adminID, _ := middleware.GetCurrentUserID(c)
+	apiKey, key, err := auth.CreateAPIKey(&bot, req.Name, req.Scopes, adminID, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create api key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"api_key": apiKey.ToJSON(),
+		"key":     key,
+	})
+}
+
+// GetAPIKeys 管理员查看 API 密钥，支持按机器人账号筛选
+func GetAPIKeys(c *gin.Context) {
+	query := database.DB.Model(&models.APIKey{})
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var apiKeys []models.APIKey
+	if err := query.Order("created_at DESC").Find(&apiKeys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get api keys",
+		})
+		return
+	}
+
+	keyList := make([]map[string]interface{}, 0, len(apiKeys))
+	for i := range apiKeys {
+		keyList = append(keyList, apiKeys[i].ToJSON())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"api_keys": keyList,
+	})
+}
+
+// RevokeAPIKey 管理员吊销 API 密钥，并断开使用该密钥的 WebSocket 连接
+func RevokeAPIKey(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid api key ID",
+			})
+			return
+		}
+
+		if err := auth.RevokeAPIKey(uint(keyID)); err != nil {
+			if err == auth.ErrAPIKeyNotFound {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "API key not found",
+				})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to revoke api key",
+				})
+			}
+			return
+		}
+
+		hub.DisconnectAPIKey(uint(keyID))
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "API key revoked",
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"gin-chat-room/config"
 	"gin-chat-room/internal/auth"
 	"gin-chat-room/internal/database"
 	"gin-chat-room/internal/middleware"
 	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/passwordhash"
+	"gin-chat-room/internal/services"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -16,7 +21,7 @@ import (
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=50"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 	Nickname string `json:"nickname,omitempty"`
 }
 
@@ -26,10 +31,49 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// RefreshTokenRequest 刷新令牌请求结构
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest 退出登录请求结构
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
 // AuthResponse 认证响应结构
 type AuthResponse struct {
-	Token string                 `json:"token"`
-	User  map[string]interface{} `json:"user"`
+	Token        string                 `json:"token"`
+	RefreshToken string                 `json:"refresh_token"`
+	User         map[string]interface{} `json:"user"`
+}
+
+// canLogIn 判断账号能否登录
+// 机器人只能使用 API 密钥，已注销用户的占位账号和注销宽限期已结束、等待删除的账号不能登录
+func canLogIn(user *models.User) bool {
+	if user.IsBot || user.IsPlaceholder {
+		return false
+	}
+	return user.DeletionScheduledAt == nil || time.Now().Before(*user.DeletionScheduledAt)
+}
+
+// newAuthResponse 为用户创建新的登录会话，签发访问令牌和刷新令牌
+func newAuthResponse(c *gin.Context, user *models.User) (*AuthResponse, error) {
+	// 注销宽限期内重新登录即撤销注销
+	if err := services.CancelAccountDeletion(user); err != nil {
+		return nil, err
+	}
+
+	_, tokens, err := auth.CreateSession(user, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		User:         user.ToJSON(),
+	}, nil
 }
 
 // Register 用户注册
@@ -42,6 +86,32 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// 限制同一 IP 的注册频率，防止批量注册
+	allowed, retryAfter, err := services.AllowRequest("register:"+c.ClientIP(), config.AppConfig.Auth.RegisterIPLimit, time.Hour)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Failed to check rate limit",
+		})
+		return
+	}
+	if !allowed {
+		respondTooManyRequests(c, retryAfter)
+		return
+	}
+
+	if models.IsReservedUsername(req.Username) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Username is reserved",
+		})
+		return
+	}
+
+	// 检查密码策略
+	if err := auth.ValidatePassword(req.Password, req.Username, req.Email); err != nil {
+		respondPasswordPolicyError(c, err)
+		return
+	}
+
 	// 检查用户名是否已存在
 	var existingUser models.User
 	if err := database.DB.Where("username = ? OR email = ?", req.Username, req.Email).First(&existingUser).Error; err == nil {
@@ -74,8 +144,13 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// 发送邮箱验证邮件，发送失败时用户可以稍后重新发送
+	if err := sendVerificationMail(&user); err != nil {
+		log.Printf("Failed to send verification mail to user %d: %v", user.ID, err)
+	}
+
 	// 生成 JWT token
-	token, err := auth.GenerateToken(user.ID, user.Username, user.Email)
+	response, err := newAuthResponse(c, &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to generate token",
@@ -84,10 +159,7 @@ func Register(c *gin.Context) {
 	}
 
 	// 返回响应
-	c.JSON(http.StatusCreated, AuthResponse{
-		Token: token,
-		User:  user.ToJSON(),
-	})
+	c.JSON(http.StatusCreated, response)
 }
 
 // Login 用户登录
@@ -103,31 +175,72 @@ func Login(c *gin.Context) {
 	// 查找用户
 	var user models.User
 	if err := database.DB.Where("username = ? OR email = ?", req.Username, req.Username).First(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid username or password",
-			})
-		} else {
+		if err != gorm.ErrRecordNotFound {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Database error",
 			})
+			return
 		}
+
+		// 用户不存在时同样计入失败次数并验证一次密码，避免通过响应内容或时间枚举用户
+		account := loginAccountKey(nil, req.Username)
+		if !checkLoginLockout(c, account, req.Username, nil) {
+			return
+		}
+		passwordhash.VerifyDummy(req.Password)
+		recordLoginFailure(c, account, req.Username, nil, models.LoginFailureUnknownUser)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid username or password",
+		})
 		return
 	}
 
-	// 验证密码
-	if !user.CheckPassword(req.Password) {
+	// 账号或 IP 失败次数过多时暂时锁定
+	account := loginAccountKey(&user, req.Username)
+	if !checkLoginLockout(c, account, req.Username, &user.ID) {
+		return
+	}
+
+	// 验证密码，不能登录的账号同样验证一次密码，避免通过响应时间区分
+	loginAllowed := canLogIn(&user)
+	if !loginAllowed {
+		passwordhash.VerifyDummy(req.Password)
+	}
+	if !loginAllowed || !user.CheckPassword(req.Password) {
+		recordLoginFailure(c, account, req.Username, &user.ID, models.LoginFailureInvalidPassword)
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "Invalid username or password",
 		})
 		return
 	}
 
+	// 迁移期间旧的 bcrypt 哈希在登录时升级为当前算法
+	auth.RehashPasswordIfNeeded(&user, req.Password)
+
+	// 开启两步验证的用户需要先完成第二步验证
+	if user.TOTPEnabled {
+		challenge, err := newTwoFactorChallenge(&user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to generate token",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	// 完成登录后清除失败计数；开启两步验证时在第二步成功后清除
+	if err := services.ResetLoginFailures(account); err != nil {
+		log.Printf("Failed to reset login failures for user %d: %v", user.ID, err)
+	}
+
 	// 更新用户在线状态
 	database.DB.Model(&user).Updates(models.User{IsOnline: true})
 
 	// 生成 JWT token
-	token, err := auth.GenerateToken(user.ID, user.Username, user.Email)
+	response, err := newAuthResponse(c, &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to generate token",
@@ -136,12 +249,125 @@ func Login(c *gin.Context) {
 	}
 
 	// 返回响应
+	c.JSON(http.StatusOK, response)
+}
+
+// RefreshToken 使用刷新令牌换取新的访问令牌和刷新令牌
+func RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	// 轮换刷新令牌
+	current, refreshToken, err := auth.RotateRefreshToken(req.RefreshToken)
+	if err != nil {
+		switch err {
+		case auth.ErrRefreshTokenReused:
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Refresh token reuse detected, please log in again",
+			})
+		case auth.ErrInvalidRefreshToken, auth.ErrRefreshTokenExpired:
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to refresh token",
+			})
+		}
+		return
+	}
+
+	// 查找用户
+	var user models.User
+	if err := database.DB.First(&user, current.UserID).Error; err != nil {
+		auth.RevokeRefreshTokenFamily(current.FamilyID)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	// 为刷新令牌所属的会话生成新的 JWT token
+	token, err := auth.RefreshSession(&user, current.FamilyID, c.ClientIP())
+	if err != nil {
+		if err == auth.ErrSessionRevoked {
+			auth.RevokeRefreshTokenFamily(current.FamilyID)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Session has been revoked",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate token",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, AuthResponse{
-		Token: token,
-		User:  user.ToJSON(),
+		Token:        token,
+		RefreshToken: refreshToken,
+		User:         user.ToJSON(),
 	})
 }
 
+// Logout 退出登录，吊销当前访问令牌和对应的刷新令牌，并断开当前会话的 WebSocket 连接
+func Logout(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, exists := middleware.GetCurrentClaims(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "User not authenticated",
+			})
+			return
+		}
+
+		// 请求体可选，只有提供刷新令牌时才吊销刷新令牌
+		var req LogoutRequest
+		c.ShouldBindJSON(&req)
+
+		// 将访问令牌加入黑名单
+		if err := services.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to revoke token",
+			})
+			return
+		}
+
+		// 注销当前会话，会话的刷新令牌同时失效
+		if claims.SessionID != "" {
+			if err := auth.RevokeSession(claims.UserID, claims.SessionID); err != nil && err != auth.ErrSessionNotFound {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to revoke session",
+				})
+				return
+			}
+			hub.DisconnectSessions(claims.SessionID)
+		}
+
+		// 吊销刷新令牌
+		if req.RefreshToken != "" {
+			if err := auth.RevokeRefreshToken(req.RefreshToken, claims.UserID); err != nil && err != auth.ErrInvalidRefreshToken {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to revoke refresh token",
+				})
+				return
+			}
+		}
+
+		// 更新用户在线状态
+		database.DB.Model(&models.User{}).Where("id = ?", claims.UserID).Update("is_online", false)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Successfully logged out",
+		})
+	}
+}
+
 // GetProfile 获取用户资料
 func GetProfile(c *gin.Context) {
 	user, exists := middleware.GetCurrentUser(c)
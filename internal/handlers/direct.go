@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateDirectConversationRequest 发起私信请求结构
+type CreateDirectConversationRequest struct {
+	UserIDs []uint `json:"user_ids" binding:"required,min=1"` // 除自己以外的参与者
+}
+
+// CreateDirectConversation 与一名或多名用户发起私信，同一组参与者已有会话时直接返回该会话
+// 私信会话的消息使用房间消息接口和 WebSocket 收发
+func CreateDirectConversation(c *gin.Context) {
+	var req CreateDirectConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	// 去重并排除自己
+	seen := map[uint]bool{user.ID: true}
+	var userIDs []uint
+	for _, id := range req.UserIDs {
+		if !seen[id] {
+			seen[id] = true
+			userIDs = append(userIDs, id)
+		}
+	}
+	if len(userIDs) == 0 || len(userIDs) >= services.MaxDirectParticipants {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "A direct conversation needs 2 to " + strconv.Itoa(services.MaxDirectParticipants) + " participants",
+		})
+		return
+	}
+
+	var others []models.User
+	if err := database.DB.Where("id IN ?", userIDs).Find(&others).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error",
+		})
+		return
+	}
+	if len(others) != len(userIDs) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+	for _, other := range others {
+		if other.IsGuest || other.IsBot || other.IsPlaceholder {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Guests, bots and deleted users cannot join direct conversations",
+			})
+			return
+		}
+	}
+
+	room, created, err := services.FindOrCreateDirectConversation(user, others)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create direct conversation",
+		})
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{
+		"conversation": directConversationJSON(room),
+	})
+}
+
+// GetDirectConversations 分页获取当前用户参与的私信会话，最近有消息的在前
+func GetDirectConversations(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	// 分页参数
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	offset := (page - 1) * pageSize
+
+	query := database.DB.Model(&models.Room{}).
+		Where("is_direct = ? AND id IN (SELECT room_id FROM room_members WHERE user_id = ?)", true, userID)
+
+	// 获取总数
+	var total int64
+	query.Count(&total)
+
+	var rooms []models.Room
+	if err := query.
+		Order("COALESCE((SELECT MAX(created_at) FROM messages WHERE messages.room_id = rooms.id AND messages.deleted_at IS NULL), rooms.created_at) DESC").
+		Offset(offset).Limit(pageSize).Find(&rooms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch direct conversations",
+		})
+		return
+	}
+
+	conversations := make([]map[string]interface{}, 0, len(rooms))
+	for i := range rooms {
+		conversations = append(conversations, directConversationJSON(&rooms[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversations": conversations,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// directConversationJSON 私信会话的房间信息，附带参与者和最后一条消息
+func directConversationJSON(room *models.Room) map[string]interface{} {
+	result := room.ToJSON(database.DB)
+
+	var members []models.RoomMember
+	database.DB.Preload("User").Where("room_id = ?", room.ID).Order("joined_at, id").Find(&members)
+	participants := make([]map[string]interface{}, 0, len(members))
+	for _, member := range members {
+		participants = append(participants, member.User.ToPublicJSON())
+	}
+	result["participants"] = participants
+
+	var lastMessage models.Message
+	if err := database.DB.Preload("User").Where("room_id = ?", room.ID).Order("created_at DESC, id DESC").First(&lastMessage).Error; err == nil {
+		result["last_message"] = lastMessage.ToJSON()
+	} else {
+		result["last_message"] = nil
+	}
+
+	return result
+}
+
+// rejectDirectRoom 私信会话不支持房间管理操作，是私信会话时写入响应并返回 true
+func rejectDirectRoom(c *gin.Context, room *models.Room) bool {
+	if !room.IsDirect {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": "Not supported for direct conversations",
+	})
+	return true
+}
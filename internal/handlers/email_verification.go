@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/mailer"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sendVerificationMail 发送邮箱验证邮件，验证链接中的令牌绑定了当前邮箱
+func sendVerificationMail(user *models.User) error {
+	expire := time.Duration(config.AppConfig.Auth.EmailVerificationExpire) * time.Hour
+	token, err := auth.GeneratePurposeToken(auth.PurposeEmailVerification, user.ID, user.Email, expire)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/v1/auth/email/verify?token=%s", config.AppConfig.Server.BaseURL, url.QueryEscape(token))
+	body := fmt.Sprintf("%s，您好：\n\n感谢注册聊天室，请在 %d 小时内打开以下链接验证您的邮箱：\n\n%s\n\n如果这不是您本人的操作，请忽略此邮件。",
+		user.Nickname, config.AppConfig.Auth.EmailVerificationExpire, link)
+
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "验证您的聊天室邮箱",
+		Body:    body,
+	})
+}
+
+// VerifyEmail 通过邮件中的链接验证邮箱
+func VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Verification token is required",
+		})
+		return
+	}
+
+	claims, err := auth.ParsePurposeToken(auth.PurposeEmailVerification, token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid or expired verification link",
+		})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, claims.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid or expired verification link",
+		})
+		return
+	}
+
+	// 邮箱变更后旧的验证链接失效
+	if user.Email != claims.Value {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid or expired verification link",
+		})
+		return
+	}
+
+	if !user.EmailVerified {
+		now := time.Now()
+		if err := database.DB.Model(&user).Updates(map[string]interface{}{
+			"email_verified":    true,
+			"email_verified_at": &now,
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to verify email",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email verified successfully",
+	})
+}
+
+// ResendVerificationEmail 重新发送邮箱验证邮件
+func ResendVerificationEmail(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	if user.EmailVerified {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Email already verified",
+		})
+		return
+	}
+
+	// 每分钟最多 1 次，每小时最多 5 次
+	limits := []struct {
+		limit  int
+		window time.Duration
+	}{
+		{1, time.Minute},
+		{5, time.Hour},
+	}
+	for _, l := range limits {
+		key := fmt.Sprintf("email_verification:%d:%d", user.ID, int(l.window.Seconds()))
+		allowed, retryAfter, err := services.AllowRequest(key, l.limit, l.window)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "Failed to check rate limit",
+			})
+			return
+		}
+		if !allowed {
+			respondTooManyRequests(c, retryAfter)
+			return
+		}
+	}
+
+	if err := sendVerificationMail(user); err != nil {
+		log.Printf("Failed to send verification mail to user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to send verification email",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Verification email sent",
+	})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateGuestSession 不注册以访客身份进入
+// 为访客生成随机昵称并签发令牌，访客默认只能阅读公开房间，房间开启访客发言后可以发言
+func CreateGuestSession(c *gin.Context) {
+	if !config.AppConfig.Guest.Enabled {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Guest access is disabled",
+		})
+		return
+	}
+
+	// 限制同一 IP 创建访客的频率
+	allowed, retryAfter, err := services.AllowRequest("guest:"+c.ClientIP(), config.AppConfig.Guest.IPLimit, time.Hour)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Failed to check rate limit",
+		})
+		return
+	}
+	if !allowed {
+		respondTooManyRequests(c, retryAfter)
+		return
+	}
+
+	user, err := auth.CreateGuestUser()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create guest",
+		})
+		return
+	}
+
+	response, err := newAuthResponse(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
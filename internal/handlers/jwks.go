@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"gin-chat-room/internal/auth"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS 返回用于验证 JWT 的公钥集合
+func GetJWKS(c *gin.Context) {
+	// 允许其他服务短时间缓存，密钥轮换时新旧公钥会同时存在
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, auth.JWKS())
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginAccountKey 登录限流使用的账号标识
+// 用户存在时按用户 ID 计数，避免用户名和邮箱交替尝试绕过限制
+func loginAccountKey(user *models.User, identifier string) string {
+	if user != nil {
+		return fmt.Sprintf("user:%d", user.ID)
+	}
+	return "name:" + strings.ToLower(strings.TrimSpace(identifier))
+}
+
+// checkLoginLockout 检查账号和 IP 是否被锁定，锁定时返回 429 并返回 false
+func checkLoginLockout(c *gin.Context, account, identifier string, userID *uint) bool {
+	lockout, err := services.LoginLockout(account, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Failed to check rate limit",
+		})
+		return false
+	}
+	if lockout > 0 {
+		recordLoginAttempt(c, identifier, userID, models.LoginFailureLocked)
+		respondTooManyRequests(c, lockout)
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure 记录登录失败并累加账号和 IP 的失败计数
+// 达到锁定阈值时在响应中带上 Retry-After 头
+func recordLoginFailure(c *gin.Context, account, identifier string, userID *uint, reason string) {
+	recordLoginAttempt(c, identifier, userID, reason)
+
+	lockout, err := services.RecordLoginFailure(account, c.ClientIP())
+	if err != nil {
+		log.Printf("Failed to record login failure for %s: %v", account, err)
+		return
+	}
+	if lockout > 0 {
+		c.Header("Retry-After", fmt.Sprint(int(lockout.Seconds())))
+	}
+}
+
+// recordLoginAttempt 保存登录失败记录，供管理员查看
+func recordLoginAttempt(c *gin.Context, identifier string, userID *uint, reason string) {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	if len(identifier) > 100 {
+		identifier = identifier[:100]
+	}
+
+	attempt := models.LoginAttempt{
+		Username:  identifier,
+		UserID:    userID,
+		IP:        c.ClientIP(),
+		UserAgent: userAgent,
+		Reason:    reason,
+	}
+	if err := database.DB.Create(&attempt).Error; err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+}
+
+// confirmPassword 敏感操作前确认当前密码，密码错误计入登录失败次数，防止被盗用的令牌暴力猜测密码
+// 被锁定或密码错误时已写入响应并返回 false
+func confirmPassword(c *gin.Context, user *models.User, password string) bool {
+	account := loginAccountKey(user, user.Username)
+	if !checkLoginLockout(c, account, user.Username, &user.ID) {
+		return false
+	}
+	if !user.CheckPassword(password) {
+		recordLoginFailure(c, account, user.Username, &user.ID, models.LoginFailureInvalidPassword)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Password is incorrect",
+		})
+		return false
+	}
+	return true
+}
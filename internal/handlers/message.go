@@ -4,6 +4,8 @@ import (
 	"gin-chat-room/internal/database"
 	"gin-chat-room/internal/middleware"
 	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
 	"net/http"
 	"strconv"
 
@@ -21,7 +23,7 @@ func GetMessages(c *gin.Context) {
 		return
 	}
 
-	userID, exists := middleware.GetCurrentUserID(c)
+	user, exists := middleware.GetCurrentUser(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "User not authenticated",
@@ -45,7 +47,7 @@ func GetMessages(c *gin.Context) {
 	}
 
 	// 检查权限
-	if room.IsPrivate && room.CreatorID != userID && !room.IsMember(database.DB, userID) {
+	if !rbac.CanViewRoom(user, &room) {
 		c.JSON(http.StatusForbidden, gin.H{
 			"error": "Access denied",
 		})
@@ -94,3 +96,123 @@ func GetMessages(c *gin.Context) {
 		},
 	})
 }
+
+// SendMessageRequest 发送消息请求
+type SendMessageRequest struct {
+	Content string `json:"content" binding:"required,max=2000"`
+}
+
+// SendMessage 通过 REST 接口发送消息，供机器人等无法保持 WebSocket 连接的客户端使用
+func SendMessage(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid room ID",
+			})
+			return
+		}
+
+		var req SendMessageRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		user, exists := middleware.GetCurrentUser(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "User not authenticated",
+			})
+			return
+		}
+
+		// 开启邮箱验证后，未验证邮箱的用户不能发言
+		if middleware.EmailVerificationRequired(user) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Email verification required",
+			})
+			return
+		}
+
+		var room models.Room
+		if err := database.DB.First(&room, roomID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "Room not found",
+				})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Database error",
+				})
+			}
+			return
+		}
+
+		if room.IsArchived() {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Room is archived",
+			})
+			return
+		}
+
+		if !rbac.CanSendMessage(user, &room) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Permission denied: " + string(rbac.PermMessagesSend),
+			})
+			return
+		}
+
+		// 访客和机器人不需要先加入房间，封禁需要单独检查
+		if room.IsBanned(database.DB, user.ID) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "You are banned from this room",
+			})
+			return
+		}
+
+		if user.IsGuest {
+			// 访客不加入房间，发言单独限流
+			allowed, retryAfter, err := services.AllowGuestMessage(user.ID)
+			if err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"error": "Failed to check rate limit",
+				})
+				return
+			}
+			if !allowed {
+				respondTooManyRequests(c, retryAfter)
+				return
+			}
+		} else if !room.IsMember(database.DB, user.ID) {
+			apiKey, _ := middleware.GetCurrentAPIKey(c)
+			if !rbac.CanBotPostWithoutMembership(user, apiKey, &room) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "Not a member of this room",
+				})
+				return
+			}
+		}
+
+		if room.IsMuted(database.DB, user.ID) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "You are muted in this room",
+			})
+			return
+		}
+
+		message, err := services.PostMessage(hub, room.ID, user.ID, req.Content)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to send message",
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": message.ToJSON(),
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/oidc"
+	"gin-chat-room/internal/services"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcStateCookie 保存授权请求状态哈希的 Cookie，回调时校验发起登录的是同一个浏览器
+const oidcStateCookie = "oidc_state"
+
+// OIDCTokenRequest 使用外部登录码兑换令牌请求结构
+type OIDCTokenRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// ListOIDCProviders 获取可用的外部登录提供方
+func ListOIDCProviders(c *gin.Context) {
+	providers := []gin.H{}
+	for _, provider := range oidc.Providers() {
+		providers = append(providers, gin.H{
+			"name":         provider.Name(),
+			"display_name": provider.DisplayName(),
+			"login_url":    "/api/v1/auth/oidc/" + provider.Name() + "/login",
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"providers": providers,
+	})
+}
+
+// OIDCLogin 跳转到外部身份提供方进行授权（授权码 + PKCE）
+func OIDCLogin(c *gin.Context) {
+	provider, err := oidc.GetProvider(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Login provider not found",
+		})
+		return
+	}
+
+	state, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start login",
+		})
+		return
+	}
+	nonce, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start login",
+		})
+		return
+	}
+	verifier, err := oidc.GenerateCodeVerifier()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start login",
+		})
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		log.Printf("Failed to build authorization url for %s: %v", provider.Name(), err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "Login provider is unavailable",
+		})
+		return
+	}
+
+	if err := services.SaveOIDCState(state, services.OIDCState{
+		Provider:     provider.Name(),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start login",
+		})
+		return
+	}
+
+	setOIDCStateCookie(c, auth.HashToken(state), int(services.OIDCStateTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback 处理外部身份提供方的授权回调
+// 登录结果通过跳转回前端页面返回：成功时带上一次性登录码 oidc_login，失败时带上 oidc_error
+func OIDCCallback(c *gin.Context) {
+	provider, err := oidc.GetProvider(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Login provider not found",
+		})
+		return
+	}
+
+	// 状态必须与发起登录的浏览器中保存的一致，防止攻击者让受害者使用攻击者的回调地址登录
+	cookie, err := c.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(auth.HashToken(c.Query("state")))) != 1 {
+		redirectOIDCResult(c, "oidc_error", "Invalid or expired login request, please try again")
+		return
+	}
+	setOIDCStateCookie(c, "", -1)
+
+	state, err := services.ConsumeOIDCState(c.Query("state"))
+	if err != nil || state.Provider != provider.Name() {
+		redirectOIDCResult(c, "oidc_error", "Invalid or expired login request, please try again")
+		return
+	}
+
+	if errorCode := c.Query("error"); errorCode != "" {
+		redirectOIDCResult(c, "oidc_error", "Login was cancelled or denied by the provider")
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		redirectOIDCResult(c, "oidc_error", "Missing authorization code")
+		return
+	}
+
+	identity, err := provider.Exchange(c.Request.Context(), code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("OIDC login with %s failed: %v", provider.Name(), err)
+		redirectOIDCResult(c, "oidc_error", "Failed to verify login with the provider")
+		return
+	}
+
+	user, err := auth.ResolveExternalIdentity(provider.Name(), identity, provider.AutoProvision())
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrExternalEmailMissing),
+			errors.Is(err, auth.ErrExternalEmailNotVerified),
+			errors.Is(err, auth.ErrLocalEmailNotVerified),
+			errors.Is(err, auth.ErrExternalAccountNotFound):
+			redirectOIDCResult(c, "oidc_error", err.Error())
+		default:
+			log.Printf("Failed to resolve %s identity %s: %v", provider.Name(), identity.Subject, err)
+			redirectOIDCResult(c, "oidc_error", "Failed to sign in")
+		}
+		return
+	}
+
+	loginCode, err := services.IssueOIDCLoginCode(user.ID)
+	if err != nil {
+		redirectOIDCResult(c, "oidc_error", "Failed to sign in")
+		return
+	}
+
+	redirectOIDCResult(c, "oidc_login", loginCode)
+}
+
+// setOIDCStateCookie 设置或清除（maxAge 为负数）授权请求状态的 Cookie
+// Cookie 只对当前提供方的登录和回调地址有效，SameSite=Lax 允许从提供方跳转回来时携带
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	path := strings.TrimSuffix(strings.TrimSuffix(c.Request.URL.Path, "/login"), "/callback")
+	secure := strings.HasPrefix(config.AppConfig.Server.BaseURL, "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, value, maxAge, path, "", secure, true)
+}
+
+// redirectOIDCResult 跳转回前端页面并附带外部登录结果
+func redirectOIDCResult(c *gin.Context, key, value string) {
+	params := url.Values{}
+	params.Set(key, value)
+	c.Redirect(http.StatusFound, strings.TrimSuffix(config.AppConfig.Server.BaseURL, "/")+"/?"+params.Encode())
+}
+
+// ExchangeOIDCLoginCode 使用一次性登录码换取访问令牌
+// 响应与密码登录相同，开启两步验证的用户同样需要完成第二步验证
+func ExchangeOIDCLoginCode(c *gin.Context) {
+	var req OIDCTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	userID, err := services.RedeemOIDCLoginCode(req.Code)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid or expired login code",
+		})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	// 与密码登录相同，机器人、占位账号和等待删除的账号不能登录
+	if !canLogIn(&user) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "This account cannot log in",
+		})
+		return
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := newTwoFactorChallenge(&user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to generate token",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	// 更新用户在线状态
+	database.DB.Model(&user).Updates(models.User{IsOnline: true})
+
+	// 生成 JWT token
+	response, err := newAuthResponse(c, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/mailer"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForgotPasswordRequest 忘记密码请求结构
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重置密码请求结构
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// ChangePasswordRequest 修改密码请求结构
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ForgotPassword 发送密码重置邮件
+// 无论邮箱是否存在都返回相同的响应，避免泄露已注册的邮箱
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", strings.TrimSpace(req.Email)).First(&user).Error; err == nil {
+		sendPasswordResetMail(&user)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the email is registered, a password reset link has been sent",
+	})
+}
+
+// sendPasswordResetMail 创建重置令牌并发送重置邮件，失败时只记录日志
+func sendPasswordResetMail(user *models.User) {
+	token, err := auth.CreatePasswordResetToken(user.ID)
+	if err != nil {
+		log.Printf("Failed to create password reset token for user %d: %v", user.ID, err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/?reset_token=%s", config.AppConfig.Server.BaseURL, url.QueryEscape(token))
+	body := fmt.Sprintf("%s，您好：\n\n我们收到了重置您聊天室账号密码的请求，请在 %d 分钟内打开以下链接设置新密码：\n\n%s\n\n如果这不是您本人的操作，请忽略此邮件。",
+		user.Nickname, config.AppConfig.Auth.PasswordResetExpire, link)
+
+	if err := mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "重置您的聊天室密码",
+		Body:    body,
+	}); err != nil {
+		log.Printf("Failed to send password reset mail to user %d: %v", user.ID, err)
+	}
+}
+
+// ResetPassword 使用重置令牌设置新密码，并断开用户所有的 WebSocket 连接
+func ResetPassword(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ResetPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request data: " + err.Error(),
+			})
+			return
+		}
+
+		// 重置密码并使所有已登录的会话失效
+		user, err := auth.ResetPassword(req.Token, req.Password)
+		if err != nil {
+			if err == auth.ErrInvalidResetToken {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+			} else if respondPasswordPolicyError(c, err) {
+				return
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to reset password",
+				})
+			}
+			return
+		}
+
+		// 已建立的连接不会重新校验令牌，需要主动断开
+		hub.DisconnectUser(user.ID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Password has been reset, please log in again",
+		})
+	}
+}
+
+// ChangePassword 修改当前用户的密码
+// 修改后之前签发的所有令牌失效，其他设备需要重新登录，当前设备获得新的登录会话
+func ChangePassword(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := middleware.GetCurrentUser(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "User not found",
+			})
+			return
+		}
+
+		var req ChangePasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request data: " + err.Error(),
+			})
+			return
+		}
+
+		// 当前密码错误计入登录失败次数，防止被盗用的令牌暴力猜测密码
+		account := loginAccountKey(user, user.Username)
+		if !checkLoginLockout(c, account, user.Username, &user.ID) {
+			return
+		}
+
+		if err := auth.ChangePassword(user, req.CurrentPassword, req.NewPassword); err != nil {
+			switch {
+			case err == auth.ErrIncorrectPassword:
+				recordLoginFailure(c, account, user.Username, &user.ID, models.LoginFailureInvalidPassword)
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "Current password is incorrect",
+				})
+			case err == auth.ErrPasswordUnchanged:
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+			default:
+				if !respondPasswordPolicyError(c, err) {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "Failed to change password",
+					})
+				}
+			}
+			return
+		}
+
+		// 断开使用旧令牌建立的 WebSocket 连接
+		hub.DisconnectUser(user.ID)
+
+		response, err := newAuthResponse(c, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to generate token",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// respondPasswordPolicyError 密码不符合策略时返回 400 和所有未通过的规则
+func respondPasswordPolicyError(c *gin.Context, err error) bool {
+	var policyErr *auth.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":      "Password does not meet the policy",
+		"violations": policyErr.Violations,
+	})
+	return true
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondTooManyRequests 返回限流响应并设置 Retry-After 头
+func respondTooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many requests, please try again later",
+		"retry_after": int(math.Ceil(retryAfter.Seconds())),
+	})
+}
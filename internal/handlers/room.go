@@ -4,6 +4,8 @@ import (
 	"gin-chat-room/internal/database"
 	"gin-chat-room/internal/middleware"
 	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
 	"net/http"
 	"strconv"
 	"strings"
@@ -20,6 +22,20 @@ type CreateRoomRequest struct {
 	IsPrivate   bool   `json:"is_private"`
 	Password    string `json:"password,omitempty"`
 	MaxMembers  int    `json:"max_members,omitempty"`
+
+	AllowGuestMessages  bool `json:"allow_guest_messages"`  // 允许访客在公开房间发言
+	RequireJoinApproval bool `json:"require_join_approval"` // 私有房间需要提交加入申请
+}
+
+// UpdateRoomRequest 修改房间请求结构，只修改提供的字段
+type UpdateRoomRequest struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=500"`
+	IsPrivate   *bool   `json:"is_private"` // 改为公开房间时清除房间密码
+	MaxMembers  *int    `json:"max_members" binding:"omitempty,min=1"`
+
+	AllowGuestMessages  *bool `json:"allow_guest_messages"`
+	RequireJoinApproval *bool `json:"require_join_approval"`
 }
 
 // JoinRoomRequest 加入房间请求结构
@@ -46,12 +62,14 @@ func GetRooms(c *gin.Context) {
 	// 搜索参数
 	search := c.Query("search")
 	
-	query := database.DB.Model(&models.Room{}).Preload("Creator")
+	query := database.DB.Model(&models.Room{}).Preload("Creator").Where("archived_at IS NULL AND is_direct = ?", false)
 	
-	// 只显示公开房间，除非用户是房间成员
-	userID, _ := middleware.GetCurrentUserID(c)
-	query = query.Where("is_private = ? OR creator_id = ? OR id IN (SELECT room_id FROM room_members WHERE user_id = ?)", 
-		false, userID, userID)
+	// 只显示公开房间，除非用户是房间成员或有权查看所有私有房间
+	user, _ := middleware.GetCurrentUser(c)
+	if !rbac.Can(user, rbac.PermRoomsViewPrivate) {
+		query = query.Where("is_private = ? OR creator_id = ? OR id IN (SELECT room_id FROM room_members WHERE user_id = ?)",
+			false, user.ID, user.ID)
+	}
 
 	if search != "" {
 		query = query.Where("name ILIKE ? OR description ILIKE ?", "%"+search+"%", "%"+search+"%")
@@ -111,6 +129,9 @@ func CreateRoom(c *gin.Context) {
 		IsPrivate:   req.IsPrivate,
 		MaxMembers:  req.MaxMembers,
 		CreatorID:   userID,
+
+		AllowGuestMessages:  req.AllowGuestMessages,
+		RequireJoinApproval: req.RequireJoinApproval,
 	}
 
 	// 设置默认最大成员数
@@ -120,7 +141,15 @@ func CreateRoom(c *gin.Context) {
 
 	// 如果是私有房间，设置密码
 	if req.IsPrivate && req.Password != "" {
-		room.Password = req.Password // 在生产环境中应该加密密码
+		if !validRoomPassword(c, req.Password) {
+			return
+		}
+		if err := room.SetPassword(req.Password); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to encrypt password",
+			})
+			return
+		}
 	}
 
 	// 保存房间
@@ -173,8 +202,8 @@ func GetRoom(c *gin.Context) {
 	}
 
 	// 检查用户是否有权限查看房间
-	userID, _ := middleware.GetCurrentUserID(c)
-	if room.IsPrivate && room.CreatorID != userID && !room.IsMember(database.DB, userID) {
+	user, _ := middleware.GetCurrentUser(c)
+	if !rbac.CanViewRoom(user, &room) {
 		c.JSON(http.StatusForbidden, gin.H{
 			"error": "Access denied",
 		})
@@ -186,6 +215,184 @@ func GetRoom(c *gin.Context) {
 	})
 }
 
+// UpdateRoom 房间管理员修改房间信息，修改后通知房间内的在线用户
+func UpdateRoom(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpdateRoomRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request data: " + err.Error(),
+			})
+			return
+		}
+
+		room, ok := loadAdministeredRoom(c)
+		if !ok {
+			return
+		}
+
+		if room.IsArchived() {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Room is archived",
+			})
+			return
+		}
+
+		updates := make(map[string]interface{})
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Room name cannot be empty",
+				})
+				return
+			}
+			updates["name"] = name
+		}
+		if req.Description != nil {
+			updates["description"] = strings.TrimSpace(*req.Description)
+		}
+		if req.IsPrivate != nil {
+			updates["is_private"] = *req.IsPrivate
+			if !*req.IsPrivate {
+				updates["password"] = ""
+			}
+		}
+		if req.MaxMembers != nil {
+			if int64(*req.MaxMembers) < room.GetMemberCount(database.DB) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Max members cannot be less than the current member count",
+				})
+				return
+			}
+			updates["max_members"] = *req.MaxMembers
+		}
+		if req.AllowGuestMessages != nil {
+			updates["allow_guest_messages"] = *req.AllowGuestMessages
+		}
+		if req.RequireJoinApproval != nil {
+			updates["require_join_approval"] = *req.RequireJoinApproval
+		}
+
+		if len(updates) > 0 {
+			if err := database.DB.Model(room).Updates(updates).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to update room",
+				})
+				return
+			}
+		}
+
+		database.DB.Preload("Creator").First(room, room.ID)
+		if len(updates) > 0 {
+			services.BroadcastRoomUpdated(hub, room)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"room": room.ToJSON(database.DB),
+		})
+	}
+}
+
+// DeleteRoom 删除房间，房间内的连接收到通知后关闭
+// 只有房间创建者和拥有 rooms:manage 权限的用户可以删除，保留期内可以恢复
+func DeleteRoom(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		room, user, ok := loadRoomAsAdmin(c, rbac.CanManageRoom)
+		if !ok {
+			return
+		}
+
+		if !rbac.CanActAsRoomCreator(user, room) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Only the room creator can delete this room",
+			})
+			return
+		}
+
+		if err := services.DeleteRoom(hub, room); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to delete room",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":          "Room deleted successfully",
+			"restorable_until": services.RoomRestoreDeadline(room),
+		})
+	}
+}
+
+// RestoreRoom 恢复保留期内删除的房间，权限要求与删除房间相同
+func RestoreRoom(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid room ID",
+			})
+			return
+		}
+
+		user, exists := middleware.GetCurrentUser(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "User not authenticated",
+			})
+			return
+		}
+
+		var room models.Room
+		if err := database.DB.Unscoped().First(&room, roomID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "Room not found",
+				})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Database error",
+				})
+			}
+			return
+		}
+
+		if !rbac.CanActAsRoomCreator(user, &room) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Only the room creator can restore this room",
+			})
+			return
+		}
+
+		if !room.DeletedAt.Valid {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Room is not deleted",
+			})
+			return
+		}
+
+		if err := services.RestoreRoom(hub, &room); err != nil {
+			if err == services.ErrRestoreExpired {
+				c.JSON(http.StatusGone, gin.H{
+					"error": "Room can no longer be restored",
+				})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to restore room",
+				})
+			}
+			return
+		}
+
+		database.DB.Preload("Creator").First(&room, room.ID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Room restored successfully",
+			"room":    room.ToJSON(database.DB),
+		})
+	}
+}
+
 // JoinRoom 加入房间
 func JoinRoom(c *gin.Context) {
 	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -196,7 +403,7 @@ func JoinRoom(c *gin.Context) {
 		return
 	}
 
-	userID, exists := middleware.GetCurrentUserID(c)
+	user, exists := middleware.GetCurrentUser(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "User not authenticated",
@@ -222,57 +429,67 @@ func JoinRoom(c *gin.Context) {
 		return
 	}
 
-	// 检查用户是否已经是房间成员
-	if room.IsMember(database.DB, userID) {
-		c.JSON(http.StatusConflict, gin.H{
-			"error": "Already a member of this room",
-		})
+	// 私信会话的参与者在创建时确定
+	if rejectDirectRoom(c, &room) {
 		return
 	}
 
-	// 检查房间是否已满
-	if room.GetMemberCount(database.DB) >= int64(room.MaxMembers) {
+	// 需要审批的私有房间只能通过加入申请或邀请码加入
+	if room.IsPrivate && room.RequireJoinApproval {
 		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Room is full",
+			"error": "This room requires approval to join, submit a join request instead",
 		})
 		return
 	}
 
 	// 如果是私有房间，验证密码
-	if room.IsPrivate && room.Password != "" && room.Password != req.Password {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Invalid password",
-		})
-		return
+	if room.IsPrivate && room.HasPassword() {
+		if !room.CheckPassword(req.Password) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Invalid password",
+			})
+			return
+		}
+		rehashRoomPasswordIfNeeded(&room, req.Password)
 	}
 
 	// 添加用户到房间
-	roomMember := models.RoomMember{
-		RoomID:   uint(roomID),
-		UserID:   userID,
-		Role:     "member",
-		JoinedAt: time.Now(),
-	}
-
-	if err := database.DB.Create(&roomMember).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to join room",
-		})
+	if err := services.JoinRoom(&room, user, "member"); err != nil {
+		respondJoinRoomError(c, err)
 		return
 	}
 
-	// 创建系统消息
-	var user models.User
-	database.DB.First(&user, userID)
-	
-	systemMessage := models.CreateSystemMessage(uint(roomID), user.Nickname+" 加入了房间")
-	database.DB.Create(systemMessage)
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Successfully joined room",
 	})
 }
 
+// respondJoinRoomError 返回加入房间失败的原因
+func respondJoinRoomError(c *gin.Context, err error) {
+	switch err {
+	case services.ErrAlreadyMember:
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Already a member of this room",
+		})
+	case services.ErrRoomFull:
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Room is full",
+		})
+	case services.ErrRoomArchived:
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Room is archived",
+		})
+	case services.ErrBanned:
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You are banned from this room",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to join room",
+		})
+	}
+}
+
 // LeaveRoom 离开房间
 func LeaveRoom(c *gin.Context) {
 	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -309,9 +526,12 @@ func LeaveRoom(c *gin.Context) {
 	// 检查是否是房间创建者
 	var room models.Room
 	database.DB.First(&room, roomID)
+	if rejectDirectRoom(c, &room) {
+		return
+	}
 	if room.CreatorID == userID {
 		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Room creator cannot leave the room",
+			"error": "Room creator cannot leave the room, transfer ownership first",
 		})
 		return
 	}
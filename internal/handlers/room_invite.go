@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateRoomInviteRequest 创建邀请码请求结构
+type CreateRoomInviteRequest struct {
+	Role           string `json:"role" binding:"omitempty,oneof=admin member"` // 加入后的房间角色，默认 member
+	MaxUses        int    `json:"max_uses" binding:"min=0"`                    // 0 表示不限次数
+	ExpiresInHours int    `json:"expires_in_hours" binding:"min=0"`            // 0 表示永不过期
+}
+
+// CreateRoomInvite 房间管理员创建邀请码
+func CreateRoomInvite(c *gin.Context) {
+	var req CreateRoomInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	room, ok := loadAdministeredRoom(c)
+	if !ok {
+		return
+	}
+
+	if room.IsArchived() {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Room is archived",
+		})
+		return
+	}
+
+	role := req.Role
+	if role == "" {
+		role = "member"
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInHours > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		expiresAt = &t
+	}
+
+	userID, _ := middleware.GetCurrentUserID(c)
+	invite, err := services.CreateRoomInvite(room, userID, role, req.MaxUses, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create invite",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"invite": invite.ToJSON(),
+	})
+}
+
+// GetRoomInvites 房间管理员查看房间的邀请码
+func GetRoomInvites(c *gin.Context) {
+	room, ok := loadAdministeredRoom(c)
+	if !ok {
+		return
+	}
+
+	var invites []models.RoomInvite
+	if err := database.DB.Where("room_id = ?", room.ID).Order("created_at DESC").Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch invites",
+		})
+		return
+	}
+
+	inviteList := make([]map[string]interface{}, 0, len(invites))
+	for _, invite := range invites {
+		inviteList = append(inviteList, invite.ToJSON())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invites": inviteList,
+	})
+}
+
+// RevokeRoomInvite 房间管理员吊销邀请码，已加入的成员不受影响
+func RevokeRoomInvite(c *gin.Context) {
+	inviteID, ok := parseInviteID(c)
+	if !ok {
+		return
+	}
+
+	room, ok := loadAdministeredRoom(c)
+	if !ok {
+		return
+	}
+
+	var invite models.RoomInvite
+	if err := database.DB.Where("id = ? AND room_id = ?", inviteID, room.ID).First(&invite).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Invite not found",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database error",
+			})
+		}
+		return
+	}
+
+	if invite.RevokedAt == nil {
+		now := time.Now()
+		if err := database.DB.Model(&invite).Update("revoked_at", &now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to revoke invite",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Invite revoked successfully",
+	})
+}
+
+// GetInvite 预览邀请码对应的房间信息
+func GetInvite(c *gin.Context) {
+	invite, ok := loadUsableInvite(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := middleware.GetCurrentUserID(c)
+	c.JSON(http.StatusOK, gin.H{
+		"invite": gin.H{
+			"code":       invite.Code,
+			"role":       invite.Role,
+			"expires_at": invite.ExpiresAt,
+		},
+		"room":      invite.Room.ToJSON(database.DB),
+		"is_member": invite.Room.IsMember(database.DB, userID),
+	})
+}
+
+// AcceptInvite 使用邀请码加入房间
+func AcceptInvite(c *gin.Context) {
+	invite, ok := loadUsableInvite(c)
+	if !ok {
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	if err := services.AcceptRoomInvite(invite, user); err != nil {
+		if err == services.ErrInvalidInvite {
+			c.JSON(http.StatusGone, gin.H{
+				"error": "Invite is no longer valid",
+			})
+			return
+		}
+		respondJoinRoomError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Successfully joined room",
+		"room":    invite.Room.ToJSON(database.DB),
+	})
+}
+
+// loadUsableInvite 加载路径中的邀请码和房间，邀请码不存在时返回 404，已失效时返回 410
+func loadUsableInvite(c *gin.Context) (*models.RoomInvite, bool) {
+	var invite models.RoomInvite
+	if err := database.DB.Preload("Room").Where("code = ?", c.Param("code")).First(&invite).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Invite not found",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database error",
+			})
+		}
+		return nil, false
+	}
+
+	// 房间被删除后邀请码一并失效
+	if !invite.IsUsable() || invite.Room.ID == 0 {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "Invite is no longer valid",
+		})
+		return nil, false
+	}
+
+	return &invite, true
+}
+
+// parseInviteID 解析路径中的邀请码 ID
+func parseInviteID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("invite_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid invite ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateJoinRequestRequest 提交加入申请请求结构
+type CreateJoinRequestRequest struct {
+	Message string `json:"message" binding:"max=500"`
+}
+
+// CreateJoinRequest 申请加入需要审批的私有房间，在线的房间管理员会实时收到通知
+func CreateJoinRequest(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateJoinRequestRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request data: " + err.Error(),
+			})
+			return
+		}
+
+		room, user, ok := loadRoomForUser(c)
+		if !ok {
+			return
+		}
+
+		if !room.IsPrivate || !room.RequireJoinApproval {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "This room does not accept join requests",
+			})
+			return
+		}
+
+		request, err := services.CreateJoinRequest(hub, room, user, strings.TrimSpace(req.Message))
+		if err != nil {
+			if err == services.ErrJoinRequestPending {
+				c.JSON(http.StatusConflict, gin.H{
+					"error": "You already have a pending join request for this room",
+				})
+			} else {
+				respondJoinRoomError(c, err)
+			}
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"request": request.ToJSON(),
+		})
+	}
+}
+
+// GetJoinRequests 获取房间中等待处理的加入申请，只有房间管理员可以查看
+func GetJoinRequests(c *gin.Context) {
+	room, _, ok := loadRoomAsAdmin(c, rbac.CanModerateRoom)
+	if !ok {
+		return
+	}
+
+	var requests []models.RoomJoinRequest
+	if err := models.PendingJoinRequests(database.DB, room.ID).Preload("User").Order("created_at").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch join requests",
+		})
+		return
+	}
+
+	requestList := make([]map[string]interface{}, 0, len(requests))
+	for _, request := range requests {
+		requestList = append(requestList, request.ToJSON())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"requests": requestList,
+	})
+}
+
+// ApproveJoinRequest 批准加入申请，申请人成为房间成员
+func ApproveJoinRequest(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		room, reviewer, ok := loadRoomAsAdmin(c, rbac.CanModerateRoom)
+		if !ok {
+			return
+		}
+
+		request, ok := loadJoinRequest(c, room)
+		if !ok {
+			return
+		}
+
+		if err := services.ApproveJoinRequest(hub, room, request, reviewer); err != nil {
+			if err == services.ErrJoinRequestClosed {
+				respondJoinRequestClosed(c)
+			} else {
+				respondJoinRoomError(c, err)
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Join request approved",
+			"request": request.ToJSON(),
+		})
+	}
+}
+
+// DenyJoinRequest 拒绝加入申请
+func DenyJoinRequest(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		room, reviewer, ok := loadRoomAsAdmin(c, rbac.CanModerateRoom)
+		if !ok {
+			return
+		}
+
+		request, ok := loadJoinRequest(c, room)
+		if !ok {
+			return
+		}
+
+		if err := services.DenyJoinRequest(hub, room, request, reviewer); err != nil {
+			if err == services.ErrJoinRequestClosed {
+				respondJoinRequestClosed(c)
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to deny join request",
+				})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Join request denied",
+			"request": request.ToJSON(),
+		})
+	}
+}
+
+// loadJoinRequest 加载路径中属于该房间的加入申请，失败时已写入响应
+func loadJoinRequest(c *gin.Context, room *models.Room) (*models.RoomJoinRequest, bool) {
+	requestID, err := strconv.ParseUint(c.Param("request_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request ID",
+		})
+		return nil, false
+	}
+
+	var request models.RoomJoinRequest
+	if err := database.DB.Preload("User").Where("id = ? AND room_id = ?", requestID, room.ID).First(&request).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Join request not found",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database error",
+			})
+		}
+		return nil, false
+	}
+
+	return &request, true
+}
+
+// respondJoinRequestClosed 申请已处理或已过期
+func respondJoinRequestClosed(c *gin.Context) {
+	c.JSON(http.StatusGone, gin.H{
+		"error": "Join request has already been handled or has expired",
+	})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRoomMembers 分页获取房间成员，合并 Hub 中的实时在线状态
+// 支持按房间角色（role）、在线状态（online）筛选和按昵称搜索（search）
+func GetRoomMembers(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		room, user, ok := loadRoomForUser(c)
+		if !ok {
+			return
+		}
+
+		if !rbac.CanViewRoom(user, room) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Access denied",
+			})
+			return
+		}
+
+		// 分页参数
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+		if page < 1 {
+			page = 1
+		}
+		if pageSize < 1 || pageSize > 100 {
+			pageSize = 50
+		}
+
+		offset := (page - 1) * pageSize
+
+		// 连接在当前房间的用户视为在线
+		onlineIDs := hub.OnlineUserIDs(room.ID)
+		online := make(map[uint]bool, len(onlineIDs))
+		for _, id := range onlineIDs {
+			online[id] = true
+		}
+
+		query := database.DB.Model(&models.RoomMember{}).
+			Joins("JOIN users ON users.id = room_members.user_id AND users.deleted_at IS NULL").
+			Where("room_members.room_id = ?", room.ID)
+
+		if role := c.Query("role"); role != "" {
+			if role != "admin" && role != "member" {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Invalid role",
+				})
+				return
+			}
+			query = query.Where("room_members.role = ?", role)
+		}
+
+		if onlineParam := c.Query("online"); onlineParam != "" {
+			wantOnline, err := strconv.ParseBool(onlineParam)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Invalid online filter",
+				})
+				return
+			}
+			switch {
+			case wantOnline && len(onlineIDs) == 0:
+				query = query.Where("1 = 0")
+			case wantOnline:
+				query = query.Where("room_members.user_id IN ?", onlineIDs)
+			case len(onlineIDs) > 0:
+				query = query.Where("room_members.user_id NOT IN ?", onlineIDs)
+			}
+		}
+
+		if search := strings.TrimSpace(c.Query("search")); search != "" {
+			query = query.Where("LOWER(users.nickname) LIKE ?", "%"+strings.ToLower(search)+"%")
+		}
+
+		// 获取总数
+		var total int64
+		query.Count(&total)
+
+		// 管理员在前，其余按加入时间排序
+		var members []models.RoomMember
+		if err := query.Select("room_members.*").Preload("User").
+			Order("CASE WHEN room_members.role = 'admin' THEN 0 ELSE 1 END, room_members.joined_at, room_members.id").
+			Offset(offset).Limit(pageSize).Find(&members).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch members",
+			})
+			return
+		}
+
+		memberList := make([]map[string]interface{}, 0, len(members))
+		for _, member := range members {
+			memberList = append(memberList, map[string]interface{}{
+				"user":        member.User.ToPublicJSON(),
+				"role":        member.Role,
+				"is_creator":  member.UserID == room.CreatorID,
+				"online":      online[member.UserID],
+				"joined_at":   member.JoinedAt,
+				"muted_until": member.MutedUntil,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"members":      memberList,
+			"online_count": len(onlineIDs),
+			"pagination": gin.H{
+				"page":        page,
+				"page_size":   pageSize,
+				"total":       total,
+				"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+			},
+		})
+	}
+}
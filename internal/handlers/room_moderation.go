@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BanMemberRequest 封禁用户请求结构
+type BanMemberRequest struct {
+	Reason         string `json:"reason" binding:"max=500"`
+	ExpiresInHours int    `json:"expires_in_hours" binding:"min=0,max=87600"` // 0 表示永久封禁，最长十年
+}
+
+// MuteMemberRequest 禁言成员请求结构
+type MuteMemberRequest struct {
+	DurationMinutes int `json:"duration_minutes" binding:"required,min=1,max=525600"` // 最长一年
+}
+
+// UpdateMemberRoleRequest 修改成员角色请求结构
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin member"`
+}
+
+// KickMember 将成员移出房间，成员在该房间的 WebSocket 连接立即断开
+func KickMember(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		room, moderator, ok := loadRoomAsAdmin(c, rbac.CanModerateRoom)
+		if !ok {
+			return
+		}
+
+		_, member, ok := loadModerationTarget(c, room, moderator, rbac.CanModerateRoomAdmins, true)
+		if !ok {
+			return
+		}
+
+		if err := services.KickMember(hub, room, member, moderator); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to kick member",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Member kicked successfully",
+		})
+	}
+}
+
+// BanMember 封禁用户，被封禁的用户不能加入、连接房间或在房间发言
+// 可以封禁不是成员的用户，防止其加入房间
+func BanMember(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req BanMemberRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request data: " + err.Error(),
+			})
+			return
+		}
+
+		room, moderator, ok := loadRoomAsAdmin(c, rbac.CanModerateRoom)
+		if !ok {
+			return
+		}
+
+		target, _, ok := loadModerationTarget(c, room, moderator, rbac.CanModerateRoomAdmins, false)
+		if !ok {
+			return
+		}
+
+		var expiresAt *time.Time
+		if req.ExpiresInHours > 0 {
+			t := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+			expiresAt = &t
+		}
+
+		ban, err := services.BanUser(hub, room, target, moderator, req.Reason, expiresAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to ban user",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"ban": ban.ToJSON(),
+		})
+	}
+}
+
+// UnbanMember 解除封禁，解除后用户需要重新加入房间
+func UnbanMember(c *gin.Context) {
+	room, _, ok := loadRoomAsAdmin(c, rbac.CanModerateRoom)
+	if !ok {
+		return
+	}
+
+	userID, ok := parseMemberUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := services.UnbanUser(room, userID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "User is not banned",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to unban user",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User unbanned successfully",
+	})
+}
+
+// GetRoomBans 查看房间中仍然有效的封禁
+func GetRoomBans(c *gin.Context) {
+	room, _, ok := loadRoomAsAdmin(c, rbac.CanModerateRoom)
+	if !ok {
+		return
+	}
+
+	var bans []models.RoomBan
+	if err := models.ActiveRoomBans(database.DB, room.ID).Preload("User").Order("created_at DESC").Find(&bans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch bans",
+		})
+		return
+	}
+
+	banList := make([]map[string]interface{}, 0, len(bans))
+	for _, ban := range bans {
+		banList = append(banList, ban.ToJSON())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bans": banList,
+	})
+}
+
+// MuteMember 禁言成员，到期前成员不能在房间发言
+func MuteMember(c *gin.Context) {
+	var req MuteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	room, moderator, ok := loadRoomAsAdmin(c, rbac.CanModerateRoom)
+	if !ok {
+		return
+	}
+
+	_, member, ok := loadModerationTarget(c, room, moderator, rbac.CanModerateRoomAdmins, true)
+	if !ok {
+		return
+	}
+
+	mutedUntil := time.Now().Add(time.Duration(req.DurationMinutes) * time.Minute)
+	if err := database.DB.Model(member).Update("muted_until", &mutedUntil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to mute member",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Member muted successfully",
+		"muted_until": mutedUntil,
+	})
+}
+
+// UnmuteMember 提前解除禁言
+func UnmuteMember(c *gin.Context) {
+	room, moderator, ok := loadRoomAsAdmin(c, rbac.CanModerateRoom)
+	if !ok {
+		return
+	}
+
+	_, member, ok := loadModerationTarget(c, room, moderator, rbac.CanModerateRoomAdmins, true)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Model(member).Update("muted_until", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to unmute member",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member unmuted successfully",
+	})
+}
+
+// UpdateMemberRole 将成员设为房间管理员或取消管理员
+// 房间管理员可以提升成员，取消其他管理员需要是房间创建者或拥有 rooms:manage 权限
+func UpdateMemberRole(c *gin.Context) {
+	var req UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	room, moderator, ok := loadRoomAsAdmin(c, rbac.CanManageRoom)
+	if !ok {
+		return
+	}
+
+	_, member, ok := loadModerationTarget(c, room, moderator, rbac.CanActAsRoomCreator, true)
+	if !ok {
+		return
+	}
+
+	if member.Role != req.Role {
+		if err := database.DB.Model(member).Update("role", req.Role).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update member role",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member role updated successfully",
+		"role":    req.Role,
+	})
+}
+
+// loadModerationTarget 加载路径中被管理的用户及其成员记录，用户不是成员时成员记录为空
+// 不能管理自己和房间创建者；管理其他房间管理员需要是房间创建者或拥有指定的全局权限
+// 检查失败时已写入响应
+func loadModerationTarget(c *gin.Context, room *models.Room, moderator *models.User, canModerateAdmins func(*models.User, *models.Room) bool, requireMember bool) (*models.User, *models.RoomMember, bool) {
+	userID, ok := parseMemberUserID(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if userID == moderator.ID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Cannot moderate yourself",
+		})
+		return nil, nil, false
+	}
+
+	if userID == room.CreatorID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Cannot moderate the room creator",
+		})
+		return nil, nil, false
+	}
+
+	var target models.User
+	if err := database.DB.First(&target, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "User not found",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database error",
+			})
+		}
+		return nil, nil, false
+	}
+
+	var member models.RoomMember
+	err := database.DB.Where("room_id = ? AND user_id = ?", room.ID, userID).First(&member).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error",
+		})
+		return nil, nil, false
+	}
+	if err == gorm.ErrRecordNotFound {
+		if requireMember {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Not a member of this room",
+			})
+			return nil, nil, false
+		}
+		return &target, nil, true
+	}
+
+	if member.Role == "admin" && !canModerateAdmins(moderator, room) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Only the room creator can moderate room admins",
+		})
+		return nil, nil, false
+	}
+
+	member.User = target
+	return &target, &member, true
+}
+
+// parseMemberUserID 解析路径中的用户ID，解析失败时已写入响应
+func parseMemberUserID(c *gin.Context) (uint, bool) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID",
+		})
+		return 0, false
+	}
+	return uint(userID), true
+}
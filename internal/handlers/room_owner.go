@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TransferRoomRequest 转让房间请求结构
+type TransferRoomRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+// TransferRoom 房间创建者将房间转让给一名成员，对方接受后生效
+func TransferRoom(c *gin.Context) {
+	var req TransferRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	room, user, ok := loadRoomForUser(c)
+	if !ok {
+		return
+	}
+
+	if rejectDirectRoom(c, room) {
+		return
+	}
+
+	if !rbac.IsRoomCreator(user, room) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Only the room creator can transfer ownership",
+		})
+		return
+	}
+
+	if room.IsArchived() {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Room is archived",
+		})
+		return
+	}
+
+	if req.UserID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Cannot transfer ownership to yourself",
+		})
+		return
+	}
+
+	target, ok := loadNewRoomOwner(c, req.UserID)
+	if !ok {
+		return
+	}
+
+	transfer, err := services.NominateRoomOwner(room, target)
+	if err != nil {
+		if err == services.ErrNotRoomMember {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "User is not a member of this room",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to create ownership transfer",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"transfer": transfer.ToJSON(),
+	})
+}
+
+// GetRoomTransfer 查看房间待接受的转让，房间创建者、被指定的成员和拥有 rooms:manage 权限的用户可以查看
+func GetRoomTransfer(c *gin.Context) {
+	room, user, ok := loadRoomForUser(c)
+	if !ok {
+		return
+	}
+
+	transfer, ok := loadPendingTransfer(c, room)
+	if !ok {
+		return
+	}
+
+	if user.ID != transfer.FromUserID && user.ID != transfer.ToUserID && !rbac.Can(user, rbac.PermRoomsManage) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Access denied",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfer": transfer.ToJSON(),
+	})
+}
+
+// CancelRoomTransfer 房间创建者撤销转让，或被指定的成员拒绝转让
+func CancelRoomTransfer(c *gin.Context) {
+	room, user, ok := loadRoomForUser(c)
+	if !ok {
+		return
+	}
+
+	transfer, ok := loadPendingTransfer(c, room)
+	if !ok {
+		return
+	}
+
+	if !rbac.IsRoomCreator(user, room) && user.ID != transfer.ToUserID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Only the room creator or the nominated member can cancel this transfer",
+		})
+		return
+	}
+
+	if err := database.DB.Delete(transfer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to cancel ownership transfer",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ownership transfer cancelled",
+	})
+}
+
+// AcceptRoomTransfer 被指定的成员接受转让，成为房间创建者，原创建者保留管理员角色
+func AcceptRoomTransfer(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		room, user, ok := loadRoomForUser(c)
+		if !ok {
+			return
+		}
+
+		transfer, ok := loadPendingTransfer(c, room)
+		if !ok {
+			return
+		}
+
+		if user.ID != transfer.ToUserID {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Only the nominated member can accept this transfer",
+			})
+			return
+		}
+
+		room, err := services.AcceptRoomOwnership(hub, transfer, user)
+		if err != nil {
+			switch err {
+			case services.ErrTransferInvalid:
+				c.JSON(http.StatusGone, gin.H{
+					"error": "Ownership transfer is no longer valid",
+				})
+			case services.ErrNotRoomMember:
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "Not a member of this room",
+				})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to transfer ownership",
+				})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Ownership transferred successfully",
+			"room":    room.ToJSON(database.DB),
+		})
+	}
+}
+
+// AssignRoomOwner 管理员直接指定房间创建者，用于原创建者已离开或无法操作的房间
+func AssignRoomOwner(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TransferRoomRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request data: " + err.Error(),
+			})
+			return
+		}
+
+		room, _, ok := loadRoomForUser(c)
+		if !ok || rejectDirectRoom(c, room) {
+			return
+		}
+
+		target, ok := loadNewRoomOwner(c, req.UserID)
+		if !ok {
+			return
+		}
+
+		if err := services.AssignRoomOwner(hub, room, target); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update room owner",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Room owner updated successfully",
+			"room":    room.ToJSON(database.DB),
+		})
+	}
+}
+
+// loadNewRoomOwner 加载新的房间创建者，机器人、访客和已注销或等待注销的账号不能成为创建者，失败时已写入响应
+func loadNewRoomOwner(c *gin.Context, userID uint) (*models.User, bool) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "User not found",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database error",
+			})
+		}
+		return nil, false
+	}
+
+	if user.IsBot || user.IsGuest {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Bots and guests cannot own rooms",
+		})
+		return nil, false
+	}
+
+	// 已注销用户的占位账号和等待注销的账号同样不能接管房间
+	if user.IsPlaceholder || user.DeletionScheduledAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Deleted accounts cannot own rooms",
+		})
+		return nil, false
+	}
+
+	return &user, true
+}
+
+// loadPendingTransfer 加载房间待接受且未过期的转让，失败时已写入响应
+func loadPendingTransfer(c *gin.Context, room *models.Room) (*models.RoomOwnershipTransfer, bool) {
+	var transfer models.RoomOwnershipTransfer
+	err := database.DB.Where("room_id = ?", room.ID).First(&transfer).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error",
+		})
+		return nil, false
+	}
+	if err == gorm.ErrRecordNotFound || transfer.IsExpired() {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "No pending ownership transfer",
+		})
+		return nil, false
+	}
+
+	return &transfer, true
+}
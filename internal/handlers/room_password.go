@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/passwordhash"
+	"gin-chat-room/internal/rbac"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UpdateRoomPasswordRequest 设置房间密码请求结构
+type UpdateRoomPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// UpdateRoomPassword 设置或修改私有房间的密码
+func UpdateRoomPassword(c *gin.Context) {
+	var req UpdateRoomPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	room, ok := loadAdministeredRoom(c)
+	if !ok {
+		return
+	}
+
+	if !room.IsPrivate {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Only private rooms can have a password",
+		})
+		return
+	}
+
+	if !validRoomPassword(c, req.Password) {
+		return
+	}
+	if err := room.SetPassword(req.Password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to encrypt password",
+		})
+		return
+	}
+	if err := database.DB.Model(room).Update("password", room.Password).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update room password",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Room password updated successfully",
+	})
+}
+
+// RemoveRoomPassword 取消房间密码，之后加入私有房间不再需要密码
+func RemoveRoomPassword(c *gin.Context) {
+	room, ok := loadAdministeredRoom(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Model(room).Update("password", "").Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to remove room password",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Room password removed successfully",
+	})
+}
+
+// loadAdministeredRoom 加载路径中的房间，并检查当前用户是房间管理员或拥有 rooms:manage 权限
+// 检查失败时已写入响应
+func loadAdministeredRoom(c *gin.Context) (*models.Room, bool) {
+	room, _, ok := loadRoomAsAdmin(c, rbac.CanManageRoom)
+	return room, ok
+}
+
+// loadRoomAsAdmin 加载路径中的房间和当前用户，并使用 allowed 检查当前用户的房间权限
+// 私信会话不支持房间管理操作，检查失败时已写入响应
+func loadRoomAsAdmin(c *gin.Context, allowed func(*models.User, *models.Room) bool) (*models.Room, *models.User, bool) {
+	room, user, ok := loadRoomForUser(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if !allowed(user, room) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Only room admins can manage this room",
+		})
+		return nil, nil, false
+	}
+
+	if rejectDirectRoom(c, room) {
+		return nil, nil, false
+	}
+
+	return room, user, true
+}
+
+// loadRoomForUser 加载路径中的房间和当前用户，失败时已写入响应
+func loadRoomForUser(c *gin.Context) (*models.Room, *models.User, bool) {
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid room ID",
+		})
+		return nil, nil, false
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return nil, nil, false
+	}
+
+	var room models.Room
+	if err := database.DB.First(&room, roomID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Room not found",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database error",
+			})
+		}
+		return nil, nil, false
+	}
+
+	return &room, user, true
+}
+
+// validRoomPassword 检查房间密码长度，bcrypt 只使用前 72 个字节
+func validRoomPassword(c *gin.Context, password string) bool {
+	if limit := passwordhash.MaxPasswordBytes(); limit > 0 && len(password) > limit {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Room password is too long",
+		})
+		return false
+	}
+	return true
+}
+
+// rehashRoomPasswordIfNeeded 验证通过后将旧算法或旧参数生成的房间密码哈希升级为当前配置
+func rehashRoomPasswordIfNeeded(room *models.Room, password string) {
+	if !room.PasswordNeedsRehash() {
+		return
+	}
+
+	oldHash := room.Password
+	if err := room.SetPassword(password); err != nil {
+		log.Printf("Failed to rehash password of room %d: %v", room.ID, err)
+		return
+	}
+	// 只在哈希未被修改时更新，避免覆盖并发设置的新密码
+	database.DB.Model(&models.Room{}).Where("id = ? AND password = ?", room.ID, oldHash).Update("password", room.Password)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSessions 获取当前用户所有有效的登录会话
+func GetSessions(c *gin.Context) {
+	claims, exists := middleware.GetCurrentClaims(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	sessions, err := auth.ListSessions(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get sessions",
+		})
+		return
+	}
+
+	var result []gin.H
+	for _, session := range sessions {
+		result = append(result, gin.H{
+			"id":           session.ID,
+			"user_agent":   session.UserAgent,
+			"ip":           session.IP,
+			"created_at":   session.CreatedAt,
+			"last_used_at": session.LastUsedAt,
+			"expires_at":   session.ExpiresAt,
+			"current":      session.ID == claims.SessionID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": result,
+	})
+}
+
+// RevokeSession 注销指定的登录会话，并断开该会话的 WebSocket 连接
+func RevokeSession(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := middleware.GetCurrentUserID(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "User not authenticated",
+			})
+			return
+		}
+
+		sessionID := c.Param("id")
+		if err := auth.RevokeSession(userID, sessionID); err != nil {
+			if err == auth.ErrSessionNotFound {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "Session not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to revoke session",
+			})
+			return
+		}
+
+		hub.DisconnectSessions(sessionID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Session revoked",
+		})
+	}
+}
+
+// RevokeOtherSessions 注销当前会话以外的所有登录会话（在其他设备上退出登录）
+func RevokeOtherSessions(hub *services.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, exists := middleware.GetCurrentClaims(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "User not authenticated",
+			})
+			return
+		}
+
+		revoked, err := auth.RevokeOtherSessions(claims.UserID, claims.SessionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to revoke sessions",
+			})
+			return
+		}
+
+		hub.DisconnectSessions(revoked...)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Other sessions revoked",
+			"revoked": len(revoked),
+		})
+	}
+}
@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// twoFactorChallengeTTL 登录第二步验证的有效期
+const twoFactorChallengeTTL = 5 * time.Minute
+
+// twoFactorIssuer 验证器应用中显示的服务名称
+const twoFactorIssuer = "GinChatRoom"
+
+// EnrollTwoFactorRequest 开始启用两步验证请求结构
+type EnrollTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// TwoFactorCodeRequest 两步验证码请求结构
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest 关闭两步验证请求结构
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// TwoFactorLoginRequest 登录第二步验证请求结构
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP 验证码或恢复码
+}
+
+// EnrollTwoFactor 开始启用两步验证，需要确认密码，生成待确认的 TOTP 密钥
+func EnrollTwoFactor(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	var req EnrollTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Two-factor authentication is already enabled",
+		})
+		return
+	}
+
+	if !confirmPassword(c, user, req.Password) {
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate secret",
+		})
+		return
+	}
+
+	// 数据库中只保存加密后的密钥
+	encrypted, err := auth.EncryptTOTPSecret(secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save secret",
+		})
+		return
+	}
+
+	if err := database.DB.Model(user).Update("totp_secret", encrypted).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save secret",
+		})
+		return
+	}
+
+	// 手动输入时按 4 位分组，便于核对
+	var groups []string
+	for i := 0; i < len(secret); i += 4 {
+		end := i + 4
+		if end > len(secret) {
+			end = len(secret)
+		}
+		groups = append(groups, secret[i:end])
+	}
+
+	// otpauth_uri 即二维码内容，客户端可以直接生成二维码
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"manual_entry_key": strings.Join(groups, " "),
+		"otpauth_uri":      auth.TOTPURI(twoFactorIssuer, user.Username, secret),
+	})
+}
+
+// ConfirmTwoFactor 使用验证码确认启用两步验证，返回一次性恢复码
+func ConfirmTwoFactor(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Two-factor authentication is already enabled",
+		})
+		return
+	}
+
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Two-factor enrollment has not been started",
+		})
+		return
+	}
+
+	secret, err := auth.DecryptTOTPSecret(user.TOTPSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify code",
+		})
+		return
+	}
+
+	// 确认时只接受 TOTP 验证码
+	if _, ok := auth.ValidateTOTP(secret, req.Code, time.Now()); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid verification code",
+		})
+		return
+	}
+
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"totp_enabled":   true,
+		"totp_last_step": auth.TOTPStep(time.Now()),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to enable two-factor authentication",
+		})
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate recovery codes",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor 关闭两步验证，需要密码和验证码（或恢复码）
+func DisableTwoFactor(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Two-factor authentication is not enabled",
+		})
+		return
+	}
+
+	if !confirmPassword(c, user, req.Password) {
+		return
+	}
+
+	ok, err := auth.VerifyTwoFactorCode(user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify code",
+		})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid verification code",
+		})
+		return
+	}
+
+	if err := auth.DisableTwoFactor(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to disable two-factor authentication",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，之前的恢复码全部作废
+func RegenerateRecoveryCodes(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Two-factor authentication is not enabled",
+		})
+		return
+	}
+
+	ok, err := auth.VerifyTwoFactorCode(user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify code",
+		})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid verification code",
+		})
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate recovery codes",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+// newTwoFactorChallenge 生成登录第二步验证的挑战令牌
+func newTwoFactorChallenge(user *models.User) (gin.H, error) {
+	challengeToken, err := auth.GeneratePurposeToken(auth.PurposeTwoFactorChallenge, user.ID, "", twoFactorChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"two_factor_required": true,
+		"challenge_token":     challengeToken,
+		"expires_in":          int(twoFactorChallengeTTL.Seconds()),
+	}, nil
+}
+
+// VerifyTwoFactorLogin 使用挑战令牌和验证码完成登录
+func VerifyTwoFactorLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	claims, err := auth.ParsePurposeToken(auth.PurposeTwoFactorChallenge, req.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid or expired challenge, please log in again",
+		})
+		return
+	}
+
+	// 每个挑战令牌最多尝试 5 次
+	allowed, retryAfter, err := services.AllowRequest("two_factor_challenge:"+claims.ID, 5, twoFactorChallengeTTL)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Failed to check rate limit",
+		})
+		return
+	}
+	if !allowed {
+		respondTooManyRequests(c, retryAfter)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, claims.UserID).Error; err != nil || !user.TOTPEnabled || !canLogIn(&user) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid or expired challenge, please log in again",
+		})
+		return
+	}
+
+	// 第二步验证与密码共用账号的失败计数和锁定
+	account := loginAccountKey(&user, user.Username)
+	if !checkLoginLockout(c, account, user.Username, &user.ID) {
+		return
+	}
+
+	// 挑战令牌只能成功使用一次，验证前先原子认领，避免并发请求重复登录
+	claimed, err := services.ClaimToken(claims.ID, claims.ExpiresAt.Time)
+	if err != nil || !claimed {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid or expired challenge, please log in again",
+		})
+		return
+	}
+
+	ok, err := auth.VerifyTwoFactorCode(&user, req.Code)
+	if err != nil || !ok {
+		// 验证失败时释放挑战令牌，允许在尝试次数内重试
+		if err := services.ReleaseToken(claims.ID); err != nil {
+			log.Printf("Failed to release two-factor challenge for user %d: %v", user.ID, err)
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify code",
+		})
+		return
+	}
+	if !ok {
+		recordLoginFailure(c, account, user.Username, &user.ID, models.LoginFailureInvalidCode)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid verification code",
+		})
+		return
+	}
+
+	if err := services.ResetLoginFailures(account); err != nil {
+		log.Printf("Failed to reset login failures for user %d: %v", user.ID, err)
+	}
+
+	// 更新用户在线状态
+	database.DB.Model(&user).Updates(models.User{IsOnline: true})
+
+	// 生成 JWT token
+	response, err := newAuthResponse(c, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
 	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
 	"gin-chat-room/internal/services"
 	"gin-chat-room/internal/websocket"
 	"log"
@@ -10,20 +14,109 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	gorillaws "github.com/gorilla/websocket"
 )
 
+// webSocketTokenProtocol 通过 Sec-WebSocket-Protocol 传递 token 时使用的子协议名
+// 客户端写法：new WebSocket(url, ["access_token", token])
+const webSocketTokenProtocol = "access_token"
+
+// CreateWebSocketTicket 签发一次性的 WebSocket 连接票据
+func CreateWebSocketTicket(c *gin.Context) {
+	// AuthMiddleware 已经校验过 Authorization 头
+	accessToken, _ := middleware.BearerToken(c.GetHeader("Authorization"))
+
+	ticket, err := services.IssueWebSocketTicket(accessToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to issue websocket ticket",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"ticket":     ticket,
+		"expires_in": int(services.WebSocketTicketTTL.Seconds()),
+	})
+}
+
+// resolveWebSocketToken 从握手请求中解析访问令牌或 API 密钥
+// 依次支持一次性票据、Sec-WebSocket-Protocol 头、Authorization 头和 X-API-Key 头
+// 不接受 token 查询参数，避免访问令牌出现在访问日志中
+// 通过子协议传递 token 时返回需要在握手响应中回传的子协议名
+func resolveWebSocketToken(c *gin.Context) (string, string, *middleware.AuthError) {
+	if ticket := c.Query("ticket"); ticket != "" {
+		accessToken, err := services.RedeemWebSocketTicket(ticket)
+		if err == services.ErrInvalidWebSocketTicket {
+			return "", "", &middleware.AuthError{Status: http.StatusUnauthorized, Message: err.Error()}
+		}
+		if err != nil {
+			return "", "", &middleware.AuthError{Status: http.StatusServiceUnavailable, Message: "Failed to verify websocket ticket"}
+		}
+		return accessToken, "", nil
+	}
+
+	protocols := gorillaws.Subprotocols(c.Request)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == webSocketTokenProtocol {
+			return protocols[i+1], webSocketTokenProtocol, nil
+		}
+	}
+
+	if token, ok := middleware.BearerToken(c.GetHeader("Authorization")); ok {
+		return token, "", nil
+	}
+
+	if apiKey := c.GetHeader(middleware.APIKeyHeader); apiKey != "" {
+		return apiKey, "", nil
+	}
+
+	return "", "", &middleware.AuthError{Status: http.StatusUnauthorized, Message: "Authentication credentials are required"}
+}
+
 // HandleWebSocket 处理 WebSocket 连接
 func HandleWebSocket(hub *services.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 获取当前用户
-		userID, exists := middleware.GetCurrentUserID(c)
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "User not authenticated",
+		// 在升级连接之前完成认证
+		tokenString, subprotocol, authErr := resolveWebSocketToken(c)
+		if authErr != nil {
+			c.JSON(authErr.Status, gin.H{
+				"error": authErr.Message,
 			})
 			return
 		}
 
+		// 机器人使用 API 密钥连接，其他用户使用访问令牌
+		var (
+			user      *models.User
+			apiKey    *models.APIKey
+			sessionID string
+		)
+		if auth.IsAPIKey(tokenString) {
+			apiKey, user, authErr = middleware.AuthenticateAPIKey(tokenString)
+			if authErr != nil {
+				c.JSON(authErr.Status, gin.H{
+					"error": authErr.Message,
+				})
+				return
+			}
+			middleware.SetCurrentAPIKey(c, apiKey, user)
+		} else {
+			var claims *auth.Claims
+			claims, user, authErr = middleware.Authenticate(tokenString)
+			if authErr != nil {
+				c.JSON(authErr.Status, gin.H{
+					"error": authErr.Message,
+				})
+				return
+			}
+			middleware.SetCurrentUser(c, claims, user)
+			sessionID = claims.SessionID
+		}
+
+		// 获取当前用户
+		userID := user.ID
+
 		// 获取房间ID
 		roomIDStr := c.Query("room_id")
 		if roomIDStr == "" {
@@ -38,8 +131,43 @@ func HandleWebSocket(hub *services.Hub) gin.HandlerFunc {
 			return
 		}
 
+		// API 密钥需要拥有房间的读取权限才能接收推送
+		if apiKey != nil && !apiKey.Allows(models.ScopeMessagesRead, uint(roomID)) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "API key is missing required scope: " + models.ScopeMessagesRead,
+			})
+			return
+		}
+
+		// 只能接收有权查看的房间的消息
+		var room models.Room
+		if err := database.DB.First(&room, roomID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Room not found",
+			})
+			return
+		}
+		if !rbac.Can(user, rbac.PermMessagesRead) || !rbac.CanViewRoom(user, &room) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Access denied",
+			})
+			return
+		}
+		if room.IsBanned(database.DB, user.ID) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "You are banned from this room",
+			})
+			return
+		}
+
+		// 通过子协议传递 token 时，握手响应必须回传选中的子协议
+		var responseHeader http.Header
+		if subprotocol != "" {
+			responseHeader = http.Header{"Sec-WebSocket-Protocol": {subprotocol}}
+		}
+
 		// 升级到 WebSocket 连接
-		conn, err := websocket.NewConnection(c.Writer, c.Request)
+		conn, err := websocket.NewConnection(c.Writer, c.Request, responseHeader)
 		if err != nil {
 			log.Printf("WebSocket upgrade error: %v", err)
 			return
@@ -47,12 +175,14 @@ func HandleWebSocket(hub *services.Hub) gin.HandlerFunc {
 
 		// 创建客户端
 		client := &services.Client{
-			ID:     uuid.New().String(),
-			UserID: userID,
-			RoomID: uint(roomID),
-			Conn:   conn,
-			Send:   make(chan []byte, 256),
-			Hub:    hub,
+			ID:        uuid.New().String(),
+			UserID:    userID,
+			RoomID:    uint(roomID),
+			SessionID: sessionID,
+			APIKey:    apiKey,
+			Conn:      conn,
+			Send:      make(chan []byte, 256),
+			Hub:       hub,
 		}
 
 		// 注册客户端
@@ -0,0 +1,112 @@
+package mailer
+
+import (
+	"fmt"
+	"gin-chat-room/config"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message 邮件内容
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送接口
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// DefaultMailer 全局邮件发送器
+var DefaultMailer Mailer = &LogMailer{}
+
+// InitMailer 根据配置初始化邮件发送器
+func InitMailer() error {
+	cfg := config.AppConfig.Mail
+
+	switch cfg.Driver {
+	case "smtp":
+		DefaultMailer = &SMTPMailer{
+			Host:     cfg.Host,
+			Port:     cfg.Port,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			From:     cfg.From,
+		}
+	case "log", "":
+		DefaultMailer = &LogMailer{Path: cfg.FilePath}
+	default:
+		return fmt.Errorf("unsupported mail driver: %s", cfg.Driver)
+	}
+
+	return nil
+}
+
+// Send 使用全局邮件发送器发送邮件
+func Send(msg Message) error {
+	return DefaultMailer.Send(msg)
+}
+
+// SMTPMailer 通过 SMTP 服务器发送邮件
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send 发送邮件
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	headers := []string{
+		"From: " + m.From,
+		"To: " + msg.To,
+		"Subject: " + msg.Subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	body := strings.Join(headers, "\r\n") + "\r\n\r\n" + msg.Body
+
+	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+	return smtp.SendMail(addr, auth, m.From, []string{msg.To}, []byte(body))
+}
+
+// LogMailer 将邮件写入文件或日志，用于本地开发和测试
+// Path 为空时输出到标准日志
+type LogMailer struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+// Send 记录邮件
+func (m *LogMailer) Send(msg Message) error {
+	entry := fmt.Sprintf("[%s] To: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+
+	if m.Path == "" {
+		log.Print("Mail: " + entry)
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	file, err := os.OpenFile(m.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(entry)
+	return err
+}
@@ -1,18 +1,52 @@
 package middleware
 
 import (
+	"gin-chat-room/config"
 	"gin-chat-room/internal/auth"
 	"gin-chat-room/internal/database"
 	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware JWT 认证中间件
-func AuthMiddleware() gin.HandlerFunc {
+// APIKeyHeader 传递 API 密钥的请求头，也可以使用 Authorization: Bearer <key>
+const APIKeyHeader = "X-API-Key"
+
+// authOptions 认证中间件选项
+type authOptions struct {
+	acceptAPIKeys bool
+}
+
+// AuthOption 认证中间件选项
+type AuthOption func(*authOptions)
+
+// AcceptAPIKeys 允许机器人账号使用 API 密钥访问
+// 需要同时使用 RequireScope 限制密钥的权限范围
+func AcceptAPIKeys() AuthOption {
+	return func(o *authOptions) {
+		o.acceptAPIKeys = true
+	}
+}
+
+// AuthMiddleware JWT 认证中间件，默认拒绝 API 密钥
+func AuthMiddleware(options ...AuthOption) gin.HandlerFunc {
+	var opts authOptions
+	for _, option := range options {
+		option(&opts)
+	}
+
 	return func(c *gin.Context) {
+		// API 密钥可以放在专用请求头中
+		if apiKey := c.GetHeader(APIKeyHeader); apiKey != "" {
+			authenticateAPIKey(c, apiKey, opts)
+			return
+		}
+
 		// 从 Header 中获取 token
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -24,8 +58,8 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// 检查 Bearer 前缀
-		tokenParts := strings.SplitN(authHeader, " ", 2)
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		tokenString, ok := BearerToken(authHeader)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid authorization header format",
 			})
@@ -33,32 +67,212 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := tokenParts[1]
+		if auth.IsAPIKey(tokenString) {
+			authenticateAPIKey(c, tokenString, opts)
+			return
+		}
 
-		// 解析 token
-		claims, err := auth.ParseToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid token: " + err.Error(),
+		// 校验 token 并加载用户
+		claims, user, authErr := Authenticate(tokenString)
+		if authErr != nil {
+			c.JSON(authErr.Status, gin.H{
+				"error": authErr.Message,
 			})
 			c.Abort()
 			return
 		}
 
-		// 验证用户是否存在
-		var user models.User
-		if err := database.DB.First(&user, claims.UserID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "User not found",
+		// 将用户信息存储到上下文中
+		SetCurrentUser(c, claims, user)
+
+		c.Next()
+	}
+}
+
+// authenticateAPIKey 使用 API 密钥完成认证
+func authenticateAPIKey(c *gin.Context, key string, opts authOptions) {
+	if !opts.acceptAPIKeys {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "API keys are not accepted for this endpoint",
+		})
+		c.Abort()
+		return
+	}
+
+	apiKey, user, authErr := AuthenticateAPIKey(key)
+	if authErr != nil {
+		c.JSON(authErr.Status, gin.H{
+			"error": authErr.Message,
+		})
+		c.Abort()
+		return
+	}
+
+	SetCurrentAPIKey(c, apiKey, user)
+
+	c.Next()
+}
+
+// BearerToken 从 Authorization 头中提取 Bearer token
+func BearerToken(authHeader string) (string, bool) {
+	tokenParts := strings.SplitN(authHeader, " ", 2)
+	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" || tokenParts[1] == "" {
+		return "", false
+	}
+	return tokenParts[1], true
+}
+
+// AuthError 认证失败错误，携带需要返回的 HTTP 状态码
+type AuthError struct {
+	Status  int
+	Message string
+}
+
+// Error 实现 error 接口
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+// Authenticate 校验访问令牌并加载对应的用户
+// 供无法使用 AuthMiddleware 的场景（如 WebSocket 握手）复用
+func Authenticate(tokenString string) (*auth.Claims, *models.User, *AuthError) {
+	// 解析 token
+	claims, err := auth.ParseToken(tokenString)
+	if err != nil {
+		return nil, nil, &AuthError{Status: http.StatusUnauthorized, Message: "Invalid token: " + err.Error()}
+	}
+
+	// 旧版本签发的 token 没有 jti，在原有的有效期内继续接受，避免升级后所有用户被迫重新登录
+	// 以 token 的哈希代替 jti，退出登录时同样可以吊销
+	if claims.ID == "" {
+		if claims.ExpiresAt == nil {
+			return nil, nil, &AuthError{Status: http.StatusUnauthorized, Message: "Invalid token: missing token ID"}
+		}
+		claims.ID = "legacy:" + auth.HashToken(tokenString)
+	}
+
+	// 检查 token 是否已被吊销
+	revoked, err := services.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, nil, &AuthError{Status: http.StatusServiceUnavailable, Message: "Failed to verify token status"}
+	}
+	if revoked {
+		return nil, nil, &AuthError{Status: http.StatusUnauthorized, Message: "Token has been revoked"}
+	}
+
+	// 验证用户是否存在
+	var user models.User
+	if err := database.DB.First(&user, claims.UserID).Error; err != nil {
+		return nil, nil, &AuthError{Status: http.StatusUnauthorized, Message: "User not found"}
+	}
+
+	// 检查 token 是否签发于修改或重置密码之前
+	if claims.TokenVersion != user.TokenVersion {
+		return nil, nil, &AuthError{Status: http.StatusUnauthorized, Message: "Token has been revoked"}
+	}
+
+	// 检查 token 所属的登录会话是否已被注销，同时更新会话的最近使用时间
+	if claims.SessionID != "" {
+		if _, err := auth.ValidateSession(claims.SessionID, user.ID); err != nil {
+			if err == auth.ErrSessionNotFound || err == auth.ErrSessionRevoked {
+				return nil, nil, &AuthError{Status: http.StatusUnauthorized, Message: "Session has been revoked"}
+			}
+			return nil, nil, &AuthError{Status: http.StatusInternalServerError, Message: "Failed to check session"}
+		}
+	}
+
+	return claims, &user, nil
+}
+
+// AuthenticateAPIKey 校验 API 密钥并加载所属的机器人账号
+func AuthenticateAPIKey(key string) (*models.APIKey, *models.User, *AuthError) {
+	apiKey, user, err := auth.AuthenticateAPIKey(key)
+	if err != nil {
+		if err == auth.ErrInvalidAPIKey {
+			return nil, nil, &AuthError{Status: http.StatusUnauthorized, Message: "Invalid API key"}
+		}
+		return nil, nil, &AuthError{Status: http.StatusInternalServerError, Message: "Failed to verify API key"}
+	}
+	return apiKey, user, nil
+}
+
+// SetCurrentUser 将认证结果存储到上下文中
+func SetCurrentUser(c *gin.Context, claims *auth.Claims, user *models.User) {
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("user", user)
+	c.Set("claims", claims)
+}
+
+// SetCurrentAPIKey 将 API 密钥认证结果存储到上下文中
+func SetCurrentAPIKey(c *gin.Context, apiKey *models.APIKey, user *models.User) {
+	c.Set("user_id", user.ID)
+	c.Set("username", user.Username)
+	c.Set("user", user)
+	c.Set("api_key", apiKey)
+}
+
+// EmailVerificationRequired 开启邮箱验证后，未验证邮箱的用户不能发言和创建房间
+func EmailVerificationRequired(user *models.User) bool {
+	return config.AppConfig.Auth.RequireEmailVerification && !user.EmailVerified
+}
+
+// RequireVerifiedEmail 开启邮箱验证后，拒绝未验证邮箱的用户
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := GetCurrentUser(c)
+		if exists && EmailVerificationRequired(user) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Email verification required",
 			})
 			c.Abort()
 			return
 		}
 
-		// 将用户信息存储到上下文中
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("user", &user)
+		c.Next()
+	}
+}
+
+// RequirePermission 要求当前用户的全局角色拥有所有指定的权限
+func RequirePermission(perms ...rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := GetCurrentUser(c)
+		for _, perm := range perms {
+			if !rbac.Can(user, perm) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "Permission denied: " + string(perm),
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireScope 要求 API 密钥拥有指定权限，路由参数 id 为房间 ID
+// 使用 JWT 登录的用户不受影响
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, exists := GetCurrentAPIKey(c)
+		if !exists {
+			c.Next()
+			return
+		}
+
+		var roomID uint
+		if id, err := strconv.ParseUint(c.Param("id"), 10, 32); err == nil {
+			roomID = uint(id)
+		}
+
+		if !apiKey.Allows(scope, roomID) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "API key is missing required scope: " + scope,
+			})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -74,6 +288,16 @@ func GetCurrentUser(c *gin.Context) (*models.User, bool) {
 	return nil, false
 }
 
+// GetCurrentClaims 从上下文中获取当前 token 的声明
+func GetCurrentClaims(c *gin.Context) (*auth.Claims, bool) {
+	if claims, exists := c.Get("claims"); exists {
+		if cl, ok := claims.(*auth.Claims); ok {
+			return cl, true
+		}
+	}
+	return nil, false
+}
+
 // GetCurrentUserID 从上下文中获取当前用户ID
 func GetCurrentUserID(c *gin.Context) (uint, bool) {
 	if userID, exists := c.Get("user_id"); exists {
@@ -83,3 +307,13 @@ func GetCurrentUserID(c *gin.Context) (uint, bool) {
 	}
 	return 0, false
 }
+
+// GetCurrentAPIKey 从上下文中获取当前请求使用的 API 密钥
+func GetCurrentAPIKey(c *gin.Context) (*models.APIKey, bool) {
+	if apiKey, exists := c.Get("api_key"); exists {
+		if k, ok := apiKey.(*models.APIKey); ok {
+			return k, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API 密钥权限范围
+// 权限可以限定到单个房间，格式为 <scope>:<room_id>，例如 messages:write:12
+const (
+	ScopeRoomsRead     = "rooms:read"     // 查看房间列表和详情
+	ScopeMessagesRead  = "messages:read"  // 读取消息、接收 WebSocket 推送
+	ScopeMessagesWrite = "messages:write" // 发送消息
+)
+
+// APIScopes 所有可用的权限范围
+var APIScopes = []string{ScopeRoomsRead, ScopeMessagesRead, ScopeMessagesWrite}
+
+// APIKey 机器人账号的 API 密钥
+// 数据库中只保存密钥的哈希值，Prefix 用于在列表中识别密钥
+type APIKey struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	Name        string     `json:"name" gorm:"not null;size:100"`
+	Prefix      string     `json:"prefix" gorm:"size:16"`
+	KeyHash     string     `json:"-" gorm:"uniqueIndex;not null;size:64"`
+	Scopes      string     `json:"-" gorm:"size:1000"` // 空格分隔
+	CreatedByID uint       `json:"created_by_id"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	// 关联关系
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// ScopeList 返回密钥的权限范围列表
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// IsActive 密钥未吊销且未过期
+func (k *APIKey) IsActive() bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt))
+}
+
+// Allows 检查密钥是否拥有指定房间的权限，roomID 为 0 表示不限定房间的操作
+// 不带房间的权限对所有房间有效，带房间的权限只对该房间有效
+func (k *APIKey) Allows(scope string, roomID uint) bool {
+	for _, granted := range k.ScopeList() {
+		if granted == scope {
+			return true
+		}
+		if roomID != 0 && granted == scope+":"+strconv.FormatUint(uint64(roomID), 10) {
+			return true
+		}
+	}
+	return false
+}
+
+// GrantsRoom 检查密钥是否拥有限定到指定房间的权限，不限定房间的权限不算在内
+func (k *APIKey) GrantsRoom(scope string, roomID uint) bool {
+	for _, granted := range k.ScopeList() {
+		if granted == scope+":"+strconv.FormatUint(uint64(roomID), 10) {
+			return true
+		}
+	}
+	return false
+}
+
+// ToJSON 转换为 JSON 格式（不包含密钥）
+func (k *APIKey) ToJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"id":            k.ID,
+		"user_id":       k.UserID,
+		"name":          k.Name,
+		"prefix":        k.Prefix,
+		"scopes":        k.ScopeList(),
+		"created_by_id": k.CreatedByID,
+		"last_used_at":  k.LastUsedAt,
+		"expires_at":    k.ExpiresAt,
+		"revoked_at":    k.RevokedAt,
+		"created_at":    k.CreatedAt,
+	}
+}
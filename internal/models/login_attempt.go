@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// 登录失败原因
+const (
+	LoginFailureUnknownUser     = "unknown_user"
+	LoginFailureInvalidPassword = "invalid_password"
+	LoginFailureInvalidCode     = "invalid_two_factor_code"
+	LoginFailureLocked          = "locked"
+)
+
+// LoginAttempt 登录失败记录，供管理员排查暴力破解
+type LoginAttempt struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Username  string    `json:"username" gorm:"size:100;index"` // 登录时提交的用户名或邮箱
+	UserID    *uint     `json:"user_id" gorm:"index"`           // 用户不存在时为空
+	IP        string    `json:"ip" gorm:"size:45;index"`
+	UserAgent string    `json:"user_agent" gorm:"size:255"`
+	Reason    string    `json:"reason" gorm:"size:50"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"gin-chat-room/internal/passwordhash"
 	"time"
 
 	"gorm.io/gorm"
@@ -12,13 +13,24 @@ type Room struct {
 	Name        string         `json:"name" gorm:"not null;size:100"`
 	Description string         `json:"description" gorm:"size:500"`
 	IsPrivate   bool           `json:"is_private" gorm:"default:false"`
-	Password    string         `json:"-" gorm:"size:255"` // 私有房间密码
+	Password    string         `json:"-" gorm:"size:255"` // 私有房间密码的哈希
 	MaxMembers  int            `json:"max_members" gorm:"default:100"`
 	CreatorID   uint           `json:"creator_id" gorm:"not null"`
+	ArchivedAt  *time.Time     `json:"archived_at"` // 创建者注销且没有成员可以接管时归档，归档后只读
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
+	// 访客默认只能阅读，开启后访客可以在房间发言
+	AllowGuestMessages bool `json:"allow_guest_messages" gorm:"default:false"`
+
+	// 私有房间开启后需要提交加入申请，由房间管理员审批，不能使用房间密码直接加入
+	RequireJoinApproval bool `json:"require_join_approval" gorm:"default:false"`
+
+	// 私信会话由参与者集合唯一确定，不出现在房间列表中，只有参与者可以查看
+	IsDirect  bool    `json:"is_direct" gorm:"default:false;index"`
+	DirectKey *string `json:"-" gorm:"size:255;uniqueIndex"` // 排序后的参与者 ID，普通房间为空
+
 	// 关联关系
 	Creator     User         `json:"creator" gorm:"foreignKey:CreatorID"`
 	Messages    []Message    `json:"-" gorm:"foreignKey:RoomID"`
@@ -33,11 +45,50 @@ type RoomMember struct {
 	Role     string    `json:"role" gorm:"default:'member';size:20"` // admin, member
 	JoinedAt time.Time `json:"joined_at"`
 
+	// 禁言截止时间，到期前不能在房间发言
+	MutedUntil *time.Time `json:"muted_until"`
+
 	// 关联关系
 	Room Room `json:"room" gorm:"foreignKey:RoomID"`
 	User User `json:"user" gorm:"foreignKey:UserID"`
 }
 
+// IsMuted 成员当前是否被禁言
+func (m *RoomMember) IsMuted() bool {
+	return m.MutedUntil != nil && time.Now().Before(*m.MutedUntil)
+}
+
+// SetPassword 设置房间密码（使用与用户密码相同的哈希算法）
+func (r *Room) SetPassword(password string) error {
+	hashedPassword, err := passwordhash.Hash(password)
+	if err != nil {
+		return err
+	}
+	r.Password = hashedPassword
+	return nil
+}
+
+// HasPassword 房间是否设置了密码
+func (r *Room) HasPassword() bool {
+	return r.Password != ""
+}
+
+// CheckPassword 验证房间密码
+func (r *Room) CheckPassword(password string) bool {
+	ok, err := passwordhash.Verify(password, r.Password)
+	return err == nil && ok
+}
+
+// PasswordNeedsRehash 房间密码哈希不是使用当前算法和参数生成的
+func (r *Room) PasswordNeedsRehash() bool {
+	return passwordhash.NeedsRehash(r.Password)
+}
+
+// IsArchived 房间是否已归档
+func (r *Room) IsArchived() bool {
+	return r.ArchivedAt != nil
+}
+
 // GetMemberCount 获取房间成员数量
 func (r *Room) GetMemberCount(db *gorm.DB) int64 {
 	var count int64
@@ -59,16 +110,35 @@ func (r *Room) IsAdmin(db *gorm.DB, userID uint) bool {
 	return count > 0 || r.CreatorID == userID
 }
 
+// IsMuted 检查成员是否在房间被禁言
+func (r *Room) IsMuted(db *gorm.DB, userID uint) bool {
+	var count int64
+	db.Model(&RoomMember{}).Where("room_id = ? AND user_id = ? AND muted_until > ?", r.ID, userID, time.Now()).Count(&count)
+	return count > 0
+}
+
+// IsBanned 检查用户是否被房间封禁（封禁未过期）
+func (r *Room) IsBanned(db *gorm.DB, userID uint) bool {
+	var count int64
+	ActiveRoomBans(db, r.ID).Where("user_id = ?", userID).Count(&count)
+	return count > 0
+}
+
 // ToJSON 转换为 JSON 格式
 func (r *Room) ToJSON(db *gorm.DB) map[string]interface{} {
 	return map[string]interface{}{
-		"id":           r.ID,
-		"name":         r.Name,
-		"description":  r.Description,
-		"is_private":   r.IsPrivate,
-		"max_members":  r.MaxMembers,
-		"creator_id":   r.CreatorID,
-		"member_count": r.GetMemberCount(db),
-		"created_at":   r.CreatedAt,
+		"id":                    r.ID,
+		"name":                  r.Name,
+		"description":           r.Description,
+		"is_private":            r.IsPrivate,
+		"has_password":          r.HasPassword(),
+		"allow_guest_messages":  r.AllowGuestMessages,
+		"require_join_approval": r.RequireJoinApproval,
+		"is_direct":             r.IsDirect,
+		"max_members":           r.MaxMembers,
+		"creator_id":            r.CreatorID,
+		"member_count":          r.GetMemberCount(db),
+		"archived_at":           r.ArchivedAt,
+		"created_at":            r.CreatedAt,
 	}
 }
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RoomBan 房间封禁记录
+// 被封禁的用户不能加入房间、连接房间或在房间发言，同一用户在同一房间只保留一条记录
+type RoomBan struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	RoomID     uint       `json:"room_id" gorm:"not null;uniqueIndex:idx_room_ban"`
+	UserID     uint       `json:"user_id" gorm:"not null;uniqueIndex:idx_room_ban"`
+	BannedByID uint       `json:"banned_by_id" gorm:"not null"`
+	Reason     string     `json:"reason" gorm:"size:500"`
+	ExpiresAt  *time.Time `json:"expires_at"` // 为空表示永久封禁
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	// 关联关系
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// IsActive 封禁是否仍然有效
+func (b *RoomBan) IsActive() bool {
+	return b.ExpiresAt == nil || time.Now().Before(*b.ExpiresAt)
+}
+
+// ToJSON 转换为 JSON 格式
+func (b *RoomBan) ToJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"id":           b.ID,
+		"room_id":      b.RoomID,
+		"user_id":      b.UserID,
+		"user":         b.User.ToPublicJSON(),
+		"banned_by_id": b.BannedByID,
+		"reason":       b.Reason,
+		"expires_at":   b.ExpiresAt,
+		"created_at":   b.CreatedAt,
+	}
+}
+
+// ActiveRoomBans 查询房间中仍然有效的封禁
+func ActiveRoomBans(db *gorm.DB, roomID uint) *gorm.DB {
+	return db.Model(&RoomBan{}).
+		Where("room_id = ? AND (expires_at IS NULL OR expires_at > ?)", roomID, time.Now())
+}
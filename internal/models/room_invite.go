@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// RoomInvite 房间邀请码
+// 持有邀请码的用户不需要房间密码即可加入房间，加入后使用邀请码指定的房间角色
+type RoomInvite struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	Code      string     `json:"code" gorm:"uniqueIndex;not null;size:32"`
+	RoomID    uint       `json:"room_id" gorm:"not null;index"`
+	CreatorID uint       `json:"creator_id" gorm:"not null"`
+	Role      string     `json:"role" gorm:"default:'member';size:20"` // 加入后的房间角色：admin, member
+	MaxUses   int        `json:"max_uses" gorm:"default:0"`            // 0 表示不限次数
+	Uses      int        `json:"uses" gorm:"default:0"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// 关联关系
+	Room Room `json:"-" gorm:"foreignKey:RoomID"`
+}
+
+// IsUsable 邀请码未吊销、未过期且未达到使用次数上限
+func (i *RoomInvite) IsUsable() bool {
+	if i.RevokedAt != nil {
+		return false
+	}
+	if i.ExpiresAt != nil && !time.Now().Before(*i.ExpiresAt) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+// ToJSON 转换为 JSON 格式
+func (i *RoomInvite) ToJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"id":         i.ID,
+		"code":       i.Code,
+		"room_id":    i.RoomID,
+		"creator_id": i.CreatorID,
+		"role":       i.Role,
+		"max_uses":   i.MaxUses,
+		"uses":       i.Uses,
+		"expires_at": i.ExpiresAt,
+		"revoked_at": i.RevokedAt,
+		"is_usable":  i.IsUsable(),
+		"created_at": i.CreatedAt,
+	}
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 加入申请状态，超过有效期仍未处理的申请视为已过期
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestDenied   = "denied"
+	JoinRequestExpired  = "expired"
+)
+
+// RoomJoinRequest 私有房间的加入申请
+// 用户提交申请后由房间管理员批准或拒绝，批准后成为房间成员
+type RoomJoinRequest struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	RoomID       uint       `json:"room_id" gorm:"not null;index"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	Message      string     `json:"message" gorm:"size:500"`
+	Status       string     `json:"status" gorm:"size:20;not null;default:pending"`
+	ReviewedByID *uint      `json:"reviewed_by_id"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	// 关联关系
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// IsPending 申请是否仍在等待处理且未过期
+func (r *RoomJoinRequest) IsPending() bool {
+	return r.Status == JoinRequestPending && time.Now().Before(r.ExpiresAt)
+}
+
+// CurrentStatus 返回申请当前的状态，过期未处理的申请返回 expired
+func (r *RoomJoinRequest) CurrentStatus() string {
+	if r.Status == JoinRequestPending && !r.IsPending() {
+		return JoinRequestExpired
+	}
+	return r.Status
+}
+
+// ToJSON 转换为 JSON 格式
+func (r *RoomJoinRequest) ToJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"id":             r.ID,
+		"room_id":        r.RoomID,
+		"user_id":        r.UserID,
+		"user":           r.User.ToPublicJSON(),
+		"message":        r.Message,
+		"status":         r.CurrentStatus(),
+		"reviewed_by_id": r.ReviewedByID,
+		"reviewed_at":    r.ReviewedAt,
+		"expires_at":     r.ExpiresAt,
+		"created_at":     r.CreatedAt,
+	}
+}
+
+// PendingJoinRequests 查询房间中等待处理且未过期的加入申请
+func PendingJoinRequests(db *gorm.DB, roomID uint) *gorm.DB {
+	return db.Model(&RoomJoinRequest{}).
+		Where("room_id = ? AND status = ? AND expires_at > ?", roomID, JoinRequestPending, time.Now())
+}
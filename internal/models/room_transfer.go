@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// RoomOwnershipTransfer 待接受的房间转让
+// 房间创建者指定一名成员，对方接受后成为新的创建者；每个房间同时只有一个待接受的转让
+type RoomOwnershipTransfer struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	RoomID     uint      `json:"room_id" gorm:"not null;uniqueIndex"`
+	FromUserID uint      `json:"from_user_id" gorm:"not null"`
+	ToUserID   uint      `json:"to_user_id" gorm:"not null;index"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// IsExpired 转让是否已过期
+func (t *RoomOwnershipTransfer) IsExpired() bool {
+	return !time.Now().Before(t.ExpiresAt)
+}
+
+// ToJSON 转换为 JSON 格式
+func (t *RoomOwnershipTransfer) ToJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"id":           t.ID,
+		"room_id":      t.RoomID,
+		"from_user_id": t.FromUserID,
+		"to_user_id":   t.ToUserID,
+		"expires_at":   t.ExpiresAt,
+		"created_at":   t.CreatedAt,
+	}
+}
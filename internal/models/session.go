@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// Session 登录会话模型
+// 每次登录创建一个会话，会话内刷新得到的访问令牌都带有同一个会话 ID（sid），
+// 刷新令牌属于同一个令牌家族，注销会话后两者同时失效
+type Session struct {
+	ID         string     `json:"id" gorm:"primaryKey;size:36"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	FamilyID   string     `json:"-" gorm:"not null;size:36;index"` // 刷新令牌家族
+	TokenID    string     `json:"-" gorm:"size:36"`                // 最近一次签发的访问令牌 jti
+	UserAgent  string     `json:"user_agent" gorm:"size:255"`
+	IP         string     `json:"ip" gorm:"size:45"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	// 关联关系
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// IsActive 会话未注销且未过期
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
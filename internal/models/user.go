@@ -1,12 +1,26 @@
 package models
 
 import (
+	"gin-chat-room/internal/passwordhash"
+	"strings"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// 已注销用户的消息和无人接管的房间归属于这个占位账号
+// 占位账号通过 IsPlaceholder 识别，用户名只是默认值，注册时保留
+const (
+	DeletedUserUsername = "[deleted]"
+	DeletedUserEmail    = "deleted@users.invalid"
+	DeletedUserNickname = "已注销用户"
+)
+
+// IsReservedUsername 用户名是否保留给系统账号，不能注册
+func IsReservedUsername(username string) bool {
+	return strings.EqualFold(strings.TrimSpace(username), DeletedUserUsername)
+}
+
 // User 用户模型
 type User struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
@@ -21,6 +35,33 @@ type User struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
+	// 全局角色：site_admin, moderator, user, guest，权限定义见 rbac 包
+	Role string `json:"role" gorm:"not null;default:'user';size:20;index"`
+
+	// 机器人账号只能通过 API 密钥认证，不能使用密码登录
+	IsBot bool `json:"is_bot" gorm:"default:false"`
+
+	// 访客账号不需要注册，使用 guest 角色，无活动一段时间后删除
+	IsGuest bool `json:"is_guest" gorm:"default:false;index"`
+
+	// 邮箱验证状态
+	EmailVerified   bool       `json:"email_verified" gorm:"default:false"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	// 两步验证，TOTPSecret 在确认启用前为待确认的密钥，加密存储
+	TOTPSecret   string `json:"-" gorm:"size:255"`
+	TOTPEnabled  bool   `json:"-" gorm:"default:false"`
+	TOTPLastStep int64  `json:"-"` // 最近一次使用的时间步，防止验证码重放
+
+	// 令牌版本，签发时写入访问令牌，修改或重置密码后递增，旧版本的令牌全部失效
+	TokenVersion uint `json:"-" gorm:"not null;default:0"`
+
+	// 申请注销后计划删除账号的时间，宽限期内重新登录会撤销注销
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at" gorm:"index"`
+
+	// 已注销用户的占位账号，全站只有一个
+	IsPlaceholder bool `json:"-" gorm:"default:false;index"`
+
 	// 关联关系
 	Messages    []Message    `json:"-" gorm:"foreignKey:UserID"`
 	RoomMembers []RoomMember `json:"-" gorm:"foreignKey:UserID"`
@@ -32,34 +73,64 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.Nickname == "" {
 		u.Nickname = u.Username
 	}
+	// 与 rbac.DefaultRole 保持一致
+	if u.Role == "" {
+		u.Role = "user"
+	}
 	return nil
 }
 
-// SetPassword 设置密码（加密）
+// SetPassword 设置密码（使用当前配置的哈希算法）
 func (u *User) SetPassword(password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := passwordhash.Hash(password)
 	if err != nil {
 		return err
 	}
-	u.Password = string(hashedPassword)
+	u.Password = hashedPassword
 	return nil
 }
 
-// CheckPassword 验证密码
+// CheckPassword 验证密码，支持所有已知算法生成的哈希
 func (u *User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	return err == nil
+	ok, err := passwordhash.Verify(password, u.Password)
+	return err == nil && ok
+}
+
+// HasPassword 用户是否设置了可以登录的密码，外部登录创建的账号、访客和占位账号没有密码
+func (u *User) HasPassword() bool {
+	return passwordhash.IsHash(u.Password)
+}
+
+// PasswordNeedsRehash 密码哈希不是使用当前算法和参数生成的
+func (u *User) PasswordNeedsRehash() bool {
+	return passwordhash.NeedsRehash(u.Password)
+}
+
+// ToPublicJSON 转换为其他用户可见的 JSON 格式，不包含邮箱、角色等个人信息
+func (u *User) ToPublicJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"id":       u.ID,
+		"username": u.Username,
+		"nickname": u.Nickname,
+		"avatar":   u.Avatar,
+	}
 }
 
 // ToJSON 转换为 JSON 格式（不包含敏感信息）
 func (u *User) ToJSON() map[string]interface{} {
 	return map[string]interface{}{
-		"id":        u.ID,
-		"username":  u.Username,
-		"email":     u.Email,
-		"nickname":  u.Nickname,
-		"avatar":    u.Avatar,
-		"is_online": u.IsOnline,
-		"last_seen": u.LastSeen,
+		"id":                    u.ID,
+		"username":              u.Username,
+		"email":                 u.Email,
+		"nickname":              u.Nickname,
+		"avatar":                u.Avatar,
+		"is_online":             u.IsOnline,
+		"last_seen":             u.LastSeen,
+		"email_verified":        u.EmailVerified,
+		"two_factor_enabled":    u.TOTPEnabled,
+		"role":                  u.Role,
+		"is_bot":                u.IsBot,
+		"is_guest":              u.IsGuest,
+		"deletion_scheduled_at": u.DeletionScheduledAt,
 	}
 }
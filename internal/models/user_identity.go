@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// UserIdentity 用户关联的外部登录身份
+// 同一提供方的 Subject 唯一，一个用户可以关联多个提供方
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Provider  string    `json:"provider" gorm:"not null;size:50;uniqueIndex:idx_provider_subject"`
+	Subject   string    `json:"subject" gorm:"not null;size:255;uniqueIndex:idx_provider_subject"`
+	Email     string    `json:"email" gorm:"size:100"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 关联关系
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
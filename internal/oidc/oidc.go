@@ -0,0 +1,286 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-chat-room/config"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// discoveryTTL 发现文档和公钥集合的缓存时间
+const discoveryTTL = time.Hour
+
+// httpClient 访问身份提供方使用的 HTTP 客户端
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// discoveryDocument OpenID Connect 发现文档中使用到的字段
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// idTokenClaims ID Token 中使用到的声明
+type idTokenClaims struct {
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     any    `json:"email_verified"` // 部分提供方返回字符串 "true"
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Picture           string `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+// OIDCProvider 基于发现文档的 OpenID Connect 提供方，使用授权码 + PKCE 流程
+type OIDCProvider struct {
+	cfg config.OIDCProviderConfig
+
+	mutex      sync.Mutex
+	discovery  *discoveryDocument
+	keys       map[string]interface{}
+	fetchedAt  time.Time
+	keysLoaded time.Time
+}
+
+// NewOIDCProvider 创建 OIDC 提供方
+func NewOIDCProvider(cfg config.OIDCProviderConfig) *OIDCProvider {
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return &OIDCProvider{cfg: cfg}
+}
+
+// Name 提供方标识
+func (p *OIDCProvider) Name() string {
+	return p.cfg.Name
+}
+
+// DisplayName 展示给用户的名称
+func (p *OIDCProvider) DisplayName() string {
+	return p.cfg.DisplayName
+}
+
+// AutoProvision 没有匹配的本地账号时是否自动创建
+func (p *OIDCProvider) AutoProvision() bool {
+	return p.cfg.AutoProvision
+}
+
+// redirectURL 授权回调地址
+func (p *OIDCProvider) redirectURL() string {
+	if p.cfg.RedirectURL != "" {
+		return p.cfg.RedirectURL
+	}
+	return strings.TrimSuffix(config.AppConfig.Server.BaseURL, "/") + "/api/v1/auth/oidc/" + p.cfg.Name + "/callback"
+}
+
+// AuthCodeURL 生成授权地址
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.cfg.ClientID)
+	params.Set("redirect_uri", p.redirectURL())
+	params.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange 使用授权码换取并校验 ID Token
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL())
+	form.Set("code_verifier", codeVerifier)
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientID) // 公共客户端
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var tokenResponse struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := doJSON(req, &tokenResponse); err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	if tokenResponse.IDToken == "" {
+		return nil, errors.New("token response does not contain an id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, tokenResponse.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	return &Identity{
+		Subject:           claims.Subject,
+		Email:             strings.ToLower(strings.TrimSpace(claims.Email)),
+		EmailVerified:     claims.EmailVerified == true || claims.EmailVerified == "true",
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+		Picture:           claims.Picture,
+	}, nil
+}
+
+// verifyIDToken 校验 ID Token 的签名、签发者、受众和有效期
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, idToken string) (*idTokenClaims, error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.verificationKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id_token does not contain a subject")
+	}
+	return claims, nil
+}
+
+// discover 获取并缓存发现文档
+func (p *OIDCProvider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.discovery != nil && time.Since(p.fetchedAt) < discoveryTTL {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc discoveryDocument
+	if err := doJSON(req, &doc); err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+
+	// 发现文档中的签发者必须与配置一致（OpenID Connect Discovery 4.3）
+	if strings.TrimSuffix(doc.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", doc.Issuer, p.cfg.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing required endpoints")
+	}
+
+	p.discovery = &doc
+	p.fetchedAt = time.Now()
+	return p.discovery, nil
+}
+
+// verificationKey 根据 kid 获取提供方公钥，遇到未知 kid 时重新获取公钥集合以支持密钥轮换
+func (p *OIDCProvider) verificationKey(ctx context.Context, kid string) (interface{}, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok && time.Since(p.keysLoaded) < discoveryTTL {
+		return key, nil
+	}
+
+	// 限制刷新频率，防止伪造的 kid 导致频繁请求身份提供方
+	if time.Since(p.keysLoaded) > 10*time.Second {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.discovery.JWKSURI, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var set struct {
+			Keys []jsonWebKey `json:"keys"`
+		}
+		if err := doJSON(req, &set); err != nil {
+			return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+		}
+
+		p.keys = make(map[string]interface{})
+		for _, jwk := range set.Keys {
+			if jwk.Use != "" && jwk.Use != "sig" {
+				continue
+			}
+			key, err := jwk.publicKey()
+			if err != nil {
+				continue // 忽略不支持的密钥类型
+			}
+			p.keys[jwk.Kid] = key
+		}
+		p.keysLoaded = time.Now()
+	}
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// lookupKey 查找公钥，token 没有 kid 且只有一个公钥时直接使用该公钥
+func (p *OIDCProvider) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// doJSON 发送请求并解析 JSON 响应
+func doJSON(req *http.Request, v interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
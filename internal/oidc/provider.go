@@ -0,0 +1,94 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gin-chat-room/config"
+	"sort"
+	"sync"
+)
+
+// ErrProviderNotFound 登录提供方不存在或未启用
+var ErrProviderNotFound = errors.New("login provider not found")
+
+// Identity 外部身份提供方返回的用户身份
+type Identity struct {
+	Subject           string // 提供方内唯一且不变的用户标识
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Picture           string
+}
+
+// Provider 外部登录提供方
+// 除 OIDC 外，其他 OAuth2 提供方实现该接口后同样可以通过 Register 接入
+type Provider interface {
+	// Name 提供方标识
+	Name() string
+	// DisplayName 展示给用户的名称
+	DisplayName() string
+	// AutoProvision 没有匹配的本地账号时是否自动创建
+	AutoProvision() bool
+	// AuthCodeURL 生成授权地址，codeChallenge 为 PKCE S256 挑战值
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange 使用授权码和 PKCE 校验值换取用户身份，nonce 必须与授权请求一致
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error)
+}
+
+var (
+	providers      = make(map[string]Provider)
+	providersMutex sync.RWMutex
+)
+
+// InitProviders 根据配置注册所有 OIDC 登录提供方
+// 发现文档在第一次使用时获取，启动时身份提供方不可用不会影响服务启动
+func InitProviders() error {
+	providersMutex.Lock()
+	providers = make(map[string]Provider)
+	providersMutex.Unlock()
+
+	for _, cfg := range config.AppConfig.OIDC {
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			return fmt.Errorf("oidc provider %q requires an issuer and a client id", cfg.Name)
+		}
+		Register(NewOIDCProvider(cfg))
+	}
+	return nil
+}
+
+// Register 注册登录提供方，同名提供方会被替换
+func Register(provider Provider) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+
+	providers[provider.Name()] = provider
+}
+
+// GetProvider 根据名称获取登录提供方
+func GetProvider(name string) (Provider, error) {
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+
+	provider, ok := providers[name]
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+	return provider, nil
+}
+
+// Providers 返回按名称排序的所有登录提供方
+func Providers() []Provider {
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+
+	list := make([]Provider, 0, len(providers))
+	for _, provider := range providers {
+		list = append(list, provider)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list
+}
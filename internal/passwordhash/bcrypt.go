@@ -0,0 +1,66 @@
+package passwordhash
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultBcryptCost 默认的 bcrypt 代价
+const DefaultBcryptCost = bcrypt.DefaultCost
+
+// bcryptMaxPasswordBytes bcrypt 只使用密码的前 72 个字节
+const bcryptMaxPasswordBytes = 72
+
+// BcryptHasher bcrypt 哈希算法，哈希为 Modular Crypt 格式：$2a$10$<salt+hash>
+// 用于验证迁移前的旧密码，也可以通过配置继续用于新密码
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher 创建 bcrypt 哈希算法
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{cost: cost}
+}
+
+// validate 检查代价是否在 bcrypt 支持的范围内
+func (h *BcryptHasher) validate() error {
+	if h.cost < bcrypt.MinCost || h.cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return nil
+}
+
+// Hash 生成密码哈希
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// Verify 验证密码
+func (h *BcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return false, err
+}
+
+// NeedsRehash 代价与当前配置不同时需要重新生成
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost
+}
+
+// Handles 判断是否为 bcrypt 哈希
+func (h *BcryptHasher) Handles(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
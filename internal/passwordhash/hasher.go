@@ -0,0 +1,133 @@
+package passwordhash
+
+import (
+	"errors"
+	"fmt"
+	"gin-chat-room/config"
+	"strings"
+	"sync"
+)
+
+// 支持的哈希算法
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// ErrUnknownHashFormat 无法识别的哈希格式
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Hasher 密码哈希算法
+// 生成的哈希必须是自描述的（PHC 字符串或 bcrypt 的 Modular Crypt 格式），包含算法和参数
+type Hasher interface {
+	// Hash 生成密码哈希
+	Hash(password string) (string, error)
+	// Verify 验证密码，哈希不属于该算法时返回 ErrUnknownHashFormat
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash 哈希的参数与当前配置不同时需要重新生成
+	NeedsRehash(encoded string) bool
+	// Handles 判断哈希是否由该算法生成
+	Handles(encoded string) bool
+}
+
+// 所有可以验证的算法，新密码使用 current 生成
+var (
+	argon2idHasher        = NewArgon2idHasher(DefaultArgon2idParams)
+	bcryptHasher          = NewBcryptHasher(DefaultBcryptCost)
+	current        Hasher = argon2idHasher
+)
+
+// 不存在的用户登录时用于验证的哈希，使用当前算法和参数生成
+var (
+	dummyOnce sync.Once
+	dummyHash string
+)
+
+// InitHasher 根据配置选择新密码使用的算法和参数
+func InitHasher() error {
+	cfg := config.AppConfig.Auth
+
+	params := DefaultArgon2idParams
+	if cfg.Argon2Memory > 0 {
+		params.Memory = uint32(cfg.Argon2Memory)
+	}
+	if cfg.Argon2Iterations > 0 {
+		params.Iterations = uint32(cfg.Argon2Iterations)
+	}
+	if cfg.Argon2Parallelism > 0 {
+		if cfg.Argon2Parallelism > 255 {
+			return fmt.Errorf("argon2 parallelism must be at most 255")
+		}
+		params.Parallelism = uint8(cfg.Argon2Parallelism)
+	}
+
+	cost := DefaultBcryptCost
+	if cfg.BcryptCost > 0 {
+		cost = cfg.BcryptCost
+	}
+	bcryptH := NewBcryptHasher(cost)
+	if err := bcryptH.validate(); err != nil {
+		return err
+	}
+
+	argon2idHasher = NewArgon2idHasher(params)
+	bcryptHasher = bcryptH
+	dummyOnce = sync.Once{}
+
+	switch strings.ToLower(cfg.PasswordHashAlgorithm) {
+	case "", AlgorithmArgon2id:
+		current = argon2idHasher
+	case AlgorithmBcrypt:
+		current = bcryptHasher
+	default:
+		return fmt.Errorf("unsupported password hash algorithm %q", cfg.PasswordHashAlgorithm)
+	}
+
+	return nil
+}
+
+// Hash 使用当前算法生成密码哈希
+func Hash(password string) (string, error) {
+	return current.Hash(password)
+}
+
+// Verify 根据哈希格式选择算法验证密码
+func Verify(password, encoded string) (bool, error) {
+	for _, h := range []Hasher{argon2idHasher, bcryptHasher} {
+		if h.Handles(encoded) {
+			return h.Verify(password, encoded)
+		}
+	}
+	return false, ErrUnknownHashFormat
+}
+
+// VerifyDummy 用户不存在时执行一次代价相同的验证，避免通过响应时间枚举用户
+func VerifyDummy(password string) {
+	dummyOnce.Do(func() {
+		dummyHash, _ = current.Hash("dummy password")
+	})
+	current.Verify(password, dummyHash)
+}
+
+// IsHash 判断字符串是否是可以识别的密码哈希，用于迁移明文存储的密码
+func IsHash(encoded string) bool {
+	for _, h := range []Hasher{argon2idHasher, bcryptHasher} {
+		if h.Handles(encoded) {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsRehash 哈希不是当前算法生成的，或者参数与当前配置不同
+func NeedsRehash(encoded string) bool {
+	return !current.Handles(encoded) || current.NeedsRehash(encoded)
+}
+
+// MaxPasswordBytes 当前算法支持的最大密码长度（字节），0 表示不限制
+func MaxPasswordBytes() int {
+	if current == bcryptHasher {
+		return bcryptMaxPasswordBytes
+	}
+	return 0
+}
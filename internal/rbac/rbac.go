@@ -0,0 +1,114 @@
+package rbac
+
+import (
+	"sort"
+)
+
+// Role 全局角色
+type Role string
+
+// 全局角色，权限依次递减
+const (
+	RoleSiteAdmin Role = "site_admin" // 站点管理员，拥有所有权限
+	RoleModerator Role = "moderator"  // 版主，可以管理所有房间的成员和消息
+	RoleUser      Role = "user"       // 普通注册用户
+	RoleGuest     Role = "guest"      // 访客，只能浏览公开房间
+)
+
+// Permission 命名权限
+type Permission string
+
+// 所有权限
+const (
+	PermRoomsView        Permission = "rooms:view"         // 查看公开房间
+	PermRoomsViewPrivate Permission = "rooms:view_private" // 不是成员也能查看私有房间
+	PermRoomsCreate      Permission = "rooms:create"       // 创建房间
+	PermRoomsJoin        Permission = "rooms:join"         // 加入房间
+	PermRoomsModerate    Permission = "rooms:moderate"     // 管理任意房间的成员
+	PermRoomsManage      Permission = "rooms:manage"       // 修改和删除任意房间
+	PermMessagesRead     Permission = "messages:read"      // 读取消息
+	PermMessagesSend     Permission = "messages:send"      // 发送消息
+	PermUsersManage      Permission = "users:manage"       // 修改用户的全局角色
+	PermBotsManage       Permission = "bots:manage"        // 管理机器人账号和 API 密钥
+	PermAuditRead        Permission = "audit:read"         // 查看登录失败记录等审计信息
+)
+
+// DefaultRole 新注册用户的角色
+const DefaultRole = RoleUser
+
+// rolePermissions 每个角色拥有的权限
+var rolePermissions = map[Role][]Permission{
+	RoleGuest: {
+		PermRoomsView,
+		PermMessagesRead,
+	},
+	RoleUser: {
+		PermRoomsView,
+		PermRoomsCreate,
+		PermRoomsJoin,
+		PermMessagesRead,
+		PermMessagesSend,
+	},
+	RoleModerator: {
+		PermRoomsView,
+		PermRoomsViewPrivate,
+		PermRoomsCreate,
+		PermRoomsJoin,
+		PermRoomsModerate,
+		PermMessagesRead,
+		PermMessagesSend,
+		PermAuditRead,
+	},
+	RoleSiteAdmin: {
+		PermRoomsView,
+		PermRoomsViewPrivate,
+		PermRoomsCreate,
+		PermRoomsJoin,
+		PermRoomsModerate,
+		PermRoomsManage,
+		PermMessagesRead,
+		PermMessagesSend,
+		PermUsersManage,
+		PermBotsManage,
+		PermAuditRead,
+	},
+}
+
+// roles 按权限从高到低排列的角色
+var roles = []Role{RoleSiteAdmin, RoleModerator, RoleUser, RoleGuest}
+
+// permissionSets 由 rolePermissions 生成的查询表
+var permissionSets = func() map[Role]map[Permission]bool {
+	sets := make(map[Role]map[Permission]bool, len(rolePermissions))
+	for role, perms := range rolePermissions {
+		set := make(map[Permission]bool, len(perms))
+		for _, perm := range perms {
+			set[perm] = true
+		}
+		sets[role] = set
+	}
+	return sets
+}()
+
+// Roles 返回所有角色，按权限从高到低排列
+func Roles() []Role {
+	return append([]Role(nil), roles...)
+}
+
+// ValidRole 判断角色是否存在
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[Role(role)]
+	return ok
+}
+
+// Has 判断角色是否拥有权限，未知角色没有任何权限
+func Has(role string, perm Permission) bool {
+	return permissionSets[Role(role)][perm]
+}
+
+// Permissions 返回角色拥有的权限，按名称排序
+func Permissions(role string) []Permission {
+	perms := append([]Permission(nil), rolePermissions[Role(role)]...)
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
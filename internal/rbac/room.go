@@ -0,0 +1,82 @@
+package rbac
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+)
+
+// Can 判断用户是否拥有全局权限
+func Can(user *models.User, perm Permission) bool {
+	return user != nil && Has(user.Role, perm)
+}
+
+// CanViewRoom 判断用户能否查看房间和房间消息
+// 公开房间需要 rooms:view，私有房间还需要是创建者、成员或拥有 rooms:view_private
+// 私信会话只有参与者可以查看
+func CanViewRoom(user *models.User, room *models.Room) bool {
+	if !Can(user, PermRoomsView) {
+		return false
+	}
+	if room.IsDirect {
+		return room.IsMember(database.DB, user.ID)
+	}
+	if !room.IsPrivate || Can(user, PermRoomsViewPrivate) {
+		return true
+	}
+	return IsRoomCreator(user, room) || room.IsMember(database.DB, user.ID)
+}
+
+// IsRoomCreator 判断用户是否是房间创建者
+func IsRoomCreator(user *models.User, room *models.Room) bool {
+	return user != nil && room.CreatorID == user.ID
+}
+
+// IsRoomAdmin 判断用户是否是房间管理员，创建者同样是房间管理员
+func IsRoomAdmin(user *models.User, room *models.Room) bool {
+	return user != nil && room.IsAdmin(database.DB, user.ID)
+}
+
+// CanManageRoom 判断用户能否修改房间设置、密码和成员角色
+// 需要是房间管理员或拥有 rooms:manage
+func CanManageRoom(user *models.User, room *models.Room) bool {
+	return IsRoomAdmin(user, room) || Can(user, PermRoomsManage)
+}
+
+// CanModerateRoom 判断用户能否管理房间成员（移出、封禁、禁言、审核加入申请）
+// 需要是房间管理员或拥有 rooms:moderate
+func CanModerateRoom(user *models.User, room *models.Room) bool {
+	return IsRoomAdmin(user, room) || Can(user, PermRoomsModerate)
+}
+
+// CanActAsRoomCreator 判断用户能否进行只有房间创建者才能进行的操作（删除、恢复房间，修改房间管理员的角色）
+// 需要是房间创建者或拥有 rooms:manage
+func CanActAsRoomCreator(user *models.User, room *models.Room) bool {
+	return IsRoomCreator(user, room) || Can(user, PermRoomsManage)
+}
+
+// CanModerateRoomAdmins 判断用户能否移出、封禁或禁言房间管理员
+// 需要是房间创建者或拥有 rooms:moderate
+func CanModerateRoomAdmins(user *models.User, room *models.Room) bool {
+	return IsRoomCreator(user, room) || Can(user, PermRoomsModerate)
+}
+
+// CanSendMessage 判断用户能否在房间发言
+// 访客角色没有全局发言权限，公开房间开启访客发言后可以发言
+func CanSendMessage(user *models.User, room *models.Room) bool {
+	if Can(user, PermMessagesSend) {
+		return true
+	}
+	return user != nil && user.Role == string(RoleGuest) && room.AllowGuestMessages && !room.IsPrivate
+}
+
+// CanBotPostWithoutMembership 判断机器人能否不加入房间直接发言
+// 机器人不加入房间，只能向 API 密钥明确授权的、可以查看的公开房间发言
+func CanBotPostWithoutMembership(user *models.User, apiKey *models.APIKey, room *models.Room) bool {
+	if user == nil || !user.IsBot || apiKey == nil {
+		return false
+	}
+	if room.IsPrivate || room.IsDirect || !apiKey.GrantsRoom(models.ScopeMessagesWrite, room.ID) {
+		return false
+	}
+	return CanViewRoom(user, room)
+}
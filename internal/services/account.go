@@ -0,0 +1,224 @@
+package services
+
+import (
+	"errors"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ScheduleAccountDeletion 申请注销账号，宽限期结束后删除
+// 所有已签发的令牌立即失效，宽限期内重新登录会撤销注销
+func ScheduleAccountDeletion(user *models.User) (time.Time, error) {
+	deleteAt := time.Now().AddDate(0, 0, config.AppConfig.Auth.AccountDeletionGraceDays)
+	if err := database.DB.Model(user).Update("deletion_scheduled_at", &deleteAt).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	if err := auth.RevokeAllUserTokens(user.ID); err != nil {
+		return time.Time{}, err
+	}
+
+	return deleteAt, nil
+}
+
+// CancelAccountDeletion 撤销尚未执行的账号注销
+func CancelAccountDeletion(user *models.User) error {
+	if user.DeletionScheduledAt == nil {
+		return nil
+	}
+	return database.DB.Model(user).Update("deletion_scheduled_at", nil).Error
+}
+
+// RunAccountDeletions 定期删除宽限期已结束的账号
+func RunAccountDeletions(hub *Hub, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		DeleteDueAccounts(hub)
+		<-ticker.C
+	}
+}
+
+// DeleteDueAccounts 删除所有宽限期已结束的账号，返回删除的数量
+func DeleteDueAccounts(hub *Hub) int {
+	var userIDs []uint
+	if err := database.DB.Model(&models.User{}).
+		Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", time.Now()).
+		Pluck("id", &userIDs).Error; err != nil {
+		log.Printf("Failed to load scheduled account deletions: %v", err)
+		return 0
+	}
+
+	deleted := 0
+	for _, userID := range userIDs {
+		if err := DeleteAccount(userID); err != nil {
+			log.Printf("Failed to delete account %d: %v", userID, err)
+			continue
+		}
+		hub.DisconnectUser(userID)
+		deleted++
+	}
+	return deleted
+}
+
+// DeleteAccount 删除账号及其个人数据
+// 发送过的消息转给已注销用户占位账号；创建的房间移交给其他成员，没有可以接管的成员时归档
+func DeleteAccount(userID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		placeholder, err := deletedUser(tx)
+		if err != nil {
+			return err
+		}
+
+		var rooms []models.Room
+		if err := tx.Unscoped().Where("creator_id = ?", user.ID).Find(&rooms).Error; err != nil {
+			return err
+		}
+		for i := range rooms {
+			if err := handOverRoom(tx, &rooms[i], &user, placeholder); err != nil {
+				return err
+			}
+		}
+
+		// 消息保留在房间中，但不再关联到本人
+		if err := tx.Unscoped().Model(&models.Message{}).Where("user_id = ?", user.ID).
+			Update("user_id", placeholder.ID).Error; err != nil {
+			return err
+		}
+
+		// 本人创建的邀请码、执行的封禁和审核的加入申请继续有效，操作人转给占位账号
+		if err := tx.Model(&models.RoomInvite{}).Where("creator_id = ?", user.ID).
+			Update("creator_id", placeholder.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.RoomBan{}).Where("banned_by_id = ?", user.ID).
+			Update("banned_by_id", placeholder.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.RoomJoinRequest{}).Where("reviewed_by_id = ?", user.ID).
+			Update("reviewed_by_id", placeholder.ID).Error; err != nil {
+			return err
+		}
+
+		// 转让给本人或由本人发起的转让作废
+		if err := tx.Where("from_user_id = ? OR to_user_id = ?", user.ID, user.ID).
+			Delete(&models.RoomOwnershipTransfer{}).Error; err != nil {
+			return err
+		}
+
+		// 本人提交的加入申请不再有意义
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RoomJoinRequest{}).Error; err != nil {
+			return err
+		}
+
+		// 保留登录失败记录用于审计，但解除与账号的关联
+		if err := tx.Model(&models.LoginAttempt{}).Where("user_id = ?", user.ID).
+			Update("user_id", nil).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&models.RoomMember{},
+			&models.RoomBan{},
+			&models.Session{},
+			&models.RefreshToken{},
+			&models.PasswordResetToken{},
+			&models.RecoveryCode{},
+			&models.UserIdentity{},
+			&models.APIKey{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Unscoped().Delete(&user).Error
+	})
+}
+
+// handOverRoom 将房间移交给最早加入的房间管理员，没有其他管理员时移交给最早加入的成员，都没有时归档房间
+func handOverRoom(tx *gorm.DB, room *models.Room, user, placeholder *models.User) error {
+	// 私信会话没有管理者，其他参与者可以继续对话
+	if room.IsDirect {
+		return tx.Unscoped().Model(room).Update("creator_id", placeholder.ID).Error
+	}
+
+	var successor models.RoomMember
+	err := tx.Preload("User").Select("room_members.*").
+		Joins("JOIN users ON users.id = room_members.user_id").
+		Where("room_members.room_id = ? AND room_members.user_id <> ?", room.ID, user.ID).
+		Where("users.is_bot = ? AND users.deleted_at IS NULL AND users.deletion_scheduled_at IS NULL", false).
+		Order("CASE WHEN room_members.role = 'admin' THEN 0 ELSE 1 END, room_members.joined_at, room_members.id").
+		First(&successor).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if err == nil && !room.DeletedAt.Valid && !room.IsArchived() {
+		if err := setRoomOwner(tx, room, successor.UserID); err != nil {
+			return err
+		}
+		return tx.Create(models.CreateSystemMessage(room.ID,
+			user.Nickname+" 已注销，房间已移交给 "+successor.User.Nickname)).Error
+	}
+
+	updates := map[string]interface{}{"creator_id": placeholder.ID}
+	if !room.IsArchived() {
+		updates["archived_at"] = time.Now()
+	}
+	return tx.Unscoped().Model(room).Updates(updates).Error
+}
+
+// deletedUser 获取已注销用户占位账号，不存在时创建
+// 占位账号的密码不是有效的哈希，不能登录；默认用户名或邮箱已被占用时加上随机后缀
+func deletedUser(tx *gorm.DB) (*models.User, error) {
+	var user models.User
+	err := tx.Unscoped().Where("is_placeholder = ?", true).Order("id").First(&user).Error
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	username, email := models.DeletedUserUsername, models.DeletedUserEmail
+	var taken int64
+	if err := tx.Unscoped().Model(&models.User{}).
+		Where("username = ? OR email = ?", username, email).Count(&taken).Error; err != nil {
+		return nil, err
+	}
+	if taken > 0 {
+		suffix, err := auth.GenerateRandomToken(6)
+		if err != nil {
+			return nil, err
+		}
+		username = models.DeletedUserUsername + "_" + suffix
+		email = "deleted+" + suffix + "@users.invalid"
+	}
+
+	user = models.User{
+		Username:      username,
+		Email:         email,
+		Nickname:      models.DeletedUserNickname,
+		Password:      "!",
+		Role:          "guest",
+		EmailVerified: true,
+		IsPlaceholder: true,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
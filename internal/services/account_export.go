@@ -0,0 +1,119 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// exportMessageBatchSize 导出消息时每次从数据库读取的条数
+const exportMessageBatchSize = 500
+
+// ExportAccount 将用户的个人数据写入 zip 压缩包
+// 包含 profile.json（资料和外部登录身份）、sessions.json（登录会话）、
+// memberships.json（加入的房间）和 messages.json（发送过的消息）
+func ExportAccount(w io.Writer, user *models.User) error {
+	archive := zip.NewWriter(w)
+
+	var identities []models.UserIdentity
+	if err := database.DB.Where("user_id = ?", user.ID).Order("id").Find(&identities).Error; err != nil {
+		return err
+	}
+	identityList := make([]map[string]interface{}, 0, len(identities))
+	for _, identity := range identities {
+		identityList = append(identityList, map[string]interface{}{
+			"provider":   identity.Provider,
+			"email":      identity.Email,
+			"created_at": identity.CreatedAt,
+		})
+	}
+
+	profile := user.ToJSON()
+	profile["created_at"] = user.CreatedAt
+	profile["identities"] = identityList
+	if err := writeExportFile(archive, "profile.json", profile); err != nil {
+		return err
+	}
+
+	var sessions []models.Session
+	if err := database.DB.Where("user_id = ?", user.ID).Order("created_at").Find(&sessions).Error; err != nil {
+		return err
+	}
+	if err := writeExportFile(archive, "sessions.json", sessions); err != nil {
+		return err
+	}
+
+	var members []models.RoomMember
+	if err := database.DB.Preload("Room").Where("user_id = ?", user.ID).Order("joined_at").Find(&members).Error; err != nil {
+		return err
+	}
+	memberships := make([]map[string]interface{}, 0, len(members))
+	for _, member := range members {
+		memberships = append(memberships, map[string]interface{}{
+			"room_id":    member.RoomID,
+			"room_name":  member.Room.Name,
+			"role":       member.Role,
+			"is_creator": member.Room.CreatorID == user.ID,
+			"joined_at":  member.JoinedAt,
+		})
+	}
+	if err := writeExportFile(archive, "memberships.json", memberships); err != nil {
+		return err
+	}
+
+	messages := make([]map[string]interface{}, 0)
+	var batch []models.Message
+	err := database.DB.Where("user_id = ?", user.ID).Order("id").
+		FindInBatches(&batch, exportMessageBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, message := range batch {
+				messages = append(messages, exportMessage(&message))
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+	if err := writeExportFile(archive, "messages.json", messages); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// exportMessage 导出单条消息，不包含发送者信息
+func exportMessage(message *models.Message) map[string]interface{} {
+	result := map[string]interface{}{
+		"id":         message.ID,
+		"room_id":    message.RoomID,
+		"type":       message.Type,
+		"content":    message.Content,
+		"created_at": message.CreatedAt,
+	}
+	if message.FileURL != "" {
+		result["file_url"] = message.FileURL
+		result["file_name"] = message.FileName
+		result["file_size"] = message.FileSize
+	}
+	return result
+}
+
+// writeExportFile 将数据以 JSON 格式写入压缩包中的一个文件
+func writeExportFile(archive *zip.Writer, name string, data interface{}) error {
+	file, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidParticipants 私信会话的参与者数量不符合要求
+var ErrInvalidParticipants = errors.New("invalid direct conversation participants")
+
+// MaxDirectParticipants 私信会话最多的参与者数量（包括发起人）
+const MaxDirectParticipants = 10
+
+// FindOrCreateDirectConversation 按参与者集合查找私信会话，不存在时创建
+// 同一组参与者始终得到同一个会话，与发起人和参与者顺序无关；返回值 created 表示是否新建
+func FindOrCreateDirectConversation(creator *models.User, others []models.User) (*models.Room, bool, error) {
+	participants := []models.User{*creator}
+	userIDs := []uint{creator.ID}
+	for _, user := range others {
+		if user.ID != creator.ID {
+			participants = append(participants, user)
+			userIDs = append(userIDs, user.ID)
+		}
+	}
+	if len(userIDs) < 2 || len(userIDs) > MaxDirectParticipants {
+		return nil, false, ErrInvalidParticipants
+	}
+
+	key := directKey(userIDs)
+	room, err := findDirectConversation(key)
+	if err == nil {
+		return room, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	names := make([]string, 0, len(participants))
+	for _, user := range participants {
+		names = append(names, user.Nickname)
+	}
+	created := models.Room{
+		Name:       truncateRunes(strings.Join(names, "、"), 100),
+		IsPrivate:  true,
+		MaxMembers: len(participants),
+		CreatorID:  creator.ID,
+		IsDirect:   true,
+		DirectKey:  &key,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&created).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, user := range participants {
+			member := models.RoomMember{
+				RoomID:   created.ID,
+				UserID:   user.ID,
+				Role:     "member",
+				JoinedAt: now,
+			}
+			if err := tx.Create(&member).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// 并发创建同一会话时唯一索引冲突，返回另一个请求创建的会话
+		if room, findErr := findDirectConversation(key); findErr == nil {
+			return room, false, nil
+		}
+		return nil, false, err
+	}
+
+	return &created, true, nil
+}
+
+// findDirectConversation 按参与者键查找私信会话
+func findDirectConversation(key string) (*models.Room, error) {
+	var room models.Room
+	if err := database.DB.Where("is_direct = ? AND direct_key = ?", true, key).First(&room).Error; err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// directKey 将参与者 ID 排序后拼接，作为会话的唯一键
+func directKey(userIDs []uint) string {
+	sorted := append([]uint(nil), userIDs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	parts := make([]string, len(sorted))
+	for i, id := range sorted {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// truncateRunes 按字符截断字符串
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"fmt"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"log"
+	"time"
+)
+
+// AllowGuestMessage 访客发送消息的限流，每个访客每分钟最多 GUEST_MESSAGE_LIMIT 条
+func AllowGuestMessage(userID uint) (bool, time.Duration, error) {
+	return AllowRequest(fmt.Sprintf("guest:message:%d", userID), config.AppConfig.Guest.MessageLimit, time.Minute)
+}
+
+// RunGuestCleanup 定期删除无活动的访客
+func RunGuestCleanup(hub *Hub, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		DeleteIdleGuests(hub)
+		<-ticker.C
+	}
+}
+
+// DeleteIdleGuests 删除超过 GUEST_IDLE_TIMEOUT 没有活动的访客，返回删除的数量
+// 最近活动时间取创建时间、最后在线时间和会话最近使用时间中最晚的一个，仍有 WebSocket 连接的访客不会被删除
+func DeleteIdleGuests(hub *Hub) int {
+	cutoff := time.Now().Add(-time.Duration(config.AppConfig.Guest.IdleTimeout) * time.Minute)
+
+	var userIDs []uint
+	if err := database.DB.Model(&models.User{}).
+		Where("is_guest = ? AND created_at < ?", true, cutoff).
+		Where("last_seen IS NULL OR last_seen < ?", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM sessions WHERE sessions.user_id = users.id AND sessions.last_used_at >= ?)", cutoff).
+		Pluck("id", &userIDs).Error; err != nil {
+		log.Printf("Failed to load idle guests: %v", err)
+		return 0
+	}
+
+	deleted := 0
+	for _, userID := range userIDs {
+		if hub.IsUserConnected(userID) {
+			continue
+		}
+		if err := DeleteAccount(userID); err != nil {
+			log.Printf("Failed to delete guest %d: %v", userID, err)
+			continue
+		}
+		deleted++
+	}
+	return deleted
+}
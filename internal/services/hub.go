@@ -18,12 +18,14 @@ type WebSocketConnection interface {
 
 // Client WebSocket 客户端
 type Client struct {
-	ID     string
-	UserID uint
-	RoomID uint
-	Conn   WebSocketConnection
-	Send   chan []byte
-	Hub    *Hub
+	ID        string
+	UserID    uint
+	RoomID    uint           // 只能在客户端自己的读协程中通过 Hub.MoveClient 修改，其他协程持有 Hub 的锁读取
+	SessionID string         // 建立连接时使用的登录会话，会话注销后断开连接
+	APIKey    *models.APIKey // 机器人使用 API 密钥连接时的密钥，密钥吊销后断开连接
+	Conn      WebSocketConnection
+	Send      chan []byte
+	Hub       *Hub
 }
 
 // Hub WebSocket 连接管理中心
@@ -34,8 +36,8 @@ type Hub struct {
 	// 按房间分组的客户端
 	rooms map[uint]map[*Client]bool
 
-	// 按用户分组的客户端
-	users map[uint]*Client
+	// 按用户分组的客户端，同一用户可以同时有多个连接
+	users map[uint]map[*Client]bool
 
 	// 注册客户端的通道
 	register chan *Client
@@ -46,6 +48,11 @@ type Hub struct {
 	// 广播消息的通道
 	broadcast chan *BroadcastMessage
 
+	// 请求停止的通道，以及 Run 退出后关闭的通道
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+
 	// 互斥锁
 	mutex sync.RWMutex
 }
@@ -69,128 +76,197 @@ func NewHub() *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
 		rooms:      make(map[uint]map[*Client]bool),
-		users:      make(map[uint]*Client),
+		users:      make(map[uint]map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		broadcast:  make(chan *BroadcastMessage),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
 	}
 }
 
-// Run 运行 Hub
+// Run 运行 Hub，调用 Stop 后断开所有连接，处理完它们的注销后退出
 func (h *Hub) Run() {
+	defer close(h.stopped)
+
+	stop := h.stop
+	stopping := false
 	for {
+		// 只有 Run 所在的协程修改客户端列表，这里读取不需要加锁
+		if stopping && len(h.clients) == 0 {
+			return
+		}
+
 		select {
 		case client := <-h.register:
 			h.registerClient(client)
+			if stopping {
+				client.Conn.Close()
+			}
 
 		case client := <-h.unregister:
 			h.unregisterClient(client)
 
 		case message := <-h.broadcast:
 			h.broadcastToRoom(message.RoomID, message.Message)
+
+		case <-stop:
+			stop = nil
+			stopping = true
+			h.disconnect(func(client *Client) bool {
+				return true
+			})
 		}
 	}
 }
 
+// Stop 停止 Hub，断开所有连接并等待 Run 退出后返回
+func (h *Hub) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.stop)
+	})
+	<-h.stopped
+}
+
 // registerClient 注册客户端
+// 修改连接映射时持有写锁，通知前释放，避免广播时再次加锁导致死锁
 func (h *Hub) registerClient(client *Client) {
 	h.mutex.Lock()
-	defer h.mutex.Unlock()
 
 	// 添加到客户端列表
 	h.clients[client] = true
 
 	// 添加到房间
-	if h.rooms[client.RoomID] == nil {
-		h.rooms[client.RoomID] = make(map[*Client]bool)
-	}
-	h.rooms[client.RoomID][client] = true
+	roomID := client.RoomID
+	h.addToRoom(client, roomID)
 
 	// 添加到用户映射
-	h.users[client.UserID] = client
+	if h.users[client.UserID] == nil {
+		h.users[client.UserID] = make(map[*Client]bool)
+	}
+	h.users[client.UserID][client] = true
+
+	h.mutex.Unlock()
 
 	// 设置用户在线状态
-	SetUserOnline(client.UserID, client.RoomID)
+	SetUserOnline(client.UserID, roomID)
 
 	// 更新数据库中的用户在线状态
 	database.DB.Model(&models.User{}).Where("id = ?", client.UserID).Update("is_online", true)
 
-	log.Printf("Client registered: UserID=%d, RoomID=%d", client.UserID, client.RoomID)
+	log.Printf("Client registered: UserID=%d, RoomID=%d", client.UserID, roomID)
 
 	// 通知房间内其他用户有新用户加入
-	h.notifyUserJoined(client)
+	h.notifyUserJoined(client, roomID)
 
 	// 发送在线用户列表给新加入的用户
-	h.sendOnlineUsers(client)
+	h.sendOnlineUsers(client, roomID)
 }
 
 // unregisterClient 注销客户端
 func (h *Hub) unregisterClient(client *Client) {
 	h.mutex.Lock()
-	defer h.mutex.Unlock()
 
-	if _, ok := h.clients[client]; ok {
-		// 从客户端列表中移除
-		delete(h.clients, client)
-
-		// 从房间中移除
-		if room, exists := h.rooms[client.RoomID]; exists {
-			delete(room, client)
-			if len(room) == 0 {
-				delete(h.rooms, client.RoomID)
-			}
+	if _, ok := h.clients[client]; !ok {
+		h.mutex.Unlock()
+		return
+	}
+
+	// 从客户端列表中移除
+	delete(h.clients, client)
+
+	// 从房间中移除
+	roomID := client.RoomID
+	h.removeFromRoom(client, roomID)
+
+	// 从用户映射中移除，用户的其他连接不受影响
+	if connections, exists := h.users[client.UserID]; exists {
+		delete(connections, client)
+		if len(connections) == 0 {
+			delete(h.users, client.UserID)
 		}
+	}
 
-		// 从用户映射中移除
-		delete(h.users, client.UserID)
+	// 关闭发送通道
+	close(client.Send)
 
-		// 关闭发送通道
-		close(client.Send)
+	h.mutex.Unlock()
 
-		// 设置用户离线状态
-		SetUserOffline(client.UserID)
+	// 设置用户离线状态
+	SetUserOffline(client.UserID)
 
-		// 更新数据库中的用户离线状态
-		now := time.Now()
-		database.DB.Model(&models.User{}).Where("id = ?", client.UserID).Updates(map[string]interface{}{
-			"is_online": false,
-			"last_seen": &now,
-		})
+	// 更新数据库中的用户离线状态
+	now := time.Now()
+	database.DB.Model(&models.User{}).Where("id = ?", client.UserID).Updates(map[string]interface{}{
+		"is_online": false,
+		"last_seen": &now,
+	})
 
-		log.Printf("Client unregistered: UserID=%d, RoomID=%d", client.UserID, client.RoomID)
+	log.Printf("Client unregistered: UserID=%d, RoomID=%d", client.UserID, roomID)
 
-		// 通知房间内其他用户有用户离开
-		h.notifyUserLeft(client)
+	// 通知房间内其他用户有用户离开
+	h.notifyUserLeft(client, roomID)
+}
+
+// MoveClient 将客户端切换到另一个房间，之后只接收新房间的广播
+// 持有写锁同时修改房间分组和客户端的房间ID，断开连接等操作持有读锁读取房间ID
+func (h *Hub) MoveClient(client *Client, roomID uint) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.clients[client]; ok {
+		h.removeFromRoom(client, client.RoomID)
+		h.addToRoom(client, roomID)
 	}
+	client.RoomID = roomID
 }
 
-// broadcastToRoom 向房间广播消息
-func (h *Hub) broadcastToRoom(roomID uint, message interface{}) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
+// addToRoom 将客户端加入房间分组，调用方需要持有写锁
+func (h *Hub) addToRoom(client *Client, roomID uint) {
+	if h.rooms[roomID] == nil {
+		h.rooms[roomID] = make(map[*Client]bool)
+	}
+	h.rooms[roomID][client] = true
+}
 
+// removeFromRoom 将客户端移出房间分组，调用方需要持有写锁
+func (h *Hub) removeFromRoom(client *Client, roomID uint) {
 	if room, exists := h.rooms[roomID]; exists {
-		jsonData, err := json.Marshal(message)
-		if err != nil {
-			log.Printf("Error marshaling message: %v", err)
-			return
+		delete(room, client)
+		if len(room) == 0 {
+			delete(h.rooms, roomID)
 		}
+	}
+}
 
-		for client := range room {
-			select {
-			case client.Send <- jsonData:
-			default:
-				// 如果发送失败，关闭客户端
-				close(client.Send)
-				delete(h.clients, client)
-				delete(room, client)
-			}
+// broadcastToRoom 向房间广播消息
+func (h *Hub) broadcastToRoom(roomID uint, message interface{}) {
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+
+	var slowClients []*Client
+
+	h.mutex.RLock()
+	for client := range h.rooms[roomID] {
+		select {
+		case client.Send <- jsonData:
+		default:
+			slowClients = append(slowClients, client)
 		}
 	}
+	h.mutex.RUnlock()
+
+	// 如果发送失败，关闭客户端连接，读协程退出时注销客户端
+	for _, client := range slowClients {
+		client.Conn.Close()
+	}
 }
 
 // notifyUserJoined 通知用户加入
-func (h *Hub) notifyUserJoined(client *Client) {
+func (h *Hub) notifyUserJoined(client *Client, roomID uint) {
 	// 获取用户信息
 	var user models.User
 	if err := database.DB.First(&user, client.UserID).Error; err != nil {
@@ -199,31 +275,31 @@ func (h *Hub) notifyUserJoined(client *Client) {
 
 	message := WebSocketMessage{
 		Type:   "user_joined",
-		RoomID: client.RoomID,
+		RoomID: roomID,
 		Data: map[string]interface{}{
 			"user": user.ToJSON(),
 		},
 	}
 
-	h.broadcastToRoom(client.RoomID, message)
+	h.broadcastToRoom(roomID, message)
 }
 
 // notifyUserLeft 通知用户离开
-func (h *Hub) notifyUserLeft(client *Client) {
+func (h *Hub) notifyUserLeft(client *Client, roomID uint) {
 	message := WebSocketMessage{
 		Type:   "user_left",
-		RoomID: client.RoomID,
+		RoomID: roomID,
 		Data: map[string]interface{}{
 			"user_id": client.UserID,
 		},
 	}
 
-	h.broadcastToRoom(client.RoomID, message)
+	h.broadcastToRoom(roomID, message)
 }
 
 // sendOnlineUsers 发送在线用户列表
-func (h *Hub) sendOnlineUsers(client *Client) {
-	onlineUsers, err := GetOnlineUsers(client.RoomID)
+func (h *Hub) sendOnlineUsers(client *Client, roomID uint) {
+	onlineUsers, err := GetOnlineUsers(roomID)
 	if err != nil {
 		log.Printf("Error getting online users: %v", err)
 		return
@@ -242,38 +318,196 @@ func (h *Hub) sendOnlineUsers(client *Client) {
 
 	message := WebSocketMessage{
 		Type:   "online_users",
-		RoomID: client.RoomID,
+		RoomID: roomID,
 		Data: map[string]interface{}{
 			"users": userList,
 		},
 	}
 
+	h.SendToClient(client, message)
+}
+
+// SendToClient 向单个客户端发送消息，客户端已注销时忽略
+func (h *Hub) SendToClient(client *Client, message interface{}) {
 	jsonData, err := json.Marshal(message)
 	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if _, ok := h.clients[client]; !ok {
 		return
 	}
 
 	select {
 	case client.Send <- jsonData:
 	default:
-		close(client.Send)
 	}
 }
 
+// SendError 向客户端发送错误消息
+func (c *Client) SendError(message string) {
+	c.Hub.SendToClient(c, WebSocketMessage{
+		Type:   "error",
+		RoomID: c.RoomID,
+		Data: map[string]interface{}{
+			"error": message,
+		},
+	})
+}
+
+// Allows 检查客户端在指定房间的权限，使用 JWT 登录的用户不受 API 密钥权限限制
+func (c *Client) Allows(scope string, roomID uint) bool {
+	return c.APIKey == nil || c.APIKey.Allows(scope, roomID)
+}
+
+// DisconnectSessions 断开属于指定登录会话的所有连接
+func (h *Hub) DisconnectSessions(sessionIDs ...string) {
+	if len(sessionIDs) == 0 {
+		return
+	}
+
+	targets := make(map[string]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		targets[id] = true
+	}
+
+	h.disconnect(func(client *Client) bool {
+		return client.SessionID != "" && targets[client.SessionID]
+	})
+}
+
+// DisconnectAPIKey 断开使用指定 API 密钥建立的所有连接
+func (h *Hub) DisconnectAPIKey(apiKeyID uint) {
+	h.disconnect(func(client *Client) bool {
+		return client.APIKey != nil && client.APIKey.ID == apiKeyID
+	})
+}
+
+// DisconnectUser 断开用户的所有连接
+func (h *Hub) DisconnectUser(userID uint) {
+	h.disconnect(func(client *Client) bool {
+		return client.UserID == userID
+	})
+}
+
+// DisconnectUserFromRoom 断开用户在指定房间的连接，用户在其他房间的连接不受影响
+func (h *Hub) DisconnectUserFromRoom(userID, roomID uint) {
+	h.disconnect(func(client *Client) bool {
+		return client.UserID == userID && client.RoomID == roomID
+	})
+}
+
+// CloseRoom 向房间内的连接发送通知后关闭连接
+// 注销客户端会关闭发送通道，写协程发完排队的消息后发送关闭帧，客户端可以据此区分正常关闭和网络断开
+func (h *Hub) CloseRoom(roomID uint, message interface{}) {
+	h.mutex.RLock()
+	var clients []*Client
+	for client := range h.clients {
+		if client.RoomID == roomID {
+			clients = append(clients, client)
+		}
+	}
+	h.mutex.RUnlock()
+
+	for _, client := range clients {
+		h.SendToClient(client, message)
+		h.UnregisterClient(client)
+	}
+}
+
+// disconnect 断开满足条件的所有连接
+// 关闭底层连接后读协程退出并注销客户端
+func (h *Hub) disconnect(match func(client *Client) bool) {
+	h.mutex.RLock()
+	var clients []*Client
+	for client := range h.clients {
+		if match(client) {
+			clients = append(clients, client)
+		}
+	}
+	h.mutex.RUnlock()
+
+	for _, client := range clients {
+		client.Conn.Close()
+	}
+}
+
+// SendToUsers 向指定用户的所有连接发送消息，不限房间
+func (h *Hub) SendToUsers(userIDs []uint, message interface{}) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	targets := make(map[uint]bool, len(userIDs))
+	for _, id := range userIDs {
+		targets[id] = true
+	}
+
+	h.mutex.RLock()
+	var clients []*Client
+	for client := range h.clients {
+		if targets[client.UserID] {
+			clients = append(clients, client)
+		}
+	}
+	h.mutex.RUnlock()
+
+	for _, client := range clients {
+		h.SendToClient(client, message)
+	}
+}
+
+// IsUserConnected 用户当前是否有 WebSocket 连接
+func (h *Hub) IsUserConnected(userID uint) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return len(h.users[userID]) > 0
+}
+
+// OnlineUserIDs 返回当前有连接在房间中的用户，不依赖 Redis
+func (h *Hub) OnlineUserIDs(roomID uint) []uint {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	seen := make(map[uint]bool)
+	var userIDs []uint
+	for client := range h.clients {
+		if client.RoomID == roomID && !seen[client.UserID] {
+			seen[client.UserID] = true
+			userIDs = append(userIDs, client.UserID)
+		}
+	}
+	return userIDs
+}
+
 // BroadcastMessage 广播消息到房间
 func (h *Hub) BroadcastMessage(roomID uint, message interface{}) {
-	h.broadcast <- &BroadcastMessage{
-		RoomID:  roomID,
-		Message: message,
+	select {
+	case h.broadcast <- &BroadcastMessage{RoomID: roomID, Message: message}:
+	case <-h.stopped:
 	}
 }
 
 // RegisterClient 注册客户端
+// Hub 已停止时直接关闭连接，读写协程随之退出
 func (h *Hub) RegisterClient(client *Client) {
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.stopped:
+		client.Conn.Close()
+		close(client.Send)
+	}
 }
 
 // UnregisterClient 注销客户端
 func (h *Hub) UnregisterClient(client *Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.stopped:
+	}
 }
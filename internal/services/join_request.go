@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 加入申请相关错误
+var (
+	ErrJoinRequestPending = errors.New("a join request is already pending")
+	ErrJoinRequestClosed  = errors.New("join request has already been handled or has expired")
+)
+
+// joinRequestTTL 加入申请等待处理的时间
+func joinRequestTTL() time.Duration {
+	hours := config.AppConfig.Room.JoinRequestExpire
+	if hours <= 0 {
+		hours = 72
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// CreateJoinRequest 提交加入房间的申请，并实时通知在线的房间管理员
+// 调用方负责检查房间是否接受加入申请
+func CreateJoinRequest(hub *Hub, room *models.Room, user *models.User, message string) (*models.RoomJoinRequest, error) {
+	request := models.RoomJoinRequest{
+		RoomID:    room.ID,
+		UserID:    user.ID,
+		Message:   message,
+		Status:    models.JoinRequestPending,
+		ExpiresAt: time.Now().Add(joinRequestTTL()),
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if room.IsArchived() {
+			return ErrRoomArchived
+		}
+		if room.IsBanned(tx, user.ID) {
+			return ErrBanned
+		}
+		if room.IsMember(tx, user.ID) {
+			return ErrAlreadyMember
+		}
+
+		var pending int64
+		if err := models.PendingJoinRequests(tx, room.ID).Where("user_id = ?", user.ID).Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrJoinRequestPending
+		}
+
+		return tx.Create(&request).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	request.User = *user
+
+	hub.SendToUsers(roomAdminIDs(room), WebSocketMessage{
+		Type:   "join_request",
+		RoomID: room.ID,
+		Data: map[string]interface{}{
+			"request": request.ToJSON(),
+		},
+	})
+	return &request, nil
+}
+
+// ApproveJoinRequest 批准加入申请，申请人成为房间成员并收到通知
+// 申请已处理或已过期时返回 ErrJoinRequestClosed，房间已满、申请人被封禁等情况返回加入房间的错误
+func ApproveJoinRequest(hub *Hub, room *models.Room, request *models.RoomJoinRequest, reviewer *models.User) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := reviewJoinRequest(tx, request, reviewer, models.JoinRequestApproved); err != nil {
+			return err
+		}
+		return addRoomMember(tx, room, &request.User, "member")
+	})
+	if err != nil {
+		return err
+	}
+
+	hub.SendToUsers([]uint{request.UserID}, WebSocketMessage{
+		Type:   "join_request_approved",
+		RoomID: room.ID,
+		Data: map[string]interface{}{
+			"request": request.ToJSON(),
+			"room":    room.ToJSON(database.DB),
+		},
+	})
+	return nil
+}
+
+// DenyJoinRequest 拒绝加入申请并通知申请人
+func DenyJoinRequest(hub *Hub, room *models.Room, request *models.RoomJoinRequest, reviewer *models.User) error {
+	if err := reviewJoinRequest(database.DB, request, reviewer, models.JoinRequestDenied); err != nil {
+		return err
+	}
+
+	hub.SendToUsers([]uint{request.UserID}, WebSocketMessage{
+		Type:   "join_request_denied",
+		RoomID: room.ID,
+		Data: map[string]interface{}{
+			"request": request.ToJSON(),
+		},
+	})
+	return nil
+}
+
+// reviewJoinRequest 将待处理的申请标记为已处理
+// 只更新仍在等待且未过期的申请，并发处理同一申请时只有一个请求生效
+func reviewJoinRequest(tx *gorm.DB, request *models.RoomJoinRequest, reviewer *models.User, status string) error {
+	now := time.Now()
+	result := tx.Model(&models.RoomJoinRequest{}).
+		Where("id = ? AND status = ? AND expires_at > ?", request.ID, models.JoinRequestPending, now).
+		Updates(map[string]interface{}{
+			"status":         status,
+			"reviewed_by_id": reviewer.ID,
+			"reviewed_at":    now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrJoinRequestClosed
+	}
+
+	request.Status = status
+	request.ReviewedByID = &reviewer.ID
+	request.ReviewedAt = &now
+	return nil
+}
+
+// roomAdminIDs 返回房间创建者和房间管理员
+func roomAdminIDs(room *models.Room) []uint {
+	var userIDs []uint
+	database.DB.Model(&models.RoomMember{}).
+		Where("room_id = ? AND role = ?", room.ID, "admin").
+		Pluck("user_id", &userIDs)
+
+	for _, id := range userIDs {
+		if id == room.CreatorID {
+			return userIDs
+		}
+	}
+	return append(userIDs, room.CreatorID)
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+)
+
+// PostMessage 保存文本消息并广播到房间
+// WebSocket 和 REST 接口共用，调用方负责检查发言权限
+func PostMessage(hub *Hub, roomID, userID uint, content string) (*models.Message, error) {
+	// 归档的房间只读
+	var archived int64
+	if err := database.DB.Model(&models.Room{}).
+		Where("id = ? AND archived_at IS NOT NULL", roomID).Count(&archived).Error; err != nil {
+		return nil, err
+	}
+	if archived > 0 {
+		return nil, ErrRoomArchived
+	}
+
+	// 创建消息记录
+	message := models.Message{
+		RoomID:  roomID,
+		UserID:  userID,
+		Type:    models.MessageTypeText,
+		Content: content,
+	}
+
+	// 保存到数据库
+	if err := database.DB.Create(&message).Error; err != nil {
+		return nil, err
+	}
+
+	// 预加载用户信息
+	database.DB.Preload("User").First(&message, message.ID)
+
+	// 缓存消息到 Redis
+	CacheMessage(roomID, message.ToJSON())
+
+	// 广播消息
+	hub.BroadcastMessage(roomID, WebSocketMessage{
+		Type:   "message",
+		RoomID: roomID,
+		Data:   message.ToJSON(),
+	})
+
+	return &message, nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// KickMember 将成员移出房间并断开其在该房间的连接，被移出的用户可以重新加入
+func KickMember(hub *Hub, room *models.Room, member *models.RoomMember, moderator *models.User) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(member).Error; err != nil {
+			return err
+		}
+		return tx.Create(models.CreateSystemMessage(room.ID,
+			member.User.Nickname+" 被 "+moderator.Nickname+" 移出了房间")).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	hub.DisconnectUserFromRoom(member.UserID, room.ID)
+	return nil
+}
+
+// BanUser 封禁用户，用户是成员时同时移出房间并断开其在该房间的连接
+// 重复封禁时更新原有记录的原因和期限，expiresAt 为空表示永久封禁
+func BanUser(hub *Hub, room *models.Room, user, moderator *models.User, reason string, expiresAt *time.Time) (*models.RoomBan, error) {
+	ban := models.RoomBan{
+		RoomID: room.ID,
+		UserID: user.ID,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where(&ban).Assign(map[string]interface{}{
+			"banned_by_id": moderator.ID,
+			"reason":       reason,
+			"expires_at":   expiresAt,
+		}).FirstOrCreate(&ban).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("room_id = ? AND user_id = ?", room.ID, user.ID).Delete(&models.RoomMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		return tx.Create(models.CreateSystemMessage(room.ID,
+			user.Nickname+" 被 "+moderator.Nickname+" 封禁")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hub.DisconnectUserFromRoom(user.ID, room.ID)
+
+	ban.User = *user
+	return &ban, nil
+}
+
+// UnbanUser 解除封禁，用户没有被封禁时返回 gorm.ErrRecordNotFound
+func UnbanUser(room *models.Room, userID uint) error {
+	result := database.DB.Where("room_id = ? AND user_id = ?", room.ID, userID).Delete(&models.RoomBan{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"strconv"
+	"time"
+)
+
+// OIDCStateTTL 外部登录授权请求的有效期
+const OIDCStateTTL = 10 * time.Minute
+
+// OIDCLoginCodeTTL 外部登录完成后兑换令牌的一次性登录码有效期
+const OIDCLoginCodeTTL = time.Minute
+
+// 外部登录相关错误
+var (
+	ErrInvalidOIDCState     = errors.New("invalid or expired login state")
+	ErrInvalidOIDCLoginCode = errors.New("invalid or expired login code")
+)
+
+// OIDCState 发起授权请求时保存的状态，回调时取出校验
+type OIDCState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// SaveOIDCState 保存授权请求状态
+func SaveOIDCState(state string, data OIDCState) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return putOnce(fmt.Sprintf("oidc:state:%s", auth.HashToken(state)), string(value), OIDCStateTTL)
+}
+
+// ConsumeOIDCState 取出授权请求状态，每个状态只能使用一次
+func ConsumeOIDCState(state string) (*OIDCState, error) {
+	value, ok, err := takeOnce(fmt.Sprintf("oidc:state:%s", auth.HashToken(state)))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidOIDCState
+	}
+
+	var data OIDCState
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// IssueOIDCLoginCode 为完成外部登录的用户签发一次性登录码
+// 回调通过浏览器跳转完成，令牌不直接放在地址中，前端使用登录码兑换令牌
+func IssueOIDCLoginCode(userID uint) (string, error) {
+	code, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("oidc:login:%s", auth.HashToken(code))
+	if err := putOnce(key, strconv.FormatUint(uint64(userID), 10), OIDCLoginCodeTTL); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// RedeemOIDCLoginCode 兑换一次性登录码，返回用户 ID
+func RedeemOIDCLoginCode(code string) (uint, error) {
+	value, ok, err := takeOnce(fmt.Sprintf("oidc:login:%s", auth.HashToken(code)))
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrInvalidOIDCLoginCode
+	}
+
+	userID, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(userID), nil
+}
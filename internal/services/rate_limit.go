@@ -0,0 +1,59 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// AllowRequest 固定窗口限流，window 时间内最多允许 limit 次请求
+// 超出限制时返回需要等待的时间
+func AllowRequest(key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	count, ttl, err := incrCounter(fmt.Sprintf("ratelimit:%s", key), window)
+	if err != nil {
+		return false, 0, err
+	}
+	return count <= int64(limit), retryAfter(count, limit, ttl), nil
+}
+
+// incrCounter 计数加一并返回计数和剩余有效期，计数在首次创建后 window 到期
+func incrCounter(key string, window time.Duration) (int64, time.Duration, error) {
+	if RedisClient == nil {
+		count, ttl := localStore.incr(key, window) // Redis 未连接，使用进程内存储
+		return count, ttl, nil
+	}
+
+	count, err := RedisClient.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// 首次计数时设置窗口过期时间
+	if count == 1 {
+		if err := RedisClient.Expire(ctx, key, window).Err(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	ttl, err := RedisClient.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	if ttl < 0 {
+		// 键没有过期时间（如设置过期时间前进程退出），重新设置
+		RedisClient.Expire(ctx, key, window)
+		ttl = window
+	}
+
+	return count, ttl, nil
+}
+
+// retryAfter 计算超出限制时需要等待的时间
+func retryAfter(count int64, limit int, ttl time.Duration) time.Duration {
+	if count <= int64(limit) {
+		return 0
+	}
+	if ttl < time.Second {
+		return time.Second
+	}
+	return ttl
+}
@@ -27,6 +27,9 @@ func InitRedis() error {
 	// 测试连接
 	_, err := RedisClient.Ping(ctx).Result()
 	if err != nil {
+		// 连接失败时置空客户端，让依赖 Redis 的功能走降级逻辑
+		RedisClient.Close()
+		RedisClient = nil
 		return fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 房间相关错误
+var (
+	ErrAlreadyMember = errors.New("already a member of this room")
+	ErrRoomFull      = errors.New("room is full")
+	ErrInvalidInvite = errors.New("invite is invalid or has expired")
+	ErrBanned        = errors.New("banned from this room")
+	ErrRoomArchived  = errors.New("room is archived")
+)
+
+// inviteCodeBytes 邀请码的随机字节数，编码后为 12 个字符
+const inviteCodeBytes = 9
+
+// JoinRoom 将用户加入房间
+// 调用方负责检查房间密码等加入条件
+func JoinRoom(room *models.Room, user *models.User, role string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return addRoomMember(tx, room, user, role)
+	})
+}
+
+// addRoomMember 检查房间状态、封禁和人数后添加成员，并创建加入房间的系统消息
+func addRoomMember(tx *gorm.DB, room *models.Room, user *models.User, role string) error {
+	if room.IsArchived() {
+		return ErrRoomArchived
+	}
+
+	// 被封禁的用户不能加入，邀请码也不例外
+	if room.IsBanned(tx, user.ID) {
+		return ErrBanned
+	}
+
+	// 检查用户是否已经是房间成员
+	if room.IsMember(tx, user.ID) {
+		return ErrAlreadyMember
+	}
+
+	// 检查房间是否已满
+	if room.GetMemberCount(tx) >= int64(room.MaxMembers) {
+		return ErrRoomFull
+	}
+
+	roomMember := models.RoomMember{
+		RoomID:   room.ID,
+		UserID:   user.ID,
+		Role:     role,
+		JoinedAt: time.Now(),
+	}
+	if err := tx.Create(&roomMember).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(models.CreateSystemMessage(room.ID, user.Nickname+" 加入了房间")).Error
+}
+
+// CreateRoomInvite 为房间创建邀请码
+func CreateRoomInvite(room *models.Room, creatorID uint, role string, maxUses int, expiresAt *time.Time) (*models.RoomInvite, error) {
+	code, err := auth.GenerateRandomToken(inviteCodeBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	invite := models.RoomInvite{
+		Code:      code,
+		RoomID:    room.ID,
+		CreatorID: creatorID,
+		Role:      role,
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt,
+	}
+	if err := database.DB.Create(&invite).Error; err != nil {
+		return nil, err
+	}
+
+	return &invite, nil
+}
+
+// AcceptRoomInvite 使用邀请码加入房间，不需要房间密码
+// 使用次数在同一事务中以条件更新递增，并发接受时不会超过次数上限
+func AcceptRoomInvite(invite *models.RoomInvite, user *models.User) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := addRoomMember(tx, &invite.Room, user, invite.Role); err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.RoomInvite{}).
+			Where("id = ? AND revoked_at IS NULL", invite.ID).
+			Where("max_uses = 0 OR uses < max_uses").
+			Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+			Update("uses", gorm.Expr("uses + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidInvite
+		}
+		return nil
+	})
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrRestoreExpired 房间删除超过保留期，不能再恢复
+var ErrRestoreExpired = errors.New("room restore window has expired")
+
+// RoomRestoreDeadline 删除的房间可以恢复的截止时间，房间未删除或永久保留时返回 nil
+func RoomRestoreDeadline(room *models.Room) *time.Time {
+	days := config.AppConfig.Room.RestoreDays
+	if !room.DeletedAt.Valid || days <= 0 {
+		return nil
+	}
+	deadline := room.DeletedAt.Time.AddDate(0, 0, days)
+	return &deadline
+}
+
+// BroadcastRoomUpdated 通知房间内的连接房间信息已修改
+func BroadcastRoomUpdated(hub *Hub, room *models.Room) {
+	hub.BroadcastMessage(room.ID, WebSocketMessage{
+		Type:   "room_updated",
+		RoomID: room.ID,
+		Data: map[string]interface{}{
+			"room": room.ToJSON(database.DB),
+		},
+	})
+}
+
+// DeleteRoom 软删除房间并关闭房间内的连接，保留期内可以恢复
+// 成员、消息和邀请码保留到房间被彻底删除
+func DeleteRoom(hub *Hub, room *models.Room) error {
+	if err := database.DB.Delete(room).Error; err != nil {
+		return err
+	}
+	// 读取删除时间，用于计算恢复截止时间
+	database.DB.Unscoped().Select("deleted_at").First(room, room.ID)
+
+	hub.CloseRoom(room.ID, WebSocketMessage{
+		Type:   "room_deleted",
+		RoomID: room.ID,
+		Data: map[string]interface{}{
+			"room_id":          room.ID,
+			"restorable_until": RoomRestoreDeadline(room),
+		},
+	})
+	return nil
+}
+
+// RestoreRoom 恢复保留期内删除的房间，成员和消息随房间一起恢复
+func RestoreRoom(hub *Hub, room *models.Room) error {
+	if deadline := RoomRestoreDeadline(room); deadline != nil && time.Now().After(*deadline) {
+		return ErrRestoreExpired
+	}
+
+	if err := database.DB.Unscoped().Model(room).Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	room.DeletedAt = gorm.DeletedAt{}
+
+	BroadcastRoomUpdated(hub, room)
+	return nil
+}
+
+// RunRoomPurge 定期彻底删除超过保留期的房间
+func RunRoomPurge(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		PurgeDeletedRooms()
+		<-ticker.C
+	}
+}
+
+// PurgeDeletedRooms 彻底删除超过保留期的房间及其成员、消息、邀请码和封禁记录，返回删除的数量
+func PurgeDeletedRooms() int {
+	days := config.AppConfig.Room.RestoreDays
+	if days <= 0 {
+		return 0
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	var roomIDs []uint
+	if err := database.DB.Unscoped().Model(&models.Room{}).
+		Where("deleted_at IS NOT NULL AND deleted_at <= ?", cutoff).
+		Pluck("id", &roomIDs).Error; err != nil {
+		log.Printf("Failed to load deleted rooms: %v", err)
+		return 0
+	}
+
+	purged := 0
+	for _, roomID := range roomIDs {
+		if err := purgeRoom(roomID); err != nil {
+			log.Printf("Failed to purge room %d: %v", roomID, err)
+			continue
+		}
+		purged++
+	}
+	return purged
+}
+
+// purgeRoom 彻底删除房间及其关联数据
+func purgeRoom(roomID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{
+			&models.Message{},
+			&models.RoomMember{},
+			&models.RoomInvite{},
+			&models.RoomBan{},
+			&models.RoomOwnershipTransfer{},
+			&models.RoomJoinRequest{},
+		} {
+			if err := tx.Unscoped().Where("room_id = ?", roomID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(&models.Room{}, roomID).Error
+	})
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 房间转让相关错误
+var (
+	ErrNotRoomMember   = errors.New("user is not a member of this room")
+	ErrTransferInvalid = errors.New("ownership transfer is no longer valid")
+)
+
+// roomTransferTTL 转让等待对方接受的时间
+const roomTransferTTL = 7 * 24 * time.Hour
+
+// NominateRoomOwner 房间创建者指定新的创建者，对方接受后生效
+// 已有待接受的转让时替换为新的转让
+func NominateRoomOwner(room *models.Room, to *models.User) (*models.RoomOwnershipTransfer, error) {
+	transfer := models.RoomOwnershipTransfer{
+		RoomID:     room.ID,
+		FromUserID: room.CreatorID,
+		ToUserID:   to.ID,
+		ExpiresAt:  time.Now().Add(roomTransferTTL),
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if !room.IsMember(tx, to.ID) {
+			return ErrNotRoomMember
+		}
+		if err := tx.Where("room_id = ?", room.ID).Delete(&models.RoomOwnershipTransfer{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&transfer).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// AcceptRoomOwnership 被指定的成员接受转让，成为房间创建者
+// 原创建者保留房间管理员角色；发起转让后创建者已变更或转让已过期时返回 ErrTransferInvalid
+func AcceptRoomOwnership(hub *Hub, transfer *models.RoomOwnershipTransfer, user *models.User) (*models.Room, error) {
+	var room models.Room
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&room, transfer.RoomID).Error; err != nil {
+			return err
+		}
+		if transfer.IsExpired() || room.CreatorID != transfer.FromUserID {
+			return ErrTransferInvalid
+		}
+
+		// 以删除转让记录作为占用，并发接受或撤销时只有一个请求生效
+		result := tx.Where("id = ?", transfer.ID).Delete(&models.RoomOwnershipTransfer{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTransferInvalid
+		}
+
+		if !room.IsMember(tx, user.ID) {
+			return ErrNotRoomMember
+		}
+
+		var previous models.User
+		if err := tx.First(&previous, transfer.FromUserID).Error; err != nil {
+			return err
+		}
+		if err := setRoomOwner(tx, &room, user.ID); err != nil {
+			return err
+		}
+		return tx.Create(models.CreateSystemMessage(room.ID,
+			previous.Nickname+" 已将房间转让给 "+user.Nickname)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	BroadcastRoomUpdated(hub, &room)
+	return &room, nil
+}
+
+// AssignRoomOwner 管理员直接指定房间创建者，用于原创建者无法操作的房间
+// 用户不是成员时加入房间并解除封禁；因没有成员接管而归档的房间恢复为可用
+func AssignRoomOwner(hub *Hub, room *models.Room, user *models.User) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("room_id = ? AND user_id = ?", room.ID, user.ID).Delete(&models.RoomBan{}).Error; err != nil {
+			return err
+		}
+		if err := setRoomOwner(tx, room, user.ID); err != nil {
+			return err
+		}
+		if room.IsArchived() {
+			if err := tx.Model(room).Update("archived_at", nil).Error; err != nil {
+				return err
+			}
+			room.ArchivedAt = nil
+		}
+		return tx.Create(models.CreateSystemMessage(room.ID, "管理员已将房间转让给 "+user.Nickname)).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	BroadcastRoomUpdated(hub, room)
+	return nil
+}
+
+// setRoomOwner 将用户设为房间创建者，并保证其是房间管理员
+// CreatorID 和成员角色在同一事务中修改，待接受的转让随之作废
+func setRoomOwner(tx *gorm.DB, room *models.Room, userID uint) error {
+	if room.IsMember(tx, userID) {
+		if err := tx.Model(&models.RoomMember{}).Where("room_id = ? AND user_id = ?", room.ID, userID).
+			Update("role", "admin").Error; err != nil {
+			return err
+		}
+	} else {
+		member := models.RoomMember{
+			RoomID:   room.ID,
+			UserID:   userID,
+			Role:     "admin",
+			JoinedAt: time.Now(),
+		}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Unscoped().Model(room).Update("creator_id", userID).Error; err != nil {
+		return err
+	}
+	room.CreatorID = userID
+
+	return tx.Where("room_id = ?", room.ID).Delete(&models.RoomOwnershipTransfer{}).Error
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// memoryItem 内存存储中的条目
+type memoryItem struct {
+	value     string
+	expiresAt time.Time
+}
+
+// memoryStore 进程内的键值存储，Redis 不可用时作为降级方案
+// 过期条目在读取时惰性删除，并由后台协程定期清理
+type memoryStore struct {
+	items map[string]memoryItem
+	mutex sync.Mutex
+}
+
+// localStore 进程内共享的降级存储
+var localStore = newMemoryStore(time.Minute)
+
+// newMemoryStore 创建内存存储并启动过期清理协程
+func newMemoryStore(cleanupInterval time.Duration) *memoryStore {
+	store := &memoryStore{
+		items: make(map[string]memoryItem),
+	}
+
+	go func() {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			store.evictExpired()
+		}
+	}()
+
+	return store
+}
+
+// set 写入条目，ttl 到期后自动失效
+func (s *memoryStore) set(key, value string, ttl time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.items[key] = memoryItem{
+		value:     value,
+		expiresAt: time.Now().Add(ttl),
+	}
+}
+
+// get 读取未过期的条目
+func (s *memoryStore) get(key string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(item.expiresAt) {
+		delete(s.items, key)
+		return "", false
+	}
+	return item.value, true
+}
+
+// setNX 条目不存在或已过期时写入并返回 true，否则保持原值并返回 false
+func (s *memoryStore) setNX(key, value string, ttl time.Duration) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if item, ok := s.items[key]; ok && !now.After(item.expiresAt) {
+		return false
+	}
+	s.items[key] = memoryItem{
+		value:     value,
+		expiresAt: now.Add(ttl),
+	}
+	return true
+}
+
+// getDel 读取并删除未过期的条目，用于一次性凭证
+func (s *memoryStore) getDel(key string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return "", false
+	}
+	delete(s.items, key)
+	if time.Now().After(item.expiresAt) {
+		return "", false
+	}
+	return item.value, true
+}
+
+// ttl 返回条目的剩余有效期，条目不存在或已过期时返回 0
+func (s *memoryStore) ttl(key string) time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return 0
+	}
+	remaining := time.Until(item.expiresAt)
+	if remaining <= 0 {
+		delete(s.items, key)
+		return 0
+	}
+	return remaining
+}
+
+// incr 计数加一并返回计数和剩余有效期，计数在首次创建后 ttl 到期
+func (s *memoryStore) incr(key string, ttl time.Duration) (int64, time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	item, ok := s.items[key]
+	if !ok || now.After(item.expiresAt) {
+		item = memoryItem{value: "0", expiresAt: now.Add(ttl)}
+	}
+
+	count, _ := strconv.ParseInt(item.value, 10, 64)
+	count++
+	item.value = strconv.FormatInt(count, 10)
+	s.items[key] = item
+
+	return count, item.expiresAt.Sub(now)
+}
+
+// del 删除条目
+func (s *memoryStore) del(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.items, key)
+}
+
+// evictExpired 清理所有已过期的条目
+func (s *memoryStore) evictExpired() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for key, item := range s.items {
+		if now.After(item.expiresAt) {
+			delete(s.items, key)
+		}
+	}
+}
+
+// putOnce 保存一次性凭证，Redis 未连接时使用进程内存储
+func putOnce(key, value string, ttl time.Duration) error {
+	if RedisClient == nil {
+		localStore.set(key, value, ttl)
+		return nil
+	}
+	return RedisClient.Set(ctx, key, value, ttl).Err()
+}
+
+// takeOnce 取出并删除一次性凭证，凭证不存在或已过期时返回 false
+func takeOnce(key string) (string, bool, error) {
+	if RedisClient == nil {
+		value, ok := localStore.getDel(key)
+		return value, ok, nil
+	}
+
+	value, err := RedisClient.GetDel(ctx, key).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// tokenRevocationKey 生成 token 黑名单的键
+func tokenRevocationKey(jti string) string {
+	return fmt.Sprintf("token:revoked:%s", jti)
+}
+
+// RevokeToken 将 token 的 jti 加入黑名单，黑名单条目在 token 过期时自动清除
+func RevokeToken(jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil // token 已过期，无需加入黑名单
+	}
+
+	key := tokenRevocationKey(jti)
+	if RedisClient == nil {
+		localStore.set(key, "1", ttl) // Redis 未连接，使用进程内存储
+		return nil
+	}
+
+	return RedisClient.Set(ctx, key, 1, ttl).Err()
+}
+
+// IsTokenRevoked 检查 token 的 jti 是否在黑名单中
+func IsTokenRevoked(jti string) (bool, error) {
+	key := tokenRevocationKey(jti)
+	if RedisClient == nil {
+		_, revoked := localStore.get(key) // Redis 未连接，使用进程内存储
+		return revoked, nil
+	}
+
+	count, err := RedisClient.Exists(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ClaimToken 原子地将 jti 加入黑名单，jti 已在黑名单中或 token 已过期时返回 false
+// 用于只能成功使用一次的令牌，并发请求中只有一个能认领成功
+func ClaimToken(jti string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return false, nil
+	}
+
+	key := tokenRevocationKey(jti)
+	if RedisClient == nil {
+		return localStore.setNX(key, "1", ttl), nil // Redis 未连接，使用进程内存储
+	}
+
+	return RedisClient.SetNX(ctx, key, 1, ttl).Result()
+}
+
+// ReleaseToken 将 jti 移出黑名单，认领后验证失败时调用以允许重试
+func ReleaseToken(jti string) error {
+	key := tokenRevocationKey(jti)
+	if RedisClient == nil {
+		localStore.del(key) // Redis 未连接，使用进程内存储
+		return nil
+	}
+
+	return RedisClient.Del(ctx, key).Err()
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"time"
+)
+
+// WebSocketTicketTTL WebSocket 连接票据的有效期
+const WebSocketTicketTTL = 30 * time.Second
+
+// ErrInvalidWebSocketTicket 票据不存在、已过期或已被使用
+var ErrInvalidWebSocketTicket = errors.New("invalid or expired websocket ticket")
+
+// webSocketTicketKey 生成 WebSocket 票据的键
+func webSocketTicketKey(ticket string) string {
+	return fmt.Sprintf("ws:ticket:%s", auth.HashToken(ticket))
+}
+
+// IssueWebSocketTicket 为访问令牌签发一次性的 WebSocket 连接票据
+// 浏览器无法在握手时设置 Authorization 头，可以通过查询参数传递票据
+func IssueWebSocketTicket(accessToken string) (string, error) {
+	ticket, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := putOnce(webSocketTicketKey(ticket), accessToken, WebSocketTicketTTL); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// RedeemWebSocketTicket 兑换 WebSocket 连接票据，返回签发票据时使用的访问令牌
+// 票据兑换后立即失效
+func RedeemWebSocketTicket(ticket string) (string, error) {
+	accessToken, ok, err := takeOnce(webSocketTicketKey(ticket))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrInvalidWebSocketTicket
+	}
+	return accessToken, nil
+}
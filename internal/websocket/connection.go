@@ -3,7 +3,9 @@ package websocket
 import (
 	"encoding/json"
 	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/middleware"
 	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
 	"gin-chat-room/internal/services"
 	"log"
 	"net/http"
@@ -48,9 +50,9 @@ type WebSocketConnection interface {
 	Close() error
 }
 
-// NewConnection 创建新的 WebSocket 连接
-func NewConnection(w http.ResponseWriter, r *http.Request) (*Connection, error) {
-	ws, err := upgrader.Upgrade(w, r, nil)
+// NewConnection 创建新的 WebSocket 连接，responseHeader 会附加到握手响应中
+func NewConnection(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*Connection, error) {
+	ws, err := upgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
 		return nil, err
 	}
@@ -162,41 +164,60 @@ func (c *Connection) handleMessage(client *services.Client, wsMessage *services.
 
 // handleChatMessage 处理聊天消息
 func (c *Connection) handleChatMessage(client *services.Client, wsMessage *services.WebSocketMessage) {
-	// 验证用户是否在房间中
-	var roomMember models.RoomMember
-	if err := database.DB.Where("room_id = ? AND user_id = ?", client.RoomID, client.UserID).First(&roomMember).Error; err != nil {
-		log.Printf("User %d is not a member of room %d", client.UserID, client.RoomID)
+	// API 密钥需要拥有当前房间的发送权限
+	if !client.Allows(models.ScopeMessagesWrite, client.RoomID) {
+		client.SendError("API key is missing required scope: " + models.ScopeMessagesWrite)
 		return
 	}
 
-	// 创建消息记录
-	message := models.Message{
-		RoomID:  client.RoomID,
-		UserID:  client.UserID,
-		Type:    models.MessageTypeText,
-		Content: wsMessage.Content,
+	// 每次发言时重新加载用户和房间，角色和房间设置变更立即生效
+	var user models.User
+	if err := database.DB.First(&user, client.UserID).Error; err != nil {
+		return
 	}
-
-	// 保存到数据库
-	if err := database.DB.Create(&message).Error; err != nil {
-		log.Printf("Error saving message: %v", err)
+	var room models.Room
+	if err := database.DB.First(&room, client.RoomID).Error; err != nil {
+		return
+	}
+	if !rbac.CanSendMessage(&user, &room) {
+		client.SendError("Permission denied: " + string(rbac.PermMessagesSend))
+		return
+	}
+	if room.IsBanned(database.DB, user.ID) {
+		client.SendError("You are banned from this room")
 		return
 	}
 
-	// 预加载用户信息
-	database.DB.Preload("User").First(&message, message.ID)
+	if user.IsGuest {
+		// 访客不加入房间，发言单独限流
+		if allowed, _, err := services.AllowGuestMessage(user.ID); err != nil || !allowed {
+			client.SendError("Too many messages, please try again later")
+			return
+		}
+	} else if !room.IsMember(database.DB, user.ID) && !rbac.CanBotPostWithoutMembership(&user, client.APIKey, &room) {
+		// 验证用户是否在房间中，机器人按 API 密钥的房间授权发言
+		client.SendError("Not a member of this room")
+		return
+	}
 
-	// 缓存消息到 Redis
-	services.CacheMessage(client.RoomID, message.ToJSON())
+	if room.IsMuted(database.DB, user.ID) {
+		client.SendError("You are muted in this room")
+		return
+	}
 
-	// 广播消息
-	broadcastMessage := services.WebSocketMessage{
-		Type:   "message",
-		RoomID: client.RoomID,
-		Data:   message.ToJSON(),
+	// 开启邮箱验证后，未验证邮箱的用户不能发言
+	if middleware.EmailVerificationRequired(&user) {
+		client.SendError("Email verification required")
+		return
 	}
 
-	client.Hub.BroadcastMessage(client.RoomID, broadcastMessage)
+	if _, err := services.PostMessage(client.Hub, client.RoomID, client.UserID, wsMessage.Content); err != nil {
+		if err == services.ErrRoomArchived {
+			client.SendError("Room is archived")
+			return
+		}
+		log.Printf("Error saving message: %v", err)
+	}
 }
 
 // handleJoinRoom 处理加入房间
@@ -212,21 +233,48 @@ func (c *Connection) handleJoinRoom(client *services.Client, wsMessage *services
 		return
 	}
 
-	// 检查用户是否已经是房间成员
-	var roomMember models.RoomMember
-	if err := database.DB.Where("room_id = ? AND user_id = ?", wsMessage.RoomID, client.UserID).First(&roomMember).Error; err != nil {
-		// 如果不是成员，添加为成员
-		roomMember = models.RoomMember{
-			RoomID:   wsMessage.RoomID,
-			UserID:   client.UserID,
-			Role:     "member",
-			JoinedAt: time.Now(),
+	// API 密钥需要拥有目标房间的读取权限
+	if !client.Allows(models.ScopeMessagesRead, wsMessage.RoomID) {
+		client.SendError("API key is missing required scope: " + models.ScopeMessagesRead)
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, client.UserID).Error; err != nil {
+		return
+	}
+	if !rbac.CanViewRoom(&user, &room) {
+		client.SendError("Access denied")
+		return
+	}
+	if room.IsBanned(database.DB, user.ID) {
+		client.SendError("You are banned from this room")
+		return
+	}
+
+	// 不是成员时只有公开房间会自动加入，与加入接口一样检查归档、封禁和人数
+	// 私有房间和私信会话只能通过密码、邀请或申请加入，有权查看的用户（如管理员）只能阅读；访客和机器人同样只能阅读
+	if !room.IsMember(database.DB, user.ID) {
+		if room.IsArchived() {
+			client.SendError("Room is archived")
+			return
+		}
+		if !room.IsPrivate && !room.IsDirect && !user.IsBot && rbac.Can(&user, rbac.PermRoomsJoin) {
+			switch err := services.JoinRoom(&room, &user, "member"); err {
+			case nil, services.ErrAlreadyMember:
+			case services.ErrRoomFull:
+				client.SendError("Room is full")
+				return
+			default:
+				log.Printf("Failed to join room %d: %v", room.ID, err)
+				client.SendError("Failed to join room")
+				return
+			}
 		}
-		database.DB.Create(&roomMember)
 	}
 
-	// 更新客户端房间ID
-	client.RoomID = wsMessage.RoomID
+	// 更新客户端房间ID，之后接收新房间的广播
+	client.Hub.MoveClient(client, wsMessage.RoomID)
 
 	// 设置用户在线状态
 	services.SetUserOnline(client.UserID, client.RoomID)
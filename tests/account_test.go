@@ -0,0 +1,321 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestExportProfile(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	user := createTestUser(t, "erin", "password123")
+	other := createTestUser(t, "frank", "password123")
+
+	room := models.Room{Name: "book club", CreatorID: user.ID}
+	database.DB.Create(&room)
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: user.ID, Role: "admin", JoinedAt: time.Now()})
+	database.DB.Create(&models.Message{RoomID: room.ID, UserID: user.ID, Content: "hello from erin"})
+	database.DB.Create(&models.Message{RoomID: room.ID, UserID: other.ID, Content: "hello from frank"})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	trustTestProxy(t, router)
+	router.POST("/auth/login", handlers.Login)
+	router.GET("/profile/export", middleware.AuthMiddleware(), handlers.ExportProfile)
+
+	w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"erin","password":"password123"}`, map[string]string{"X-Forwarded-For": "198.51.100.60"})
+	var login handlers.AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &login)
+
+	w = performJSON(router, http.MethodGet, "/profile/export", "", map[string]string{"Authorization": "Bearer " + login.Token})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/zip" {
+		t.Errorf("Unexpected content type %q", w.Header().Get("Content-Type"))
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open export archive: %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, file := range archive.File {
+		reader, _ := file.Open()
+		files[file.Name], _ = io.ReadAll(reader)
+		reader.Close()
+	}
+
+	for _, name := range []string{"profile.json", "sessions.json", "memberships.json", "messages.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Expected %s in export archive", name)
+		}
+	}
+
+	var profile map[string]interface{}
+	json.Unmarshal(files["profile.json"], &profile)
+	if profile["username"] != "erin" {
+		t.Errorf("Unexpected profile: %v", profile)
+	}
+
+	var memberships []map[string]interface{}
+	json.Unmarshal(files["memberships.json"], &memberships)
+	if len(memberships) != 1 || memberships[0]["room_name"] != "book club" || memberships[0]["is_creator"] != true {
+		t.Errorf("Unexpected memberships: %v", memberships)
+	}
+
+	var messages []map[string]interface{}
+	json.Unmarshal(files["messages.json"], &messages)
+	if len(messages) != 1 || messages[0]["content"] != "hello from erin" {
+		t.Errorf("Expected only the user's own message, got %v", messages)
+	}
+
+	var sessions []map[string]interface{}
+	json.Unmarshal(files["sessions.json"], &sessions)
+	if len(sessions) != 1 {
+		t.Errorf("Expected 1 session, got %d", len(sessions))
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	user := createTestUser(t, "grace", "password123")
+	t.Cleanup(func() {
+		services.ResetLoginFailures(fmt.Sprintf("user:%d", user.ID))
+	})
+	heir := createTestUser(t, "heidi", "password123")
+
+	shared := models.Room{Name: "shared", CreatorID: user.ID}
+	solo := models.Room{Name: "solo", CreatorID: user.ID}
+	database.DB.Create(&shared)
+	database.DB.Create(&solo)
+	database.DB.Create(&models.RoomMember{RoomID: shared.ID, UserID: user.ID, Role: "admin", JoinedAt: time.Now()})
+	database.DB.Create(&models.RoomMember{RoomID: shared.ID, UserID: heir.ID, Role: "member", JoinedAt: time.Now()})
+	database.DB.Create(&models.RoomMember{RoomID: solo.ID, UserID: user.ID, Role: "admin", JoinedAt: time.Now()})
+	message := models.Message{RoomID: shared.ID, UserID: user.ID, Content: "goodbye"}
+	database.DB.Create(&message)
+	spammer := createTestUser(t, "ivo", "password123")
+	invite := models.RoomInvite{Code: "grace-invite", RoomID: shared.ID, CreatorID: user.ID}
+	ban := models.RoomBan{RoomID: shared.ID, UserID: spammer.ID, BannedByID: user.ID}
+	request := models.RoomJoinRequest{RoomID: shared.ID, UserID: spammer.ID, Status: models.JoinRequestDenied, ReviewedByID: &user.ID}
+	database.DB.Create(&invite)
+	database.DB.Create(&ban)
+	database.DB.Create(&request)
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	trustTestProxy(t, router)
+	router.POST("/auth/login", handlers.Login)
+	protected := router.Group("/", middleware.AuthMiddleware())
+	protected.GET("/profile", handlers.GetProfile)
+	protected.DELETE("/profile", handlers.DeleteAccount(hub))
+	protected.GET("/rooms", handlers.GetRooms)
+
+	login := func(username string) map[string]string {
+		w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"`+username+`","password":"password123"}`, map[string]string{"X-Forwarded-For": "198.51.100.61"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected login to succeed, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp handlers.AuthResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return map[string]string{"Authorization": "Bearer " + resp.Token, "X-Forwarded-For": "198.51.100.61"}
+	}
+
+	headers := login("grace")
+	if w := performJSON(router, http.MethodDelete, "/profile", `{"password":"wrong"}`, headers); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for wrong password, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodDelete, "/profile", `{"password":"password123"}`, headers); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 申请注销后已签发的令牌立即失效
+	if w := performJSON(router, http.MethodGet, "/profile", "", headers); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected old token to be rejected, got %d", w.Code)
+	}
+
+	// 宽限期内重新登录撤销注销
+	headers = login("grace")
+	var reloaded models.User
+	database.DB.First(&reloaded, user.ID)
+	if reloaded.DeletionScheduledAt != nil {
+		t.Fatal("Expected login to cancel the scheduled deletion")
+	}
+
+	// 宽限期结束后删除账号
+	if w := performJSON(router, http.MethodDelete, "/profile", `{"password":"password123"}`, headers); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if deleted := services.DeleteDueAccounts(hub); deleted != 0 {
+		t.Fatalf("Expected no deletion before the grace period ends, got %d", deleted)
+	}
+	database.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("deletion_scheduled_at", time.Now().Add(-time.Minute))
+	if w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"grace","password":"password123"}`, map[string]string{"X-Forwarded-For": "198.51.100.61"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected login after the grace period to be rejected, got %d", w.Code)
+	}
+	if deleted := services.DeleteDueAccounts(hub); deleted != 1 {
+		t.Fatalf("Expected 1 deleted account, got %d", deleted)
+	}
+
+	var count int64
+	database.DB.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).Count(&count)
+	if count != 0 {
+		t.Error("Expected user row to be removed")
+	}
+
+	// 消息转给占位账号
+	database.DB.Preload("User").First(&message, message.ID)
+	if message.User.Username != models.DeletedUserUsername || message.Content != "goodbye" {
+		t.Errorf("Expected message to be anonymized, got author %q", message.User.Username)
+	}
+
+	// 本人创建的邀请码、执行的封禁和审核记录转给占位账号
+	var placeholder models.User
+	database.DB.Where("is_placeholder = ?", true).First(&placeholder)
+	database.DB.First(&invite, invite.ID)
+	database.DB.First(&ban, ban.ID)
+	database.DB.First(&request, request.ID)
+	if invite.CreatorID != placeholder.ID || ban.BannedByID != placeholder.ID || request.ReviewedByID == nil || *request.ReviewedByID != placeholder.ID {
+		t.Errorf("Expected moderation records to be reassigned to the placeholder, got invite %d, ban %d, request %v", invite.CreatorID, ban.BannedByID, request.ReviewedByID)
+	}
+
+	// 有其他成员的房间移交，没有的归档
+	database.DB.First(&shared, shared.ID)
+	if shared.CreatorID != heir.ID || shared.IsArchived() {
+		t.Errorf("Expected shared room to be handed over to heir, got creator %d", shared.CreatorID)
+	}
+	var heirMember models.RoomMember
+	database.DB.Where("room_id = ? AND user_id = ?", shared.ID, heir.ID).First(&heirMember)
+	if heirMember.Role != "admin" {
+		t.Errorf("Expected heir to become room admin, got %q", heirMember.Role)
+	}
+	database.DB.First(&solo, solo.ID)
+	if !solo.IsArchived() {
+		t.Error("Expected solo room to be archived")
+	}
+
+	// 归档的房间不出现在房间列表中
+	w := performJSON(router, http.MethodGet, "/rooms", "", login("heidi"))
+	var rooms struct {
+		Rooms []map[string]interface{} `json:"rooms"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &rooms)
+	if len(rooms.Rooms) != 1 || rooms.Rooms[0]["name"] != "shared" {
+		t.Errorf("Expected only the shared room to be listed, got %v", rooms.Rooms)
+	}
+}
+
+func TestDeleteAccountWithoutPassword(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	// 外部登录创建的账号没有可用的密码
+	newUser := func(username string) *models.User {
+		user := &models.User{Username: username, Email: username + "@example.com", Password: "!"}
+		if err := database.DB.Create(user).Error; err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		return user
+	}
+	sso := newUser("ivan")
+	totpUser := newUser("judy")
+
+	secret, _ := auth.GenerateTOTPSecret()
+	encrypted, _ := auth.EncryptTOTPSecret(secret)
+	database.DB.Model(totpUser).Updates(map[string]interface{}{"totp_secret": encrypted, "totp_enabled": true})
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.DELETE("/profile", middleware.AuthMiddleware(), handlers.DeleteAccount(hub))
+
+	login := func(user *models.User) map[string]string {
+		_, tokens, err := auth.CreateSession(user, "test", "198.51.100.62")
+		if err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		return map[string]string{"Authorization": "Bearer " + tokens.AccessToken}
+	}
+	scheduled := func(user *models.User) bool {
+		var reloaded models.User
+		database.DB.First(&reloaded, user.ID)
+		return reloaded.DeletionScheduledAt != nil
+	}
+
+	// 没有开启两步验证时需要最近重新登录
+	headers := login(sso)
+	database.DB.Model(&models.Session{}).Where("user_id = ?", sso.ID).Update("created_at", time.Now().Add(-time.Hour))
+	if w := performJSON(router, http.MethodDelete, "/profile", `{"password":""}`, headers); w.Code != http.StatusForbidden {
+		t.Errorf("Expected stale session to be rejected, got %d", w.Code)
+	}
+	if scheduled(sso) {
+		t.Fatal("Expected deletion not to be scheduled")
+	}
+	if w := performJSON(router, http.MethodDelete, "/profile", `{}`, login(sso)); w.Code != http.StatusOK {
+		t.Fatalf("Expected recent login to confirm deletion, got %d: %s", w.Code, w.Body.String())
+	}
+	if !scheduled(sso) {
+		t.Error("Expected deletion to be scheduled")
+	}
+
+	// 开启两步验证时需要验证码
+	headers = login(totpUser)
+	code, _ := auth.TOTPCode(secret, auth.TOTPStep(time.Now()))
+	wrong := code[:5] + string('0'+(code[5]-'0'+1)%10)
+	if w := performJSON(router, http.MethodDelete, "/profile", `{"code":"`+wrong+`"}`, headers); w.Code != http.StatusForbidden {
+		t.Errorf("Expected wrong code to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodDelete, "/profile", `{"code":"`+code+`"}`, headers); w.Code != http.StatusOK {
+		t.Fatalf("Expected valid code to confirm deletion, got %d: %s", w.Code, w.Body.String())
+	}
+	if !scheduled(totpUser) {
+		t.Error("Expected deletion to be scheduled")
+	}
+}
+
+func TestDeletedUserPlaceholderReserved(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/register", handlers.Register)
+	if w := performJSON(router, http.MethodPost, "/auth/register", `{"username":"[Deleted]","email":"squat@example.com","password":"password123"}`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected reserved username to be rejected, got %d", w.Code)
+	}
+
+	// 旧版本中已被注册的用户名不影响占位账号
+	squatter := createTestUser(t, models.DeletedUserUsername, "password123")
+	leaver := createTestUser(t, "leaver", "password123")
+	if err := services.DeleteAccount(leaver.ID); err != nil {
+		t.Fatalf("Failed to delete account: %v", err)
+	}
+	other := createTestUser(t, "another", "password123")
+	if err := services.DeleteAccount(other.ID); err != nil {
+		t.Fatalf("Failed to delete second account: %v", err)
+	}
+
+	var placeholders []models.User
+	database.DB.Where("is_placeholder = ?", true).Find(&placeholders)
+	if len(placeholders) != 1 || placeholders[0].ID == squatter.ID {
+		t.Errorf("Expected a single placeholder separate from the squatter, got %+v", placeholders)
+	}
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestBotAPIKeys(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	admin := createTestUser(t, "admin", "password123")
+	database.DB.Model(admin).Update("role", rbac.RoleSiteAdmin)
+	adminToken, err := auth.GenerateToken(admin.ID, admin.Username, admin.Email)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	adminAuth := map[string]string{"Authorization": "Bearer " + adminToken}
+
+	buildRoom := models.Room{Name: "builds", CreatorID: admin.ID}
+	otherRoom := models.Room{Name: "random", CreatorID: admin.ID}
+	database.DB.Create(&buildRoom)
+	database.DB.Create(&otherRoom)
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/login", handlers.Login)
+	router.GET("/ws", handlers.HandleWebSocket(hub))
+	router.GET("/profile", middleware.AuthMiddleware(), handlers.GetProfile)
+	bots := router.Group("/", middleware.AuthMiddleware(middleware.AcceptAPIKeys()))
+	bots.GET("/rooms", middleware.RequireScope(models.ScopeRoomsRead), handlers.GetRooms)
+	bots.POST("/rooms/:id/messages", middleware.RequireScope(models.ScopeMessagesWrite), handlers.SendMessage(hub))
+	adminGroup := router.Group("/admin", middleware.AuthMiddleware(), middleware.RequirePermission(rbac.PermBotsManage))
+	adminGroup.POST("/bots", handlers.CreateBot)
+	adminGroup.POST("/bots/:id/api-keys", handlers.CreateAPIKey)
+	adminGroup.GET("/api-keys", handlers.GetAPIKeys)
+	adminGroup.DELETE("/api-keys/:id", handlers.RevokeAPIKey(hub))
+
+	w := performJSON(router, http.MethodPost, "/admin/bots", `{"username":"ci-bot"}`, adminAuth)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var botResp struct {
+		Bot struct {
+			ID    uint `json:"id"`
+			IsBot bool `json:"is_bot"`
+		} `json:"bot"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &botResp)
+	if !botResp.Bot.IsBot {
+		t.Fatal("Expected created user to be a bot")
+	}
+
+	// 普通用户不能管理机器人
+	alice := createTestUser(t, "alice", "password123")
+	aliceToken, _ := auth.GenerateToken(alice.ID, alice.Username, alice.Email)
+	if w := performJSON(router, http.MethodPost, "/admin/bots", `{"username":"evil-bot"}`, map[string]string{"Authorization": "Bearer " + aliceToken}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non-admin, got %d", w.Code)
+	}
+
+	keysPath := fmt.Sprintf("/admin/bots/%d/api-keys", botResp.Bot.ID)
+	if w := performJSON(router, http.MethodPost, keysPath, `{"name":"bad","scopes":["messages:delete"]}`, adminAuth); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown scope, got %d", w.Code)
+	}
+
+	body := fmt.Sprintf(`{"name":"ci","scopes":["messages:write:%d","messages:read:%d"]}`, buildRoom.ID, buildRoom.ID)
+	w = performJSON(router, http.MethodPost, keysPath, body, adminAuth)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var keyResp struct {
+		Key    string `json:"key"`
+		APIKey struct {
+			ID uint `json:"id"`
+		} `json:"api_key"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &keyResp)
+	if !strings.HasPrefix(keyResp.Key, auth.APIKeyPrefix) {
+		t.Fatalf("Unexpected key format: %q", keyResp.Key)
+	}
+
+	var stored models.APIKey
+	database.DB.First(&stored, keyResp.APIKey.ID)
+	if stored.KeyHash == keyResp.Key || strings.Contains(w.Body.String(), stored.KeyHash) {
+		t.Error("API key must be stored hashed and the hash must not be exposed")
+	}
+
+	keyAuth := map[string]string{"Authorization": "Bearer " + keyResp.Key}
+
+	// 授权的公开房间可以发消息，机器人不会因此成为成员
+	w = performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/messages", buildRoom.ID), `{"content":"build #42 passed"}`, keyAuth)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if buildRoom.IsMember(database.DB, botResp.Bot.ID) {
+		t.Error("Expected bot not to join the room")
+	}
+
+	// 即使授权了房间，也不能向非成员的私有房间发消息
+	secretRoom := models.Room{Name: "secret", IsPrivate: true, CreatorID: admin.ID}
+	database.DB.Create(&secretRoom)
+	body = fmt.Sprintf(`{"name":"spy","scopes":["messages:write:%d"]}`, secretRoom.ID)
+	w = performJSON(router, http.MethodPost, keysPath, body, adminAuth)
+	var spyResp struct {
+		Key string `json:"key"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &spyResp)
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/messages", secretRoom.ID), `{"content":"hi"}`, map[string]string{"X-API-Key": spyResp.Key}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for private room, got %d", w.Code)
+	}
+	if secretRoom.IsMember(database.DB, botResp.Bot.ID) {
+		t.Error("Expected bot not to join the private room")
+	}
+
+	// 其他房间和没有授权的接口被拒绝
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/messages", otherRoom.ID), `{"content":"hi"}`, map[string]string{"X-API-Key": keyResp.Key}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for room outside scope, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodGet, "/rooms", "", keyAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without rooms:read, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodGet, "/profile", "", keyAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected API keys to be rejected on user endpoints, got %d", w.Code)
+	}
+
+	// 机器人不能使用密码登录
+	if w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"ci-bot","password":""}`, nil); w.Code == http.StatusOK {
+		t.Error("Expected bot password login to fail")
+	}
+
+	// WebSocket 只能连接有读取权限的房间
+	server := httptest.NewServer(router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	header := http.Header{"X-Api-Key": {keyResp.Key}}
+	if _, resp, err := websocket.DefaultDialer.Dial(fmt.Sprintf("%s?room_id=%d", wsURL, otherRoom.ID), header); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected websocket to room outside scope to be rejected")
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("%s?room_id=%d", wsURL, buildRoom.ID), header)
+	if err != nil {
+		t.Fatalf("Failed to connect websocket with api key: %v", err)
+	}
+	defer conn.Close()
+
+	// 机器人通过 WebSocket 向授权的公开房间发言，同样不需要加入房间
+	conn.WriteJSON(map[string]interface{}{"type": "message", "content": "deploy finished"})
+	var wsMessage models.Message
+	for i := 0; i < 50; i++ {
+		if database.DB.Where("room_id = ? AND user_id = ? AND content = ?", buildRoom.ID, botResp.Bot.ID, "deploy finished").First(&wsMessage).Error == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if wsMessage.ID == 0 {
+		t.Fatal("Expected bot websocket message to be saved")
+	}
+
+	// 吊销后密钥失效，WebSocket 连接被断开
+	if w := performJSON(router, http.MethodDelete, fmt.Sprintf("/admin/api-keys/%d", keyResp.APIKey.ID), "", adminAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+				t.Fatal("Expected websocket of revoked key to be closed")
+			}
+			break
+		}
+	}
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/messages", buildRoom.ID), `{"content":"again"}`, keyAuth); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked key to be rejected, got %d", w.Code)
+	}
+}
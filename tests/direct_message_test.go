@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDirectMessages(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	users := make(map[string]*models.User)
+	tokens := make(map[string]string)
+	for _, name := range []string{"uma", "vic", "wes"} {
+		users[name] = createTestUser(t, name, "password123")
+		tokens[name], _ = auth.GenerateToken(users[name].ID, users[name].Username, users[name].Email)
+	}
+	authFor := func(name string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + tokens[name]}
+	}
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/", middleware.AuthMiddleware())
+	api.GET("/rooms", handlers.GetRooms)
+	api.POST("/rooms/:id/join", handlers.JoinRoom)
+	api.PUT("/rooms/:id", handlers.UpdateRoom(hub))
+	api.GET("/rooms/:id/messages", handlers.GetMessages)
+	api.POST("/rooms/:id/messages", handlers.SendMessage(hub))
+	api.POST("/direct-messages", handlers.CreateDirectConversation)
+	api.GET("/direct-messages", handlers.GetDirectConversations)
+
+	type conversation struct {
+		ID           uint                     `json:"id"`
+		IsDirect     bool                     `json:"is_direct"`
+		Participants []map[string]interface{} `json:"participants"`
+		LastMessage  map[string]interface{}   `json:"last_message"`
+	}
+	start := func(name string, others ...string) (int, conversation) {
+		var ids []uint
+		for _, other := range others {
+			ids = append(ids, users[other].ID)
+		}
+		body, _ := json.Marshal(map[string]interface{}{"user_ids": ids})
+		w := performJSON(router, http.MethodPost, "/direct-messages", string(body), authFor(name))
+		var resp struct {
+			Conversation conversation `json:"conversation"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Conversation
+	}
+
+	// 同一组参与者只有一个会话
+	code, pair := start("uma", "vic")
+	if code != http.StatusCreated || !pair.IsDirect || len(pair.Participants) != 2 {
+		t.Fatalf("Expected new direct conversation, got %d: %+v", code, pair)
+	}
+	for _, participant := range pair.Participants {
+		if _, ok := participant["email"]; ok {
+			t.Errorf("Expected participants not to expose email, got %v", participant)
+		}
+	}
+	if code, again := start("vic", "uma", "uma"); code != http.StatusOK || again.ID != pair.ID {
+		t.Errorf("Expected existing conversation %d, got %d (%d)", pair.ID, again.ID, code)
+	}
+	code, group := start("uma", "wes", "vic")
+	if code != http.StatusCreated || group.ID == pair.ID || len(group.Participants) != 3 {
+		t.Errorf("Expected separate group conversation, got %d: %+v", code, group)
+	}
+	if code, _ := start("uma", "uma"); code != http.StatusBadRequest {
+		t.Errorf("Expected conversation with yourself to be rejected, got %d", code)
+	}
+	users["bot"] = createTestUser(t, "helper-bot", "password123")
+	database.DB.Model(users["bot"]).Update("is_bot", true)
+	if code, _ := start("uma", "bot"); code != http.StatusBadRequest {
+		t.Errorf("Expected conversation with a bot to be rejected, got %d", code)
+	}
+
+	// 使用房间消息接口收发，只有参与者可以访问
+	messagesPath := fmt.Sprintf("/rooms/%d/messages", pair.ID)
+	if w := performJSON(router, http.MethodPost, messagesPath, `{"content":"hi vic"}`, authFor("uma")); w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := performJSON(router, http.MethodGet, messagesPath, "", authFor("vic")); w.Code != http.StatusOK {
+		t.Errorf("Expected participant to read messages, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodGet, messagesPath, "", authFor("wes")); w.Code != http.StatusForbidden {
+		t.Errorf("Expected non-participant to be denied, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/join", pair.ID), "", authFor("wes")); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected joining a direct conversation to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPut, fmt.Sprintf("/rooms/%d", pair.ID), `{"max_members":1}`, authFor("uma")); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected updating a direct conversation to be rejected, got %d", w.Code)
+	}
+
+	// 私信会话不出现在房间列表中，会话列表按最近消息排序
+	database.DB.Create(&models.Room{Name: "plaza", MaxMembers: 10, CreatorID: users["uma"].ID})
+	w := performJSON(router, http.MethodGet, "/rooms", "", authFor("uma"))
+	var rooms struct {
+		Rooms []map[string]interface{} `json:"rooms"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &rooms)
+	if len(rooms.Rooms) != 1 || rooms.Rooms[0]["name"] != "plaza" {
+		t.Errorf("Expected only the public room to be listed, got %v", rooms.Rooms)
+	}
+
+	w = performJSON(router, http.MethodGet, "/direct-messages", "", authFor("uma"))
+	var list struct {
+		Conversations []conversation `json:"conversations"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Conversations) != 2 || list.Conversations[0].ID != pair.ID || list.Conversations[0].LastMessage["content"] != "hi vic" {
+		t.Errorf("Unexpected conversation list: %s", w.Body.String())
+	}
+	w = performJSON(router, http.MethodGet, "/direct-messages", "", authFor("wes"))
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Conversations) != 1 || list.Conversations[0].ID != group.ID {
+		t.Errorf("Expected wes to see only the group conversation, got %s", w.Body.String())
+	}
+}
+
+func TestDirectMessagesRequireVerifiedEmail(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	config.AppConfig.Auth.RequireEmailVerification = true
+
+	sender := createTestUser(t, "xena", "password123")
+	recipient := createTestUser(t, "yuri", "password123")
+	token, _ := auth.GenerateToken(sender.ID, sender.Username, sender.Email)
+	headers := map[string]string{"Authorization": "Bearer " + token}
+	body := fmt.Sprintf(`{"user_ids":[%d]}`, recipient.ID)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/direct-messages", middleware.AuthMiddleware(), middleware.RequireVerifiedEmail(), handlers.CreateDirectConversation)
+
+	// 未验证邮箱的用户不能发起私信
+	if w := performJSON(router, http.MethodPost, "/direct-messages", body, headers); w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 for unverified user, got %d: %s", w.Code, w.Body.String())
+	}
+	var count int64
+	database.DB.Model(&models.Room{}).Where("is_direct = ?", true).Count(&count)
+	if count != 0 {
+		t.Error("Expected no conversation to be created")
+	}
+
+	database.DB.Model(sender).Update("email_verified", true)
+	if w := performJSON(router, http.MethodPost, "/direct-messages", body, headers); w.Code != http.StatusCreated && w.Code != http.StatusOK {
+		t.Errorf("Expected verified user to start a conversation, got %d: %s", w.Code, w.Body.String())
+	}
+}
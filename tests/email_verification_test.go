@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEmailVerificationFlow(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	mailFile := useMailFile(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/register", handlers.Register)
+	router.GET("/auth/email/verify", handlers.VerifyEmail)
+
+	w := performJSON(router, http.MethodPost, "/auth/register", `{"username":"newuser","email":"newuser@example.com","password":"password123"}`, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var user models.User
+	database.DB.Where("username = ?", "newuser").First(&user)
+	if user.EmailVerified {
+		t.Fatal("New user should not be verified")
+	}
+
+	mail, _ := os.ReadFile(mailFile)
+	match := regexp.MustCompile(`token=([\w.-]+)`).FindSubmatch(mail)
+	if match == nil {
+		t.Fatalf("Verification link not found in mail: %s", mail)
+	}
+	token := string(match[1])
+
+	// 验证令牌不能作为访问令牌使用
+	if _, err := auth.ParseToken(token); err == nil {
+		t.Error("Verification token should not be accepted as an access token")
+	}
+
+	w = performJSON(router, http.MethodGet, "/auth/email/verify?token="+token, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	database.DB.First(&user, user.ID)
+	if !user.EmailVerified || user.EmailVerifiedAt == nil {
+		t.Error("User should be verified after opening the link")
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	user := createTestUser(t, "unverified", "password123")
+	token, _ := auth.GenerateToken(user.ID, user.Username, user.Email)
+	headers := map[string]string{"Authorization": "Bearer " + token}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/rooms", middleware.AuthMiddleware(), middleware.RequireVerifiedEmail(), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	// 未开启时不限制
+	if w := performJSON(router, http.MethodPost, "/rooms", `{}`, headers); w.Code != http.StatusCreated {
+		t.Errorf("Expected 201 when verification is not required, got %d", w.Code)
+	}
+
+	config.AppConfig.Auth.RequireEmailVerification = true
+
+	if w := performJSON(router, http.MethodPost, "/rooms", `{}`, headers); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for unverified user, got %d", w.Code)
+	}
+}
+
+func TestEmailVerifiedBackfill(t *testing.T) {
+	setupTestDB(t)
+
+	// 模拟邮箱验证功能上线前的数据库
+	existing := createTestUser(t, "veteran", "password123")
+	if err := database.DB.Migrator().DropColumn(&models.User{}, "email_verified"); err != nil {
+		t.Fatalf("Failed to drop column: %v", err)
+	}
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	newcomer := createTestUser(t, "newcomer", "password123")
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	database.DB.First(existing, existing.ID)
+	database.DB.First(newcomer, newcomer.ID)
+	if !existing.EmailVerified {
+		t.Error("Expected users registered before the upgrade to be marked as verified")
+	}
+	if newcomer.EmailVerified {
+		t.Error("Expected users registered after the upgrade to stay unverified")
+	}
+}
+
+func TestResendVerificationRateLimit(t *testing.T) {
+	services.RedisClient = nil
+
+	key := "test-resend-" + time.Now().Format(time.RFC3339Nano)
+	allowed, _, _ := services.AllowRequest(key, 1, time.Minute)
+	if !allowed {
+		t.Fatal("First request should be allowed")
+	}
+
+	allowed, retryAfter, _ := services.AllowRequest(key, 1, time.Minute)
+	if allowed {
+		t.Error("Second request should be rate limited")
+	}
+	if retryAfter <= 0 || retryAfter > time.Minute {
+		t.Errorf("Unexpected retry after: %v", retryAfter)
+	}
+}
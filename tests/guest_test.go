@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestGuestAccess(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	owner := createTestUser(t, "ivan", "password123")
+
+	readOnly := models.Room{Name: "lobby", CreatorID: owner.ID}
+	open := models.Room{Name: "open lobby", CreatorID: owner.ID, AllowGuestMessages: true}
+	database.DB.Create(&readOnly)
+	database.DB.Create(&open)
+	database.DB.Create(&models.Message{RoomID: readOnly.ID, UserID: owner.ID, Content: "welcome"})
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	trustTestProxy(t, router)
+	router.POST("/auth/guest", handlers.CreateGuestSession)
+	api := router.Group("/", middleware.AuthMiddleware())
+	api.POST("/rooms", middleware.RequirePermission(rbac.PermRoomsCreate), handlers.CreateRoom)
+	api.GET("/rooms/:id/messages", middleware.RequirePermission(rbac.PermMessagesRead), handlers.GetMessages)
+	api.POST("/rooms/:id/messages", handlers.SendMessage(hub))
+
+	ip := map[string]string{"X-Forwarded-For": "198.51.100.70"}
+	if w := performJSON(router, http.MethodPost, "/auth/guest", "", ip); w.Code != http.StatusForbidden {
+		t.Errorf("Expected guest access to be disabled by default, got %d", w.Code)
+	}
+
+	config.AppConfig.Guest = config.GuestConfig{Enabled: true, IPLimit: 100, MessageLimit: 2, IdleTimeout: 60}
+	w := performJSON(router, http.MethodPost, "/auth/guest", "", ip)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp handlers.AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.User["is_guest"] != true || resp.User["role"] != string(rbac.RoleGuest) {
+		t.Errorf("Expected a guest user, got %v", resp.User)
+	}
+	if nickname, _ := resp.User["nickname"].(string); !strings.HasPrefix(nickname, "访客") {
+		t.Errorf("Expected generated nickname, got %q", nickname)
+	}
+	headers := map[string]string{"Authorization": "Bearer " + resp.Token}
+
+	// 访客可以阅读公开房间
+	if w := performJSON(router, http.MethodGet, fmt.Sprintf("/rooms/%d/messages", readOnly.ID), "", headers); w.Code != http.StatusOK {
+		t.Errorf("Expected guest to read messages, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, "/rooms", `{"name":"guest room"}`, headers); w.Code != http.StatusForbidden {
+		t.Errorf("Expected guest to be denied creating rooms, got %d", w.Code)
+	}
+
+	// 默认只读，房间开启后可以发言，发言单独限流
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/messages", readOnly.ID), `{"content":"hi"}`, headers); w.Code != http.StatusForbidden {
+		t.Errorf("Expected guest to be denied posting in read-only room, got %d", w.Code)
+	}
+	openPath := fmt.Sprintf("/rooms/%d/messages", open.ID)
+	for i := 0; i < 2; i++ {
+		if w := performJSON(router, http.MethodPost, openPath, `{"content":"hi"}`, headers); w.Code != http.StatusCreated {
+			t.Fatalf("Expected guest to post in open room, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if w := performJSON(router, http.MethodPost, openPath, `{"content":"hi"}`, headers); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected guest message limit, got %d", w.Code)
+	}
+
+	// 访客发言不会加入房间
+	var guest models.User
+	database.DB.Where("is_guest = ?", true).First(&guest)
+	if open.IsMember(database.DB, guest.ID) {
+		t.Error("Expected guest not to become a room member")
+	}
+}
+
+func TestDeleteIdleGuests(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	config.AppConfig.Guest = config.GuestConfig{Enabled: true, IPLimit: 100, MessageLimit: 5, IdleTimeout: 60}
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	trustTestProxy(t, router)
+	router.POST("/auth/guest", handlers.CreateGuestSession)
+
+	createGuest := func() uint {
+		w := performJSON(router, http.MethodPost, "/auth/guest", "", map[string]string{"X-Forwarded-For": "198.51.100.71"})
+		var resp handlers.AuthResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		id, _ := resp.User["id"].(float64)
+		return uint(id)
+	}
+	idle := createGuest()
+	active := createGuest()
+
+	past := time.Now().Add(-2 * time.Hour)
+	database.DB.Model(&models.User{}).Where("id IN ?", []uint{idle, active}).Update("created_at", past)
+	database.DB.Model(&models.Session{}).Where("user_id = ?", idle).Update("last_used_at", past)
+
+	if deleted := services.DeleteIdleGuests(hub); deleted != 1 {
+		t.Fatalf("Expected 1 idle guest to be deleted, got %d", deleted)
+	}
+
+	var count int64
+	database.DB.Model(&models.User{}).Where("id = ?", idle).Count(&count)
+	if count != 0 {
+		t.Error("Expected idle guest to be deleted")
+	}
+	database.DB.Model(&models.User{}).Where("id = ?", active).Count(&count)
+	if count != 1 {
+		t.Error("Expected active guest to be kept")
+	}
+}
+
+func TestDeleteIdleGuestsKeepsConnectedGuests(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	config.AppConfig.Guest = config.GuestConfig{Enabled: true, IPLimit: 100, MessageLimit: 5, IdleTimeout: 60}
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	trustTestProxy(t, router)
+	router.POST("/auth/guest", handlers.CreateGuestSession)
+	router.GET("/ws", handlers.HandleWebSocket(hub))
+
+	w := performJSON(router, http.MethodPost, "/auth/guest", "", map[string]string{"X-Forwarded-For": "198.51.100.72"})
+	var resp handlers.AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	id, _ := resp.User["id"].(float64)
+	guestID := uint(id)
+
+	room := models.Room{Name: "大厅", CreatorID: guestID}
+	database.DB.Create(&room)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	query := fmt.Sprintf("?room_id=%d", room.ID)
+	first, _, err := dialWebSocket(server, query, resp.Token)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	second, _, err := dialWebSocket(server, query, resp.Token)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer second.Close()
+
+	// 注册后会收到在线用户列表
+	for _, conn := range []*websocket.Conn{first, second} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatalf("Expected online users after connecting: %v", err)
+		}
+	}
+
+	// 关闭其中一个连接，注销时会把用户标记为离线
+	first.Close()
+	var guest models.User
+	for i := 0; i < 100; i++ {
+		database.DB.First(&guest, guestID)
+		if !guest.IsOnline {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if guest.IsOnline {
+		t.Fatal("Expected closed connection to be unregistered")
+	}
+
+	past := time.Now().Add(-2 * time.Hour)
+	database.DB.Model(&models.User{}).Where("id = ?", guestID).Updates(map[string]interface{}{"created_at": past, "last_seen": past})
+	database.DB.Model(&models.Session{}).Where("user_id = ?", guestID).Update("last_used_at", past)
+
+	// 另一个连接仍然保持，访客不会被删除
+	if deleted := services.DeleteIdleGuests(hub); deleted != 0 {
+		t.Errorf("Expected guest with an open connection to be kept, deleted %d", deleted)
+	}
+}
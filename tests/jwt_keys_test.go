@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writePrivateKey 将私钥以 PKCS8 PEM 格式写入文件
+func writePrivateKey(t *testing.T, path string, key interface{}) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+// useKeysDir 使用指定的密钥目录初始化签名密钥，测试结束后恢复 HS256
+func useKeysDir(t *testing.T, dir, activeKeyID string) {
+	setupTestConfig()
+	config.AppConfig.JWT.KeysDir = dir
+	config.AppConfig.JWT.ActiveKeyID = activeKeyID
+	if err := auth.InitKeys(); err != nil {
+		t.Fatalf("Failed to init keys: %v", err)
+	}
+
+	t.Cleanup(func() {
+		config.AppConfig.JWT.KeysDir = ""
+		auth.InitKeys()
+	})
+}
+
+func TestAsymmetricSigningWithRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	writePrivateKey(t, filepath.Join(dir, "rsa-2024.pem"), rsaKey)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	writePrivateKey(t, filepath.Join(dir, "ed-2025.pem"), edKey)
+
+	// 使用 RSA 密钥签名
+	useKeysDir(t, dir, "rsa-2024")
+	oldToken, err := auth.GenerateToken(1, "testuser", "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(oldToken, &auth.Claims{})
+	if err != nil {
+		t.Fatalf("Failed to decode token: %v", err)
+	}
+	if parsed.Header["kid"] != "rsa-2024" || parsed.Method.Alg() != "RS256" {
+		t.Errorf("Expected RS256 token with kid rsa-2024, got %v %v", parsed.Method.Alg(), parsed.Header["kid"])
+	}
+
+	// 轮换到 Ed25519 密钥后，旧 token 仍然可以验证
+	useKeysDir(t, dir, "ed-2025")
+	newToken, err := auth.GenerateToken(1, "testuser", "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	if _, err := auth.ParseToken(oldToken); err != nil {
+		t.Errorf("Token signed with previous key should still verify: %v", err)
+	}
+	if _, err := auth.ParseToken(newToken); err != nil {
+		t.Errorf("Token signed with active key should verify: %v", err)
+	}
+
+	// JWKS 只暴露公钥
+	jwks := auth.JWKS()["keys"].([]map[string]interface{})
+	if len(jwks) != 2 {
+		t.Fatalf("Expected 2 keys in JWKS, got %d", len(jwks))
+	}
+	for _, key := range jwks {
+		if _, exists := key["d"]; exists {
+			t.Error("JWKS should not include private key material")
+		}
+	}
+}
+
+func TestHS256TokenRejectedWithAsymmetricKeys(t *testing.T) {
+	setupTestConfig()
+	hsToken, err := auth.GenerateToken(1, "testuser", "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	dir := t.TempDir()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, filepath.Join(dir, "ed.pem"), edKey)
+	useKeysDir(t, dir, "")
+
+	if _, err := auth.ParseToken(hsToken); err == nil {
+		t.Error("HS256 token should be rejected when asymmetric keys are configured")
+	}
+
+	token, err := auth.GenerateToken(1, "testuser", "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if _, err := auth.ParseToken(token); err != nil {
+		t.Errorf("EdDSA token should verify: %v", err)
+	}
+}
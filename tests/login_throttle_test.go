@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoginLockout(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	user := createTestUser(t, "mallory", "password123")
+	t.Cleanup(func() {
+		services.ResetLoginFailures(fmt.Sprintf("user:%d", user.ID))
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	trustTestProxy(t, router)
+	router.POST("/auth/login", handlers.Login)
+
+	headers := map[string]string{"X-Forwarded-For": "203.0.113.10"}
+	for i := 1; i <= 5; i++ {
+		w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"mallory","password":"wrong"}`, headers)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Attempt %d: expected 401, got %d", i, w.Code)
+		}
+		if i == 5 && w.Header().Get("Retry-After") != "60" {
+			t.Errorf("Expected Retry-After 60 after reaching the limit, got %q", w.Header().Get("Retry-After"))
+		}
+	}
+
+	// 锁定期间正确的密码也不能登录
+	w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"mallory","password":"password123"}`, headers)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+
+	// 使用邮箱登录同一账号同样被锁定
+	w = performJSON(router, http.MethodPost, "/auth/login", `{"username":"`+user.Email+`","password":"password123"}`, map[string]string{"X-Forwarded-For": "203.0.113.11"})
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected account lock to apply to email login, got %d", w.Code)
+	}
+
+	var attempts []models.LoginAttempt
+	database.DB.Where("user_id = ?", user.ID).Find(&attempts)
+	if len(attempts) != 7 {
+		t.Fatalf("Expected 7 recorded attempts, got %d", len(attempts))
+	}
+	if attempts[0].IP != "203.0.113.10" || attempts[0].Reason != models.LoginFailureInvalidPassword {
+		t.Errorf("Unexpected attempt record: %+v", attempts[0])
+	}
+	if attempts[5].Reason != models.LoginFailureLocked {
+		t.Errorf("Expected locked attempt to be recorded, got %s", attempts[5].Reason)
+	}
+}
+
+func TestLoginLockoutPerIP(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	config.AppConfig.Auth.LoginIPMaxFailures = 3
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	trustTestProxy(t, router)
+	router.POST("/auth/login", handlers.Login)
+
+	// 同一 IP 尝试不同用户名
+	headers := map[string]string{"X-Forwarded-For": "203.0.113.20"}
+	for i := 1; i <= 3; i++ {
+		w := performJSON(router, http.MethodPost, "/auth/login", fmt.Sprintf(`{"username":"ghost%d","password":"wrong"}`, i), headers)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Attempt %d: expected 401, got %d", i, w.Code)
+		}
+	}
+
+	w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"ghost4","password":"wrong"}`, headers)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected IP to be locked, got %d", w.Code)
+	}
+
+	// 其他 IP 不受影响
+	w = performJSON(router, http.MethodPost, "/auth/login", `{"username":"ghost4","password":"wrong"}`, map[string]string{"X-Forwarded-For": "203.0.113.21"})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected other IPs to be unaffected, got %d", w.Code)
+	}
+}
+
+func TestRegisterRateLimit(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	config.AppConfig.Auth.RegisterIPLimit = 2
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	trustTestProxy(t, router)
+	router.POST("/auth/register", handlers.Register)
+
+	headers := map[string]string{"X-Forwarded-For": "203.0.113.30"}
+	for i := 1; i <= 3; i++ {
+		body := fmt.Sprintf(`{"username":"bot%d","email":"bot%d@example.com","password":"password123"}`, i, i)
+		w := performJSON(router, http.MethodPost, "/auth/register", body, headers)
+
+		if i <= 2 && w.Code != http.StatusCreated {
+			t.Fatalf("Registration %d: expected 201, got %d: %s", i, w.Code, w.Body.String())
+		}
+		if i == 3 {
+			if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+				t.Errorf("Expected 429 with Retry-After, got %d", w.Code)
+			}
+		}
+	}
+}
+
+func TestForwardedForIgnoredWithoutTrustedProxy(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	config.AppConfig.Auth.RegisterIPLimit = 2
+
+	// 默认不信任任何代理，伪造的 X-Forwarded-For 不能绕过按 IP 的限制
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.SetTrustedProxies(nil)
+	router.POST("/auth/register", handlers.Register)
+	router.POST("/auth/login", handlers.Login)
+
+	for i := 1; i <= 3; i++ {
+		body := fmt.Sprintf(`{"username":"spoof%d","email":"spoof%d@example.com","password":"password123"}`, i, i)
+		w := performJSON(router, http.MethodPost, "/auth/register", body, map[string]string{"X-Forwarded-For": fmt.Sprintf("203.0.113.%d", 40+i)})
+		if i == 3 && w.Code != http.StatusTooManyRequests {
+			t.Errorf("Expected spoofed addresses to share the limit, got %d", w.Code)
+		}
+	}
+
+	performJSON(router, http.MethodPost, "/auth/login", `{"username":"ghost5","password":"wrong"}`, map[string]string{"X-Forwarded-For": "203.0.113.50"})
+	var attempt models.LoginAttempt
+	database.DB.Where("username = ?", "ghost5").First(&attempt)
+	if attempt.IP != testProxyAddr {
+		t.Errorf("Expected connection address to be recorded, got %q", attempt.IP)
+	}
+}
+
+func TestAdminLoginAttempts(t *testing.T) {
+	setupTestDB(t)
+
+	admin := createTestUser(t, "admin", "password123")
+	database.DB.Model(admin).Update("role", rbac.RoleSiteAdmin)
+	user := createTestUser(t, "bob", "password123")
+
+	database.DB.Create(&models.LoginAttempt{Username: "bob", UserID: &user.ID, IP: "198.51.100.1", Reason: models.LoginFailureInvalidPassword})
+	database.DB.Create(&models.LoginAttempt{Username: "nobody", IP: "198.51.100.2", Reason: models.LoginFailureUnknownUser})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/login-attempts", middleware.AuthMiddleware(), middleware.RequirePermission(rbac.PermAuditRead), handlers.GetLoginAttempts)
+
+	userToken, _ := auth.GenerateToken(user.ID, user.Username, user.Email)
+	w := performJSON(router, http.MethodGet, "/admin/login-attempts", "", map[string]string{"Authorization": "Bearer " + userToken})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non-admin, got %d", w.Code)
+	}
+
+	adminToken, _ := auth.GenerateToken(admin.ID, admin.Username, admin.Email)
+	w = performJSON(router, http.MethodGet, "/admin/login-attempts?ip=198.51.100.1", "", map[string]string{"Authorization": "Bearer " + adminToken})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Attempts []models.LoginAttempt `json:"attempts"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Attempts) != 1 || resp.Attempts[0].Username != "bob" {
+		t.Errorf("Unexpected attempts: %+v", resp.Attempts)
+	}
+}
@@ -0,0 +1,355 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/oidc"
+	"gin-chat-room/internal/services"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIdP 本地模拟的 OpenID Connect 身份提供方
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mutex sync.Mutex
+	codes map[string]mockGrant
+}
+
+// mockGrant 模拟授权页面签发的授权码
+type mockGrant struct {
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	idp := &mockIdP{key: key, codes: make(map[string]mockGrant)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "mock-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		clientID, clientSecret, _ := r.BasicAuth()
+
+		idp.mutex.Lock()
+		grant, ok := idp.codes[r.Form.Get("code")]
+		delete(idp.codes, r.Form.Get("code"))
+		idp.mutex.Unlock()
+
+		if !ok || clientID != "chat-client" || clientSecret != "chat-secret" ||
+			oidc.CodeChallenge(r.Form.Get("code_verifier")) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   idp.server.URL,
+			"aud":   "chat-client",
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": grant.nonce,
+		}
+		for k, v := range grant.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "mock-key"
+		idToken, _ := token.SignedString(key)
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "mock-access-token",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+// authorize 模拟用户在授权页面登录，返回授权码
+func (idp *mockIdP) authorize(t *testing.T, authURL string, claims jwt.MapClaims) (code, state string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Invalid authorization url: %v", err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("Authorization request does not use PKCE: %s", authURL)
+	}
+
+	code, _ = auth.GenerateRandomToken(16)
+	idp.mutex.Lock()
+	idp.codes[code] = mockGrant{
+		challenge: query.Get("code_challenge"),
+		nonce:     query.Get("nonce"),
+		claims:    claims,
+	}
+	idp.mutex.Unlock()
+
+	return code, query.Get("state")
+}
+
+func setupOIDCRouter(t *testing.T, autoProvision bool) (*gin.Engine, *mockIdP) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	idp := newMockIdP(t)
+	oidc.Register(oidc.NewOIDCProvider(config.OIDCProviderConfig{
+		Name:          "mock",
+		DisplayName:   "Mock IdP",
+		Issuer:        idp.server.URL,
+		ClientID:      "chat-client",
+		ClientSecret:  "chat-secret",
+		Scopes:        []string{"openid", "email", "profile"},
+		AutoProvision: autoProvision,
+	}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/auth/oidc/providers", handlers.ListOIDCProviders)
+	router.GET("/api/v1/auth/oidc/:provider/login", handlers.OIDCLogin)
+	router.GET("/api/v1/auth/oidc/:provider/callback", handlers.OIDCCallback)
+	router.POST("/api/v1/auth/oidc/token", handlers.ExchangeOIDCLoginCode)
+
+	return router, idp
+}
+
+// oidcLogin 完成一次完整的外部登录，返回回调跳转地址中的参数
+func oidcLogin(t *testing.T, router *gin.Engine, idp *mockIdP, claims jwt.MapClaims) url.Values {
+	w := performJSON(router, http.MethodGet, "/api/v1/auth/oidc/mock/login", "", nil)
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected 302, got %d: %s", w.Code, w.Body.String())
+	}
+
+	code, state := idp.authorize(t, w.Header().Get("Location"), claims)
+
+	w = performJSON(router, http.MethodGet, "/api/v1/auth/oidc/mock/callback?code="+code+"&state="+url.QueryEscape(state), "", oidcStateCookie(w))
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected 302, got %d: %s", w.Code, w.Body.String())
+	}
+	location, _ := url.Parse(w.Header().Get("Location"))
+	return location.Query()
+}
+
+// oidcStateCookie 返回登录跳转响应中设置的状态 Cookie，用于回调请求
+func oidcStateCookie(w *httptest.ResponseRecorder) map[string]string {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "oidc_state" {
+			return map[string]string{"Cookie": cookie.Name + "=" + cookie.Value}
+		}
+	}
+	return nil
+}
+
+func TestOIDCAutoProvision(t *testing.T) {
+	router, idp := setupOIDCRouter(t, true)
+
+	result := oidcLogin(t, router, idp, jwt.MapClaims{
+		"sub":                "idp-user-1",
+		"email":              "Carol@Example.com",
+		"email_verified":     true,
+		"name":               "Carol",
+		"preferred_username": "carol",
+	})
+	if result.Get("oidc_login") == "" {
+		t.Fatalf("Expected login code, got %v", result)
+	}
+
+	w := performJSON(router, http.MethodPost, "/api/v1/auth/oidc/token", `{"code":"`+result.Get("oidc_login")+`"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp handlers.AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	// 签发的是与密码登录相同的访问令牌
+	claims, err := auth.ParseToken(resp.Token)
+	if err != nil {
+		t.Fatalf("Expected a regular access token: %v", err)
+	}
+
+	var user models.User
+	database.DB.First(&user, claims.UserID)
+	if user.Username != "carol" || user.Email != "carol@example.com" || !user.EmailVerified {
+		t.Errorf("Unexpected provisioned user: %+v", user)
+	}
+	if user.HasPassword() {
+		t.Error("Expected provisioned user to have no usable password")
+	}
+
+	// 登录码只能使用一次
+	w = performJSON(router, http.MethodPost, "/api/v1/auth/oidc/token", `{"code":"`+result.Get("oidc_login")+`"}`, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected used login code to be rejected, got %d", w.Code)
+	}
+
+	// 再次登录使用已关联的身份，不会重复创建用户
+	oidcLogin(t, router, idp, jwt.MapClaims{"sub": "idp-user-1", "email": "carol@example.com", "email_verified": true})
+	var count int64
+	database.DB.Model(&models.User{}).Where("email = ?", "carol@example.com").Count(&count)
+	if count != 1 {
+		t.Errorf("Expected one user, got %d", count)
+	}
+}
+
+func TestOIDCLoginRejectsUnavailableAccounts(t *testing.T) {
+	router, idp := setupOIDCRouter(t, true)
+
+	identity := jwt.MapClaims{"sub": "idp-user-9", "email": "dave@example.com", "email_verified": true, "preferred_username": "dave"}
+	exchange := func() int {
+		result := oidcLogin(t, router, idp, identity)
+		return performJSON(router, http.MethodPost, "/api/v1/auth/oidc/token", `{"code":"`+result.Get("oidc_login")+`"}`, nil).Code
+	}
+	if code := exchange(); code != http.StatusOK {
+		t.Fatalf("Expected first login to succeed, got %d", code)
+	}
+	var user models.User
+	database.DB.Where("email = ?", "dave@example.com").First(&user)
+
+	// 宽限期内重新登录撤销注销
+	database.DB.Model(&user).Update("deletion_scheduled_at", time.Now().Add(time.Hour))
+	if code := exchange(); code != http.StatusOK {
+		t.Errorf("Expected login during the grace period to succeed, got %d", code)
+	}
+	var reloaded models.User
+	database.DB.First(&reloaded, user.ID)
+	if reloaded.DeletionScheduledAt != nil {
+		t.Error("Expected login to cancel the scheduled deletion")
+	}
+
+	// 机器人、占位账号和宽限期已结束的账号不能登录
+	for _, update := range []map[string]interface{}{
+		{"is_bot": true},
+		{"is_placeholder": true},
+		{"deletion_scheduled_at": time.Now().Add(-time.Minute)},
+	} {
+		database.DB.Model(&user).Updates(update)
+		if code := exchange(); code != http.StatusForbidden {
+			t.Errorf("Expected login to be rejected after %v, got %d", update, code)
+		}
+		database.DB.Model(&user).Updates(map[string]interface{}{"is_bot": false, "is_placeholder": false, "deletion_scheduled_at": nil})
+	}
+}
+
+func TestOIDCLinksVerifiedEmail(t *testing.T) {
+	router, idp := setupOIDCRouter(t, false)
+
+	user := createTestUser(t, "dave", "password123")
+
+	// 本地邮箱未验证时不能关联
+	result := oidcLogin(t, router, idp, jwt.MapClaims{"sub": "idp-dave", "email": user.Email, "email_verified": true})
+	if result.Get("oidc_error") == "" {
+		t.Fatalf("Expected linking to an unverified account to fail, got %v", result)
+	}
+
+	database.DB.Model(user).Update("email_verified", true)
+
+	// 提供方未验证的邮箱不能用于关联
+	result = oidcLogin(t, router, idp, jwt.MapClaims{"sub": "idp-dave", "email": user.Email, "email_verified": false})
+	if result.Get("oidc_error") == "" {
+		t.Fatalf("Expected unverified provider email to be rejected, got %v", result)
+	}
+
+	result = oidcLogin(t, router, idp, jwt.MapClaims{"sub": "idp-dave", "email": user.Email, "email_verified": true})
+	w := performJSON(router, http.MethodPost, "/api/v1/auth/oidc/token", `{"code":"`+result.Get("oidc_login")+`"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp handlers.AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if claims, err := auth.ParseToken(resp.Token); err != nil || claims.UserID != user.ID {
+		t.Error("Expected to be logged in as the existing user")
+	}
+
+	// 未开启自动创建时，没有匹配账号的身份不能登录
+	result = oidcLogin(t, router, idp, jwt.MapClaims{"sub": "idp-erin", "email": "erin@example.com", "email_verified": true})
+	if result.Get("oidc_error") == "" {
+		t.Errorf("Expected unknown identity to be rejected, got %v", result)
+	}
+}
+
+func TestOIDCRejectsInvalidState(t *testing.T) {
+	router, idp := setupOIDCRouter(t, true)
+
+	w := performJSON(router, http.MethodGet, "/api/v1/auth/oidc/mock/login", "", nil)
+	cookie := oidcStateCookie(w)
+	if cookie == nil {
+		t.Fatal("Expected login to set the state cookie")
+	}
+	if setCookie := w.Header().Get("Set-Cookie"); !strings.Contains(setCookie, "HttpOnly") || !strings.Contains(setCookie, "SameSite=Lax") {
+		t.Errorf("Expected HttpOnly SameSite=Lax state cookie, got %q", setCookie)
+	}
+	code, state := idp.authorize(t, w.Header().Get("Location"), jwt.MapClaims{"sub": "idp-frank", "email": "frank@example.com", "email_verified": true})
+	callback := "/api/v1/auth/oidc/mock/callback?code=" + code + "&state=" + url.QueryEscape(state)
+
+	w = performJSON(router, http.MethodGet, "/api/v1/auth/oidc/mock/callback?code="+code+"&state=forged", "", cookie)
+	location, _ := url.Parse(w.Header().Get("Location"))
+	if location.Query().Get("oidc_error") == "" {
+		t.Errorf("Expected forged state to be rejected, got %s", w.Header().Get("Location"))
+	}
+
+	// 其他浏览器（没有或有别的状态 Cookie）打开回调地址不能登录，也不会消耗状态
+	for _, headers := range []map[string]string{nil, {"Cookie": "oidc_state=other"}} {
+		w = performJSON(router, http.MethodGet, callback, "", headers)
+		location, _ = url.Parse(w.Header().Get("Location"))
+		if location.Query().Get("oidc_error") == "" {
+			t.Errorf("Expected callback without matching cookie to be rejected, got %s", w.Header().Get("Location"))
+		}
+	}
+
+	// 状态只能使用一次
+	w = performJSON(router, http.MethodGet, callback, "", cookie)
+	location, _ = url.Parse(w.Header().Get("Location"))
+	if location.Query().Get("oidc_login") == "" {
+		t.Fatalf("Expected login from the original browser to succeed, got %s", w.Header().Get("Location"))
+	}
+	w = performJSON(router, http.MethodGet, callback, "", cookie)
+	location, _ = url.Parse(w.Header().Get("Location"))
+	if location.Query().Get("oidc_error") == "" {
+		t.Errorf("Expected replayed state to be rejected, got %s", w.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"encoding/json"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestChangePassword(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	createTestUser(t, "carol", "password123")
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	trustTestProxy(t, router)
+	router.POST("/auth/login", handlers.Login)
+	router.POST("/auth/refresh", handlers.RefreshToken)
+	protected := router.Group("/", middleware.AuthMiddleware())
+	protected.GET("/profile", handlers.GetProfile)
+	protected.PUT("/profile/password", handlers.ChangePassword(hub))
+
+	login := func(password string) *handlers.AuthResponse {
+		w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"carol","password":"`+password+`"}`, map[string]string{"X-Forwarded-For": "198.51.100.40"})
+		if w.Code != http.StatusOK {
+			return nil
+		}
+		var resp handlers.AuthResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return &resp
+	}
+
+	laptop := login("password123")
+	phone := login("password123")
+	if laptop == nil || phone == nil {
+		t.Fatal("Expected login to succeed")
+	}
+	laptopAuth := map[string]string{"Authorization": "Bearer " + laptop.Token, "X-Forwarded-For": "198.51.100.40"}
+
+	// 当前密码错误
+	w := performJSON(router, http.MethodPut, "/profile/password", `{"current_password":"wrong","new_password":"newpassword456"}`, laptopAuth)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for wrong current password, got %d", w.Code)
+	}
+
+	// 新密码不符合策略
+	w = performJSON(router, http.MethodPut, "/profile/password", `{"current_password":"password123","new_password":"abc"}`, laptopAuth)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for weak password, got %d", w.Code)
+	}
+	var policyResp struct {
+		Violations []auth.PasswordViolation `json:"violations"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &policyResp)
+	if len(policyResp.Violations) == 0 {
+		t.Errorf("Expected policy violations in response: %s", w.Body.String())
+	}
+
+	w = performJSON(router, http.MethodPut, "/profile/password", `{"current_password":"password123","new_password":"newpassword456"}`, laptopAuth)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var changed handlers.AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &changed)
+
+	// 之前签发的访问令牌和刷新令牌全部失效
+	for name, token := range map[string]string{"laptop": laptop.Token, "phone": phone.Token} {
+		if w := performJSON(router, http.MethodGet, "/profile", "", map[string]string{"Authorization": "Bearer " + token}); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected old %s token to be rejected, got %d", name, w.Code)
+		}
+	}
+	if w := performJSON(router, http.MethodPost, "/auth/refresh", `{"refresh_token":"`+phone.RefreshToken+`"}`, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected old refresh token to be rejected, got %d", w.Code)
+	}
+
+	// 修改密码时签发的新令牌可用，新密码可以登录
+	if w := performJSON(router, http.MethodGet, "/profile", "", map[string]string{"Authorization": "Bearer " + changed.Token}); w.Code != http.StatusOK {
+		t.Errorf("Expected new token to be accepted, got %d", w.Code)
+	}
+	if login("password123") != nil {
+		t.Error("Old password should no longer work")
+	}
+	if login("newpassword456") == nil {
+		t.Error("New password should work")
+	}
+
+	var user models.User
+	database.DB.Where("username = ?", "carol").First(&user)
+	if user.TokenVersion == 0 {
+		t.Error("Expected token version to be incremented")
+	}
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"gin-chat-room/config"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/passwordhash"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// resetHasher 恢复默认的哈希配置
+func resetHasher(t *testing.T) {
+	t.Cleanup(func() {
+		setupTestConfig()
+		passwordhash.InitHasher()
+	})
+}
+
+func TestArgon2idHashFormat(t *testing.T) {
+	setupTestConfig()
+	resetHasher(t)
+
+	user := &models.User{}
+	if err := user.SetPassword("Correct-Horse-7"); err != nil {
+		t.Fatalf("Failed to set password: %v", err)
+	}
+	if !strings.HasPrefix(user.Password, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Fatalf("Expected PHC argon2id hash, got %s", user.Password)
+	}
+	if !user.CheckPassword("Correct-Horse-7") || user.CheckPassword("correct-horse-7") {
+		t.Error("Argon2id verification failed")
+	}
+	if user.PasswordNeedsRehash() {
+		t.Error("Hash with current parameters should not need rehash")
+	}
+
+	// 修改参数后旧哈希仍然可以验证，但需要重新生成
+	config.AppConfig.Auth.Argon2Memory = 8 * 1024
+	if err := passwordhash.InitHasher(); err != nil {
+		t.Fatalf("Failed to init hasher: %v", err)
+	}
+	if !user.CheckPassword("Correct-Horse-7") {
+		t.Error("Hash with old parameters should still verify")
+	}
+	if !user.PasswordNeedsRehash() {
+		t.Error("Hash with old parameters should need rehash")
+	}
+
+	// 切换为 bcrypt
+	config.AppConfig.Auth.PasswordHashAlgorithm = "bcrypt"
+	config.AppConfig.Auth.BcryptCost = bcrypt.MinCost
+	if err := passwordhash.InitHasher(); err != nil {
+		t.Fatalf("Failed to init hasher: %v", err)
+	}
+	if !user.PasswordNeedsRehash() {
+		t.Error("Argon2id hash should need rehash when bcrypt is configured")
+	}
+	bcryptUser := &models.User{}
+	bcryptUser.SetPassword("Correct-Horse-7")
+	if !strings.HasPrefix(bcryptUser.Password, "$2a$04$") {
+		t.Errorf("Expected bcrypt hash, got %s", bcryptUser.Password)
+	}
+
+	config.AppConfig.Auth.PasswordHashAlgorithm = "md5"
+	if err := passwordhash.InitHasher(); err == nil {
+		t.Error("Expected unknown algorithm to be rejected")
+	}
+}
+
+func TestLoginUpgradesBcryptHash(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	resetHasher(t)
+
+	// 迁移前使用 bcrypt 保存的用户
+	legacyHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	legacy := models.User{Username: "legacy", Email: "legacy@example.com", Password: string(legacyHash)}
+	database.DB.Create(&legacy)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	trustTestProxy(t, router)
+	router.POST("/auth/login", handlers.Login)
+
+	headers := map[string]string{"X-Forwarded-For": "198.51.100.60"}
+	if w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"legacy","password":"wrong"}`, headers); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401, got %d", w.Code)
+	}
+	database.DB.First(&legacy, legacy.ID)
+	if legacy.Password != string(legacyHash) {
+		t.Fatal("Failed login must not rehash the password")
+	}
+
+	if w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"legacy","password":"password123"}`, headers); w.Code != http.StatusOK {
+		t.Fatalf("Expected bcrypt user to log in, got %d: %s", w.Code, w.Body.String())
+	}
+	database.DB.First(&legacy, legacy.ID)
+	if !strings.HasPrefix(legacy.Password, "$argon2id$") {
+		t.Fatalf("Expected hash to be upgraded to argon2id, got %s", legacy.Password)
+	}
+
+	if w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"legacy","password":"password123"}`, headers); w.Code != http.StatusOK {
+		t.Errorf("Expected login with upgraded hash to succeed, got %d", w.Code)
+	}
+}
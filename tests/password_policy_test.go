@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func violatedRules(t *testing.T, err error) map[string]bool {
+	t.Helper()
+	var policyErr *auth.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Expected a password policy error, got %v", err)
+	}
+	rules := make(map[string]bool)
+	for _, v := range policyErr.Violations {
+		rules[v.Rule] = true
+	}
+	return rules
+}
+
+func TestPasswordPolicyListsAllViolations(t *testing.T) {
+	setupTestConfig()
+	config.AppConfig.Auth.PasswordMinCharClasses = 3
+
+	rules := violatedRules(t, auth.ValidatePassword("ecila", "alice", "someone@example.com"))
+	for _, rule := range []string{auth.PasswordRuleMinLength, auth.PasswordRuleCharClasses, auth.PasswordRuleUsername} {
+		if !rules[rule] {
+			t.Errorf("Expected rule %s to fail, got %v", rule, rules)
+		}
+	}
+	if rules[auth.PasswordRuleEmail] {
+		t.Error("Email rule should pass")
+	}
+
+	rules = violatedRules(t, auth.ValidatePassword("Xx1-"+strings.Repeat("a", 70), "bob", "Mallory.Smith@example.com"))
+	if !rules[auth.PasswordRuleMaxLength] {
+		t.Errorf("Expected max length rule to fail, got %v", rules)
+	}
+	if err := auth.ValidatePassword("my-MALLORY.smith-1", "bob", "mallory.smith@example.com"); err == nil {
+		t.Error("Expected password containing the email to be rejected")
+	}
+
+	if err := auth.ValidatePassword("Correct-Horse-7", "alice", "alice@example.com"); err != nil {
+		t.Errorf("Expected strong password to pass, got %v", err)
+	}
+}
+
+func TestBreachedPasswordList(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	sum := sha1.Sum([]byte("Summer2024!"))
+	listFile := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# top passwords\n" + strings.ToUpper(hex.EncodeToString(sum[:])) + ":12345\n" +
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n"
+	if err := os.WriteFile(listFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write list: %v", err)
+	}
+
+	config.AppConfig.Auth.PasswordBreachListFile = listFile
+	if err := auth.InitBreachedPasswords(); err != nil {
+		t.Fatalf("Failed to load breached list: %v", err)
+	}
+	t.Cleanup(func() {
+		config.AppConfig.Auth.PasswordBreachListFile = ""
+		auth.InitBreachedPasswords()
+	})
+
+	if !auth.IsBreachedPassword("password") || !auth.IsBreachedPassword("Summer2024!") {
+		t.Error("Expected listed passwords to be reported as breached")
+	}
+	if auth.IsBreachedPassword("Summer2025!") {
+		t.Error("Unlisted password should not be reported")
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	trustTestProxy(t, router)
+	router.POST("/auth/register", handlers.Register)
+
+	w := performJSON(router, http.MethodPost, "/auth/register", `{"username":"erin","email":"erin@example.com","password":"Summer2024!"}`, map[string]string{"X-Forwarded-For": "198.51.100.50"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Violations []auth.PasswordViolation `json:"violations"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Violations) != 1 || resp.Violations[0].Rule != auth.PasswordRuleBreached {
+		t.Errorf("Expected only the breached rule to fail, got %+v", resp.Violations)
+	}
+
+	os.WriteFile(listFile, []byte("not-a-hash\n"), 0o600)
+	if err := auth.InitBreachedPasswords(); err == nil {
+		t.Error("Expected malformed list to fail loading")
+	}
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPasswordResetFlow(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	mailFile := useMailFile(t)
+
+	user := createTestUser(t, "resetuser", "oldpassword")
+	accessToken, _ := auth.GenerateToken(user.ID, user.Username, user.Email)
+	refreshToken, _ := auth.IssueRefreshToken(user.ID, "")
+	database.DB.Create(&models.Room{Name: "大厅", CreatorID: user.ID})
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", handlers.HandleWebSocket(hub))
+	router.POST("/auth/password/forgot", handlers.ForgotPassword)
+	router.POST("/auth/password/reset", handlers.ResetPassword(hub))
+
+	// 未注册的邮箱返回相同的响应，且不发送邮件
+	w := performJSON(router, http.MethodPost, "/auth/password/forgot", `{"email":"nobody@example.com"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for unknown email, got %d", w.Code)
+	}
+	if _, err := os.Stat(mailFile); err == nil {
+		t.Fatal("No mail should be sent for unknown email")
+	}
+
+	w = performJSON(router, http.MethodPost, "/auth/password/forgot", `{"email":"resetuser@example.com"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	// 从邮件中提取重置令牌
+	mail, err := os.ReadFile(mailFile)
+	if err != nil {
+		t.Fatalf("Failed to read mail file: %v", err)
+	}
+	match := regexp.MustCompile(`reset_token=([\w-]+)`).FindSubmatch(mail)
+	if match == nil {
+		t.Fatalf("Reset link not found in mail: %s", mail)
+	}
+	resetToken := string(match[1])
+
+	// 数据库中不应该保存明文令牌
+	var count int64
+	database.DB.Model(&models.PasswordResetToken{}).Where("token_hash = ?", resetToken).Count(&count)
+	if count != 0 {
+		t.Error("Reset token should not be stored in plain text")
+	}
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	conn, _, err := dialWebSocket(server, "", accessToken)
+	if err != nil {
+		t.Fatalf("Failed to connect websocket: %v", err)
+	}
+	defer conn.Close()
+
+	w = performJSON(router, http.MethodPost, "/auth/password/reset", `{"token":"`+resetToken+`","password":"newpassword"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 重置前建立的 WebSocket 连接被断开
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+				t.Fatal("Expected websocket to be closed after password reset")
+			}
+			break
+		}
+	}
+
+	// 重置令牌只能使用一次
+	w = performJSON(router, http.MethodPost, "/auth/password/reset", `{"token":"`+resetToken+`","password":"anotherpassword"}`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 when reusing reset token, got %d", w.Code)
+	}
+
+	var updated models.User
+	database.DB.First(&updated, user.ID)
+	if !updated.CheckPassword("newpassword") {
+		t.Error("Password should be updated after reset")
+	}
+
+	// 重置前签发的令牌全部失效
+	if _, _, authErr := middleware.Authenticate(accessToken); authErr == nil {
+		t.Error("Access token issued before reset should be rejected")
+	}
+	if _, _, err := auth.RotateRefreshToken(refreshToken); err == nil {
+		t.Error("Refresh token issued before reset should be revoked")
+	}
+
+	// 重置后同一秒内签发的令牌有效
+	newToken, _, _ := auth.GenerateSessionToken(&updated, "")
+	if _, _, authErr := middleware.Authenticate(newToken); authErr != nil {
+		t.Errorf("Token issued right after reset should be accepted: %v", authErr.Message)
+	}
+}
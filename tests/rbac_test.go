@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRolePermissions(t *testing.T) {
+	cases := []struct {
+		role rbac.Role
+		perm rbac.Permission
+		want bool
+	}{
+		{rbac.RoleGuest, rbac.PermRoomsView, true},
+		{rbac.RoleGuest, rbac.PermMessagesSend, false},
+		{rbac.RoleUser, rbac.PermRoomsCreate, true},
+		{rbac.RoleUser, rbac.PermRoomsViewPrivate, false},
+		{rbac.RoleModerator, rbac.PermRoomsModerate, true},
+		{rbac.RoleModerator, rbac.PermUsersManage, false},
+		{rbac.RoleSiteAdmin, rbac.PermUsersManage, true},
+		{"unknown", rbac.PermRoomsView, false},
+	}
+	for _, tc := range cases {
+		if got := rbac.Has(string(tc.role), tc.perm); got != tc.want {
+			t.Errorf("Has(%s, %s) = %v, want %v", tc.role, tc.perm, got, tc.want)
+		}
+	}
+}
+
+func TestRoomPermissions(t *testing.T) {
+	setupTestDB(t)
+
+	creator := createTestUser(t, "creator", "password123")
+	roomAdmin := createTestUser(t, "roomadmin", "password123")
+	member := createTestUser(t, "member", "password123")
+	moderator := createTestUser(t, "moderator", "password123")
+	siteAdmin := createTestUser(t, "siteadmin", "password123")
+	moderator.Role = string(rbac.RoleModerator)
+	siteAdmin.Role = string(rbac.RoleSiteAdmin)
+
+	room := models.Room{Name: "permissions", CreatorID: creator.ID}
+	database.DB.Create(&room)
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: roomAdmin.ID, Role: "admin"})
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: member.ID, Role: "member"})
+
+	cases := []struct {
+		name  string
+		check func(*models.User, *models.Room) bool
+		user  *models.User
+		want  bool
+	}{
+		{"CanManageRoom", rbac.CanManageRoom, creator, true},
+		{"CanManageRoom", rbac.CanManageRoom, roomAdmin, true},
+		{"CanManageRoom", rbac.CanManageRoom, member, false},
+		{"CanManageRoom", rbac.CanManageRoom, moderator, false},
+		{"CanManageRoom", rbac.CanManageRoom, siteAdmin, true},
+		{"CanModerateRoom", rbac.CanModerateRoom, roomAdmin, true},
+		{"CanModerateRoom", rbac.CanModerateRoom, member, false},
+		{"CanModerateRoom", rbac.CanModerateRoom, moderator, true},
+		{"CanActAsRoomCreator", rbac.CanActAsRoomCreator, creator, true},
+		{"CanActAsRoomCreator", rbac.CanActAsRoomCreator, roomAdmin, false},
+		{"CanActAsRoomCreator", rbac.CanActAsRoomCreator, moderator, false},
+		{"CanActAsRoomCreator", rbac.CanActAsRoomCreator, siteAdmin, true},
+		{"CanModerateRoomAdmins", rbac.CanModerateRoomAdmins, roomAdmin, false},
+		{"CanModerateRoomAdmins", rbac.CanModerateRoomAdmins, moderator, true},
+	}
+	for _, tc := range cases {
+		if got := tc.check(tc.user, &room); got != tc.want {
+			t.Errorf("%s(%s) = %v, want %v", tc.name, tc.user.Username, got, tc.want)
+		}
+	}
+}
+
+func TestRoleBasedAccess(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	tokenFor := func(name string, role rbac.Role) (*models.User, map[string]string) {
+		user := createTestUser(t, name, "password123")
+		database.DB.Model(user).Update("role", role)
+		token, _ := auth.GenerateToken(user.ID, user.Username, user.Email)
+		return user, map[string]string{"Authorization": "Bearer " + token}
+	}
+	admin, adminAuth := tokenFor("admin", rbac.RoleSiteAdmin)
+	alice, aliceAuth := tokenFor("alice", rbac.RoleUser)
+	_, modAuth := tokenFor("mod", rbac.RoleModerator)
+	_, guestAuth := tokenFor("guest", rbac.RoleGuest)
+
+	secret := models.Room{Name: "secret", IsPrivate: true, CreatorID: admin.ID}
+	database.DB.Create(&secret)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/", middleware.AuthMiddleware())
+	api.POST("/rooms", middleware.RequirePermission(rbac.PermRoomsCreate), handlers.CreateRoom)
+	api.GET("/rooms/:id", middleware.RequirePermission(rbac.PermRoomsView), handlers.GetRoom)
+	api.PUT("/admin/users/:id/role", middleware.RequirePermission(rbac.PermUsersManage), handlers.UpdateUserRole)
+
+	if w := performJSON(router, http.MethodPost, "/rooms", `{"name":"guest room"}`, guestAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected guest to be denied creating rooms, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, "/rooms", `{"name":"alice room"}`, aliceAuth); w.Code != http.StatusCreated {
+		t.Errorf("Expected user to create rooms, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 私有房间：普通用户不可见，版主可见
+	roomPath := fmt.Sprintf("/rooms/%d", secret.ID)
+	if w := performJSON(router, http.MethodGet, roomPath, "", aliceAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected user to be denied private room, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodGet, roomPath, "", modAuth); w.Code != http.StatusOK {
+		t.Errorf("Expected moderator to view private room, got %d", w.Code)
+	}
+
+	// 修改角色
+	rolePath := fmt.Sprintf("/admin/users/%d/role", alice.ID)
+	if w := performJSON(router, http.MethodPut, rolePath, `{"role":"site_admin"}`, modAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected moderator to be denied managing roles, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPut, rolePath, `{"role":"superuser"}`, adminAuth); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid role to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPut, fmt.Sprintf("/admin/users/%d/role", admin.ID), `{"role":"user"}`, adminAuth); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected changing own role to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPut, rolePath, `{"role":"moderator"}`, adminAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 角色变更立即生效
+	if w := performJSON(router, http.MethodGet, roomPath, "", aliceAuth); w.Code != http.StatusOK {
+		t.Errorf("Expected promoted user to view private room, got %d", w.Code)
+	}
+}
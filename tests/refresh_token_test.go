@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/models"
+	"testing"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	setupTestDB(t)
+
+	token, err := auth.IssueRefreshToken(1, "")
+	if err != nil {
+		t.Fatalf("Failed to issue refresh token: %v", err)
+	}
+
+	// 第一次使用应该成功并返回新令牌
+	current, rotated, err := auth.RotateRefreshToken(token)
+	if err != nil {
+		t.Fatalf("Failed to rotate refresh token: %v", err)
+	}
+
+	if current.UserID != 1 {
+		t.Errorf("Expected UserID 1, got %d", current.UserID)
+	}
+
+	if rotated == "" || rotated == token {
+		t.Fatal("Rotated refresh token should be a new value")
+	}
+
+	// 新令牌可以继续轮换
+	_, next, err := auth.RotateRefreshToken(rotated)
+	if err != nil {
+		t.Fatalf("Failed to rotate refresh token again: %v", err)
+	}
+
+	// 数据库中不应该保存明文令牌
+	var count int64
+	database.DB.Model(&models.RefreshToken{}).Where("token_hash = ?", next).Count(&count)
+	if count != 0 {
+		t.Error("Refresh token should not be stored in plain text")
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	setupTestDB(t)
+
+	token, err := auth.IssueRefreshToken(1, "")
+	if err != nil {
+		t.Fatalf("Failed to issue refresh token: %v", err)
+	}
+
+	_, rotated, err := auth.RotateRefreshToken(token)
+	if err != nil {
+		t.Fatalf("Failed to rotate refresh token: %v", err)
+	}
+
+	// 重放旧令牌应该失败
+	if _, _, err := auth.RotateRefreshToken(token); err != auth.ErrRefreshTokenReused {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	// 整个家族都应该被吊销，包括最新签发的令牌
+	if _, _, err := auth.RotateRefreshToken(rotated); err != auth.ErrRefreshTokenReused {
+		t.Errorf("Expected rotated token to be revoked, got %v", err)
+	}
+}
+
+func TestInvalidRefreshToken(t *testing.T) {
+	setupTestDB(t)
+
+	if _, _, err := auth.RotateRefreshToken("unknown-token"); err != auth.ErrInvalidRefreshToken {
+		t.Errorf("Expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRoomInvites(t *testing.T) {
+	setupTestDB(t)
+
+	users := make(map[string]*models.User)
+	tokenFor := func(name string) map[string]string {
+		user := createTestUser(t, name, "password123")
+		users[name] = user
+		token, _ := auth.GenerateToken(user.ID, user.Username, user.Email)
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+	ownerAuth := tokenFor("olivia")
+	peggyAuth := tokenFor("peggy")
+	quentinAuth := tokenFor("quentin")
+	ruthAuth := tokenFor("ruth")
+
+	room := models.Room{Name: "secret garden", IsPrivate: true, MaxMembers: 10, CreatorID: users["olivia"].ID}
+	room.SetPassword("hunter22")
+	database.DB.Create(&room)
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: users["olivia"].ID, Role: "admin", JoinedAt: time.Now()})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/", middleware.AuthMiddleware())
+	api.POST("/rooms/:id/invites", handlers.CreateRoomInvite)
+	api.GET("/rooms/:id/invites", handlers.GetRoomInvites)
+	api.DELETE("/rooms/:id/invites/:invite_id", handlers.RevokeRoomInvite)
+	api.GET("/invites/:code", handlers.GetInvite)
+	api.POST("/invites/:code/accept", handlers.AcceptInvite)
+
+	invitesPath := fmt.Sprintf("/rooms/%d/invites", room.ID)
+	createInvite := func(body string) map[string]interface{} {
+		w := performJSON(router, http.MethodPost, invitesPath, body, ownerAuth)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Invite map[string]interface{} `json:"invite"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Invite
+	}
+
+	if w := performJSON(router, http.MethodPost, invitesPath, `{}`, peggyAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected non-admin to be denied creating invites, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, invitesPath, `{"role":"owner"}`, ownerAuth); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid role to be rejected, got %d", w.Code)
+	}
+
+	single := createInvite(`{"max_uses":1,"expires_in_hours":24}`)
+	code := single["code"].(string)
+
+	// 预览房间信息
+	w := performJSON(router, http.MethodGet, "/invites/"+code, "", peggyAuth)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var preview struct {
+		Room     map[string]interface{} `json:"room"`
+		IsMember bool                   `json:"is_member"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &preview)
+	if preview.Room["name"] != "secret garden" || preview.IsMember {
+		t.Errorf("Unexpected preview: %s", w.Body.String())
+	}
+
+	// 使用邀请码加入不需要房间密码
+	if w := performJSON(router, http.MethodPost, "/invites/"+code+"/accept", "", peggyAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !room.IsMember(database.DB, users["peggy"].ID) {
+		t.Fatal("Expected peggy to be a member")
+	}
+
+	// 达到使用次数上限
+	if w := performJSON(router, http.MethodPost, "/invites/"+code+"/accept", "", quentinAuth); w.Code != http.StatusGone {
+		t.Errorf("Expected 410 for used up invite, got %d", w.Code)
+	}
+
+	// 已经是成员
+	multi := createInvite(`{"role":"admin"}`)
+	multiCode := multi["code"].(string)
+	if w := performJSON(router, http.MethodPost, "/invites/"+multiCode+"/accept", "", peggyAuth); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for existing member, got %d", w.Code)
+	}
+
+	// 邀请码指定加入后的角色
+	if w := performJSON(router, http.MethodPost, "/invites/"+multiCode+"/accept", "", quentinAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var member models.RoomMember
+	database.DB.Where("room_id = ? AND user_id = ?", room.ID, users["quentin"].ID).First(&member)
+	if member.Role != "admin" {
+		t.Errorf("Expected role from invite, got %q", member.Role)
+	}
+
+	// 吊销后不能再使用
+	revokePath := fmt.Sprintf("%s/%d", invitesPath, uint(multi["id"].(float64)))
+	if w := performJSON(router, http.MethodDelete, revokePath, "", ownerAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodGet, "/invites/"+multiCode, "", ruthAuth); w.Code != http.StatusGone {
+		t.Errorf("Expected 410 for revoked invite, got %d", w.Code)
+	}
+
+	// 过期
+	expired := createInvite(`{}`)
+	database.DB.Model(&models.RoomInvite{}).Where("id = ?", uint(expired["id"].(float64))).Update("expires_at", time.Now().Add(-time.Minute))
+	if w := performJSON(router, http.MethodPost, "/invites/"+expired["code"].(string)+"/accept", "", ruthAuth); w.Code != http.StatusGone {
+		t.Errorf("Expected 410 for expired invite, got %d", w.Code)
+	}
+
+	if w := performJSON(router, http.MethodGet, "/invites/unknown", "", ruthAuth); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown invite, got %d", w.Code)
+	}
+
+	w = performJSON(router, http.MethodGet, invitesPath, "", ownerAuth)
+	var list struct {
+		Invites []map[string]interface{} `json:"invites"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Invites) != 3 {
+		t.Errorf("Expected 3 invites, got %d", len(list.Invites))
+	}
+}
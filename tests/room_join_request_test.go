@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestRoomJoinRequests(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	users := make(map[string]*models.User)
+	tokens := make(map[string]string)
+	for _, name := range []string{"quinn", "rosa", "sam", "tess"} {
+		users[name] = createTestUser(t, name, "password123")
+		tokens[name], _ = auth.GenerateToken(users[name].ID, users[name].Username, users[name].Email)
+	}
+	authFor := func(name string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + tokens[name]}
+	}
+
+	room := models.Room{Name: "vault", IsPrivate: true, RequireJoinApproval: true, MaxMembers: 10, CreatorID: users["quinn"].ID}
+	database.DB.Create(&room)
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: users["quinn"].ID, Role: "admin", JoinedAt: time.Now()})
+	open := models.Room{Name: "lobby", MaxMembers: 10, CreatorID: users["quinn"].ID}
+	database.DB.Create(&open)
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", handlers.HandleWebSocket(hub))
+	api := router.Group("/", middleware.AuthMiddleware())
+	api.POST("/rooms/:id/join", handlers.JoinRoom)
+	api.POST("/rooms/:id/join-requests", handlers.CreateJoinRequest(hub))
+	api.GET("/rooms/:id/join-requests", handlers.GetJoinRequests)
+	api.POST("/rooms/:id/join-requests/:request_id/approve", handlers.ApproveJoinRequest(hub))
+	api.POST("/rooms/:id/join-requests/:request_id/deny", handlers.DenyJoinRequest(hub))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	dial := func(name string, roomID uint) *websocket.Conn {
+		conn, _, err := dialWebSocket(server, fmt.Sprintf("?room_id=%d", roomID), tokens[name])
+		if err != nil {
+			t.Fatalf("Failed to connect websocket: %v", err)
+		}
+		return conn
+	}
+	// expect 读取消息直到收到指定类型
+	expect := func(conn *websocket.Conn, msgType string) map[string]interface{} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for {
+			var msg map[string]interface{}
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("Expected %s message: %v", msgType, err)
+			}
+			if msg["type"] == msgType {
+				return msg
+			}
+		}
+	}
+
+	requestsPath := fmt.Sprintf("/rooms/%d/join-requests", room.ID)
+	submit := func(name string) (int, uint) {
+		w := performJSON(router, http.MethodPost, requestsPath, `{"message":"let me in"}`, authFor(name))
+		var resp struct {
+			Request struct {
+				ID uint `json:"id"`
+			} `json:"request"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Request.ID
+	}
+
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/join", room.ID), "", authFor("rosa")); w.Code != http.StatusForbidden {
+		t.Errorf("Expected direct join to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/join-requests", open.ID), "", authFor("rosa")); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected public room to reject join requests, got %d", w.Code)
+	}
+
+	// 管理员在其他房间的连接也能收到申请通知
+	adminConn := dial("quinn", open.ID)
+	defer adminConn.Close()
+	for i := 0; i < 50 && len(hub.OnlineUserIDs(open.ID)) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	code, rosaRequest := submit("rosa")
+	if code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+	notice := expect(adminConn, "join_request")
+	if data := notice["data"].(map[string]interface{}); data["request"].(map[string]interface{})["message"] != "let me in" {
+		t.Errorf("Unexpected join request notification: %v", notice)
+	}
+	if code, _ := submit("rosa"); code != http.StatusConflict {
+		t.Errorf("Expected duplicate request to be rejected, got %d", code)
+	}
+
+	if w := performJSON(router, http.MethodGet, requestsPath, "", authFor("sam")); w.Code != http.StatusForbidden {
+		t.Errorf("Expected non-admin to be denied listing requests, got %d", w.Code)
+	}
+	w := performJSON(router, http.MethodGet, requestsPath, "", authFor("quinn"))
+	var list struct {
+		Requests []map[string]interface{} `json:"requests"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if w.Code != http.StatusOK || len(list.Requests) != 1 {
+		t.Fatalf("Expected one pending request, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 批准后申请人成为成员并收到通知
+	rosaConn := dial("rosa", open.ID)
+	defer rosaConn.Close()
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("%s/%d/approve", requestsPath, rosaRequest), "", authFor("quinn")); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	expect(rosaConn, "join_request_approved")
+	if !room.IsMember(database.DB, users["rosa"].ID) {
+		t.Error("Expected approved user to be a member")
+	}
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("%s/%d/deny", requestsPath, rosaRequest), "", authFor("quinn")); w.Code != http.StatusGone {
+		t.Errorf("Expected handled request to be rejected, got %d", w.Code)
+	}
+
+	// 拒绝后申请人收到通知，可以重新申请
+	samConn := dial("sam", open.ID)
+	defer samConn.Close()
+	_, samRequest := submit("sam")
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("%s/%d/deny", requestsPath, samRequest), "", authFor("quinn")); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	expect(samConn, "join_request_denied")
+	if room.IsMember(database.DB, users["sam"].ID) {
+		t.Error("Expected denied user not to be a member")
+	}
+	if code, _ := submit("sam"); code != http.StatusCreated {
+		t.Errorf("Expected denied user to request again, got %d", code)
+	}
+
+	// 过期的申请不能批准
+	_, tessRequest := submit("tess")
+	database.DB.Model(&models.RoomJoinRequest{}).Where("id = ?", tessRequest).Update("expires_at", time.Now().Add(-time.Minute))
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("%s/%d/approve", requestsPath, tessRequest), "", authFor("quinn")); w.Code != http.StatusGone {
+		t.Errorf("Expected expired request to be rejected, got %d", w.Code)
+	}
+	if room.IsMember(database.DB, users["tess"].ID) {
+		t.Error("Expected expired request not to add a member")
+	}
+}
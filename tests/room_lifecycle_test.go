@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestRoomUpdateDeleteRestore(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	users := make(map[string]*models.User)
+	tokens := make(map[string]string)
+	for _, name := range []string{"amelia", "basil", "cora"} {
+		users[name] = createTestUser(t, name, "password123")
+		tokens[name], _ = auth.GenerateToken(users[name].ID, users[name].Username, users[name].Email)
+	}
+	authFor := func(name string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + tokens[name]}
+	}
+
+	room := models.Room{Name: "garden", IsPrivate: true, MaxMembers: 10, CreatorID: users["amelia"].ID}
+	room.SetPassword("hunter22")
+	database.DB.Create(&room)
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: users["amelia"].ID, Role: "admin", JoinedAt: time.Now()})
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: users["basil"].ID, Role: "member", JoinedAt: time.Now()})
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: users["cora"].ID, Role: "admin", JoinedAt: time.Now()})
+	database.DB.Create(&models.Message{RoomID: room.ID, UserID: users["basil"].ID, Content: "hi"})
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", handlers.HandleWebSocket(hub))
+	api := router.Group("/", middleware.AuthMiddleware())
+	api.GET("/rooms/:id", handlers.GetRoom)
+	api.PUT("/rooms/:id", handlers.UpdateRoom(hub))
+	api.DELETE("/rooms/:id", handlers.DeleteRoom(hub))
+	api.POST("/rooms/:id/restore", handlers.RestoreRoom(hub))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	roomPath := fmt.Sprintf("/rooms/%d", room.ID)
+	conn, _, err := dialWebSocket(server, fmt.Sprintf("?room_id=%d", room.ID), tokens["basil"])
+	if err != nil {
+		t.Fatalf("Failed to connect websocket: %v", err)
+	}
+	defer conn.Close()
+
+	// readEvent 读取下一条指定类型的推送，跳过在线用户等其他消息
+	readEvent := func(eventType string) map[string]interface{} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("Expected %s event, got error: %v", eventType, err)
+			}
+			for _, line := range strings.Split(string(data), "\n") {
+				var event struct {
+					Type string                 `json:"type"`
+					Data map[string]interface{} `json:"data"`
+				}
+				json.Unmarshal([]byte(line), &event)
+				if event.Type == eventType {
+					return event.Data
+				}
+			}
+		}
+	}
+
+	// 修改房间
+	if w := performJSON(router, http.MethodPut, roomPath, `{"name":"orchard"}`, authFor("basil")); w.Code != http.StatusForbidden {
+		t.Errorf("Expected member to be denied editing the room, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPut, roomPath, `{"max_members":2}`, authFor("cora")); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected max members below member count to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPut, roomPath, `{"name":"   "}`, authFor("cora")); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected blank name to be rejected, got %d", w.Code)
+	}
+	w := performJSON(router, http.MethodPut, roomPath, `{"name":" orchard ","description":"apples","is_private":false,"max_members":5}`, authFor("cora"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	database.DB.First(&room, room.ID)
+	if room.Name != "orchard" || room.Description != "apples" || room.IsPrivate || room.MaxMembers != 5 || room.HasPassword() {
+		t.Errorf("Unexpected room after update: %+v", room)
+	}
+	updated := readEvent("room_updated")
+	if updatedRoom, _ := updated["room"].(map[string]interface{}); updatedRoom["name"] != "orchard" {
+		t.Errorf("Expected room_updated event with the new name, got %v", updated)
+	}
+
+	// 删除房间，房间内的连接收到通知后正常关闭
+	if w := performJSON(router, http.MethodDelete, roomPath, "", authFor("cora")); w.Code != http.StatusForbidden {
+		t.Errorf("Expected room admin to be denied deleting the room, got %d", w.Code)
+	}
+	w = performJSON(router, http.MethodDelete, roomPath, "", authFor("amelia"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var deleted struct {
+		RestorableUntil *time.Time `json:"restorable_until"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &deleted)
+	if deleted.RestorableUntil == nil || deleted.RestorableUntil.Before(time.Now().AddDate(0, 0, 29)) {
+		t.Errorf("Expected restore deadline 30 days from now, got %v", deleted.RestorableUntil)
+	}
+
+	readEvent("room_deleted")
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if !websocket.IsCloseError(err, websocket.CloseNoStatusReceived, websocket.CloseNormalClosure) {
+			t.Errorf("Expected websocket to be closed with a close frame, got %v", err)
+		}
+		break
+	}
+
+	if w := performJSON(router, http.MethodGet, roomPath, "", authFor("amelia")); w.Code != http.StatusNotFound {
+		t.Errorf("Expected deleted room to be hidden, got %d", w.Code)
+	}
+
+	// 恢复房间，成员和消息随房间恢复
+	if w := performJSON(router, http.MethodPost, roomPath+"/restore", "", authFor("basil")); w.Code != http.StatusForbidden {
+		t.Errorf("Expected member to be denied restoring the room, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, roomPath+"/restore", "", authFor("amelia")); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := performJSON(router, http.MethodPost, roomPath+"/restore", "", authFor("amelia")); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a room that is not deleted, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodGet, roomPath, "", authFor("basil")); w.Code != http.StatusOK {
+		t.Errorf("Expected restored room to be visible, got %d", w.Code)
+	}
+	if !room.IsMember(database.DB, users["basil"].ID) {
+		t.Error("Expected members to be restored with the room")
+	}
+
+	// 超过保留期不能恢复，并被彻底删除
+	if w := performJSON(router, http.MethodDelete, roomPath, "", authFor("amelia")); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	database.DB.Unscoped().Model(&models.Room{}).Where("id = ?", room.ID).Update("deleted_at", time.Now().AddDate(0, 0, -31))
+	if w := performJSON(router, http.MethodPost, roomPath+"/restore", "", authFor("amelia")); w.Code != http.StatusGone {
+		t.Errorf("Expected 410 after the restore window, got %d", w.Code)
+	}
+	if purged := services.PurgeDeletedRooms(); purged != 1 {
+		t.Fatalf("Expected 1 purged room, got %d", purged)
+	}
+	var count int64
+	database.DB.Unscoped().Model(&models.Message{}).Where("room_id = ?", room.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected messages of purged room to be removed, got %d", count)
+	}
+	database.DB.Unscoped().Model(&models.Room{}).Where("id = ?", room.ID).Count(&count)
+	if count != 0 {
+		t.Error("Expected purged room to be removed")
+	}
+}
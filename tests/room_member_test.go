@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetRoomMembers(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	users := make(map[string]*models.User)
+	tokens := make(map[string]string)
+	for _, name := range []string{"lena", "mark", "nina", "otto", "pia"} {
+		users[name] = createTestUser(t, name, "password123")
+		database.DB.Model(users[name]).Update("nickname", strings.ToUpper(name[:1])+name[1:])
+		tokens[name], _ = auth.GenerateToken(users[name].ID, users[name].Username, users[name].Email)
+	}
+
+	room := models.Room{Name: "club", IsPrivate: true, MaxMembers: 10, CreatorID: users["lena"].ID}
+	database.DB.Create(&room)
+	joined := time.Now().Add(-time.Hour)
+	for i, name := range []string{"lena", "mark", "nina", "otto"} {
+		role := "member"
+		if name == "lena" || name == "otto" {
+			role = "admin"
+		}
+		database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: users[name].ID, Role: role, JoinedAt: joined.Add(time.Duration(i) * time.Minute)})
+	}
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", handlers.HandleWebSocket(hub))
+	router.GET("/rooms/:id/members", middleware.AuthMiddleware(), handlers.GetRoomMembers(hub))
+
+	// mark 连接到房间
+	server := httptest.NewServer(router)
+	defer server.Close()
+	conn, _, err := dialWebSocket(server, fmt.Sprintf("?room_id=%d", room.ID), tokens["mark"])
+	if err != nil {
+		t.Fatalf("Failed to connect websocket: %v", err)
+	}
+	defer conn.Close()
+	for i := 0; i < 50 && len(hub.OnlineUserIDs(room.ID)) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	type memberList struct {
+		Members []struct {
+			User      map[string]interface{} `json:"user"`
+			Role      string                 `json:"role"`
+			IsCreator bool                   `json:"is_creator"`
+			Online    bool                   `json:"online"`
+		} `json:"members"`
+		OnlineCount int `json:"online_count"`
+		Pagination  struct {
+			Total int64 `json:"total"`
+		} `json:"pagination"`
+	}
+	list := func(query string) memberList {
+		w := performJSON(router, http.MethodGet, fmt.Sprintf("/rooms/%d/members%s", room.ID, query), "", map[string]string{"Authorization": "Bearer " + tokens["lena"]})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %q, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var resp memberList
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+	usernames := func(resp memberList) []string {
+		var names []string
+		for _, member := range resp.Members {
+			names = append(names, member.User["username"].(string))
+		}
+		return names
+	}
+
+	// 管理员在前，其余按加入时间排序
+	all := list("")
+	if got := strings.Join(usernames(all), ","); got != "lena,otto,mark,nina" {
+		t.Errorf("Unexpected member order: %s", got)
+	}
+	if all.Pagination.Total != 4 || all.OnlineCount != 1 || !all.Members[0].IsCreator {
+		t.Errorf("Unexpected member list: %+v", all)
+	}
+	for _, member := range all.Members {
+		if member.Online != (member.User["username"] == "mark") {
+			t.Errorf("Unexpected online state for %v: %v", member.User["username"], member.Online)
+		}
+		if _, ok := member.User["email"]; ok {
+			t.Errorf("Expected member list not to expose email, got %v", member.User)
+		}
+	}
+
+	if got := strings.Join(usernames(list("?role=admin")), ","); got != "lena,otto" {
+		t.Errorf("Unexpected admins: %s", got)
+	}
+	if got := strings.Join(usernames(list("?online=true")), ","); got != "mark" {
+		t.Errorf("Unexpected online members: %s", got)
+	}
+	if got := strings.Join(usernames(list("?online=false&role=member")), ","); got != "nina" {
+		t.Errorf("Unexpected offline members: %s", got)
+	}
+	if got := strings.Join(usernames(list("?search=NI")), ","); got != "nina" {
+		t.Errorf("Unexpected search result: %s", got)
+	}
+	paged := list("?page=2&page_size=3")
+	if got := strings.Join(usernames(paged), ","); got != "nina" || paged.Pagination.Total != 4 {
+		t.Errorf("Unexpected second page: %s", got)
+	}
+
+	if w := performJSON(router, http.MethodGet, fmt.Sprintf("/rooms/%d/members?role=owner", room.ID), "", map[string]string{"Authorization": "Bearer " + tokens["lena"]}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid role filter to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodGet, fmt.Sprintf("/rooms/%d/members", room.ID), "", map[string]string{"Authorization": "Bearer " + tokens["pia"]}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected non-member to be denied listing a private room, got %d", w.Code)
+	}
+}
+
+func TestWebSocketJoinRoomMembership(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	owner := createTestUser(t, "quincy", "password123")
+	moderator := createTestUser(t, "rhea", "password123")
+	database.DB.Model(moderator).Update("role", rbac.RoleModerator)
+	token, _ := auth.GenerateToken(moderator.ID, moderator.Username, moderator.Email)
+
+	lobby := models.Room{Name: "lobby", MaxMembers: 10, CreatorID: owner.ID}
+	vault := models.Room{Name: "vault", IsPrivate: true, RequireJoinApproval: true, MaxMembers: 10, CreatorID: owner.ID}
+	tiny := models.Room{Name: "tiny", MaxMembers: 1, CreatorID: owner.ID}
+	for _, room := range []*models.Room{&lobby, &vault, &tiny} {
+		database.DB.Create(room)
+		database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: owner.ID, Role: "admin", JoinedAt: time.Now()})
+	}
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", handlers.HandleWebSocket(hub))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	conn, _, err := dialWebSocket(server, fmt.Sprintf("?room_id=%d", lobby.ID), token)
+	if err != nil {
+		t.Fatalf("Failed to connect websocket: %v", err)
+	}
+	defer conn.Close()
+
+	// 消息按顺序处理，加入公开房间完成时之前的消息都已处理
+	for _, room := range []models.Room{vault, tiny, lobby} {
+		conn.WriteJSON(map[string]interface{}{"type": "join_room", "room_id": room.ID})
+	}
+	for i := 0; i < 100 && !lobby.IsMember(database.DB, moderator.ID); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if !lobby.IsMember(database.DB, moderator.ID) {
+		t.Error("Expected joining a public room to add a member")
+	}
+	if vault.IsMember(database.DB, moderator.ID) {
+		t.Error("Expected viewing a private room not to bypass approval")
+	}
+	if tiny.IsMember(database.DB, moderator.ID) {
+		t.Error("Expected a full room not to accept new members")
+	}
+}
@@ -0,0 +1,328 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRoomModeration(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	users := make(map[string]*models.User)
+	tokens := make(map[string]string)
+	authFor := func(name string) map[string]string {
+		if _, ok := users[name]; !ok {
+			user := createTestUser(t, name, "password123")
+			users[name] = user
+			tokens[name], _ = auth.GenerateToken(user.ID, user.Username, user.Email)
+		}
+		return map[string]string{"Authorization": "Bearer " + tokens[name]}
+	}
+	ownerAuth := authFor("sybil")
+	adminAuth := authFor("trent")
+	victorAuth := authFor("victor")
+	wendyAuth := authFor("wendy")
+	authFor("xavier")
+
+	room := models.Room{Name: "town square", MaxMembers: 10, CreatorID: users["sybil"].ID}
+	database.DB.Create(&room)
+	for name, role := range map[string]string{"sybil": "admin", "trent": "admin", "victor": "member", "wendy": "member", "xavier": "admin"} {
+		database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: users[name].ID, Role: role, JoinedAt: time.Now()})
+	}
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/", middleware.AuthMiddleware())
+	api.POST("/rooms/:id/join", handlers.JoinRoom)
+	api.POST("/rooms/:id/messages", handlers.SendMessage(hub))
+	api.DELETE("/rooms/:id/members/:user_id", handlers.KickMember(hub))
+	api.POST("/rooms/:id/members/:user_id/ban", handlers.BanMember(hub))
+	api.DELETE("/rooms/:id/members/:user_id/ban", handlers.UnbanMember)
+	api.POST("/rooms/:id/members/:user_id/mute", handlers.MuteMember)
+	api.DELETE("/rooms/:id/members/:user_id/mute", handlers.UnmuteMember)
+	api.PUT("/rooms/:id/members/:user_id/role", handlers.UpdateMemberRole)
+	api.GET("/rooms/:id/bans", handlers.GetRoomBans)
+
+	memberPath := func(name string) string {
+		return fmt.Sprintf("/rooms/%d/members/%d", room.ID, users[name].ID)
+	}
+	roomPath := fmt.Sprintf("/rooms/%d", room.ID)
+
+	// 权限检查
+	if w := performJSON(router, http.MethodDelete, memberPath("wendy"), "", victorAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected member to be denied kicking, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodDelete, memberPath("trent"), "", adminAuth); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected moderating yourself to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, memberPath("sybil")+"/ban", `{}`, adminAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected banning the creator to be denied, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, memberPath("xavier")+"/mute", `{"duration_minutes":5}`, adminAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected room admin to be denied muting another admin, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/members/9999/ban", room.ID), `{}`, ownerAuth); w.Code != http.StatusNotFound {
+		t.Errorf("Expected banning an unknown user to return 404, got %d", w.Code)
+	}
+
+	// 禁言期间不能发言，解除后恢复
+	if w := performJSON(router, http.MethodPost, memberPath("victor")+"/mute", `{"duration_minutes":0}`, adminAuth); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected zero mute duration to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, memberPath("victor")+"/mute", `{"duration_minutes":10}`, adminAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := performJSON(router, http.MethodPost, roomPath+"/messages", `{"content":"hello?"}`, victorAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected muted member to be denied sending, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodDelete, memberPath("victor")+"/mute", "", adminAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, roomPath+"/messages", `{"content":"hello!"}`, victorAuth); w.Code != http.StatusCreated {
+		t.Errorf("Expected unmuted member to send, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 移出后可以重新加入
+	if w := performJSON(router, http.MethodDelete, memberPath("wendy"), "", adminAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if room.IsMember(database.DB, users["wendy"].ID) {
+		t.Fatal("Expected kicked user to be removed from the room")
+	}
+	if w := performJSON(router, http.MethodPost, roomPath+"/join", "", wendyAuth); w.Code != http.StatusOK {
+		t.Errorf("Expected kicked user to rejoin, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 封禁时长有上限，过大的值会在计算到期时间时溢出
+	if w := performJSON(router, http.MethodPost, memberPath("wendy")+"/ban", `{"expires_in_hours":9223372036}`, adminAuth); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an overlong ban, got %d", w.Code)
+	}
+	if room.IsBanned(database.DB, users["wendy"].ID) {
+		t.Fatal("Expected rejected ban not to be stored")
+	}
+
+	// 封禁后不能加入，也不能发言
+	w := performJSON(router, http.MethodPost, memberPath("wendy")+"/ban", `{"reason":"spam","expires_in_hours":24}`, adminAuth)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if room.IsMember(database.DB, users["wendy"].ID) {
+		t.Error("Expected banned user to be removed from the room")
+	}
+	if w := performJSON(router, http.MethodPost, roomPath+"/join", "", wendyAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected banned user to be denied joining, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, roomPath+"/messages", `{"content":"let me in"}`, wendyAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected banned user to be denied sending, got %d", w.Code)
+	}
+
+	w = performJSON(router, http.MethodGet, roomPath+"/bans", "", adminAuth)
+	var bans struct {
+		Bans []map[string]interface{} `json:"bans"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &bans)
+	if len(bans.Bans) != 1 || bans.Bans[0]["reason"] != "spam" || bans.Bans[0]["expires_at"] == nil {
+		t.Errorf("Unexpected bans: %s", w.Body.String())
+	}
+
+	// 过期的封禁不再生效
+	database.DB.Model(&models.RoomBan{}).Where("user_id = ?", users["wendy"].ID).Update("expires_at", time.Now().Add(-time.Minute))
+	if w := performJSON(router, http.MethodPost, roomPath+"/join", "", wendyAuth); w.Code != http.StatusOK {
+		t.Errorf("Expected expired ban to be ignored, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 重复封禁更新原有记录，解除后可以重新加入
+	if w := performJSON(router, http.MethodPost, memberPath("wendy")+"/ban", `{}`, ownerAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var ban models.RoomBan
+	database.DB.Where("room_id = ? AND user_id = ?", room.ID, users["wendy"].ID).First(&ban)
+	if ban.ExpiresAt != nil || ban.BannedByID != users["sybil"].ID {
+		t.Errorf("Expected ban to become permanent, got %+v", ban)
+	}
+	if w := performJSON(router, http.MethodDelete, memberPath("wendy")+"/ban", "", adminAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodDelete, memberPath("wendy")+"/ban", "", adminAuth); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for user who is not banned, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, roomPath+"/join", "", wendyAuth); w.Code != http.StatusOK {
+		t.Errorf("Expected unbanned user to rejoin, got %d", w.Code)
+	}
+
+	// 角色调整：管理员可以提升成员，只有创建者可以取消其他管理员
+	if w := performJSON(router, http.MethodPut, memberPath("victor")+"/role", `{"role":"owner"}`, adminAuth); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid role to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPut, memberPath("victor")+"/role", `{"role":"admin"}`, adminAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !room.IsAdmin(database.DB, users["victor"].ID) {
+		t.Error("Expected member to be promoted to admin")
+	}
+	if w := performJSON(router, http.MethodPut, memberPath("victor")+"/role", `{"role":"member"}`, adminAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected room admin to be denied demoting another admin, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPut, memberPath("victor")+"/role", `{"role":"member"}`, ownerAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if room.IsAdmin(database.DB, users["victor"].ID) {
+		t.Error("Expected admin to be demoted")
+	}
+}
+
+func TestBanDisconnectsWebSocket(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	owner := createTestUser(t, "yvonne", "password123")
+	member := createTestUser(t, "zack", "password123")
+
+	room := models.Room{Name: "war room", MaxMembers: 10, CreatorID: owner.ID}
+	database.DB.Create(&room)
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: owner.ID, Role: "admin", JoinedAt: time.Now()})
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: member.ID, Role: "member", JoinedAt: time.Now()})
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", handlers.HandleWebSocket(hub))
+	api := router.Group("/", middleware.AuthMiddleware())
+	api.POST("/rooms/:id/members/:user_id/ban", handlers.BanMember(hub))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	ownerToken, _ := auth.GenerateToken(owner.ID, owner.Username, owner.Email)
+	memberToken, _ := auth.GenerateToken(member.ID, member.Username, member.Email)
+	query := fmt.Sprintf("?room_id=%d", room.ID)
+
+	conn, _, err := dialWebSocket(server, query, memberToken)
+	if err != nil {
+		t.Fatalf("Failed to connect websocket: %v", err)
+	}
+	defer conn.Close()
+
+	w := performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/members/%d/ban", room.ID, member.ID), `{}`, map[string]string{"Authorization": "Bearer " + ownerToken})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 被封禁用户的连接被断开
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+				t.Fatal("Expected websocket of banned user to be closed")
+			}
+			break
+		}
+	}
+
+	// 被封禁后不能重新连接房间
+	_, resp, err := dialWebSocket(server, query, memberToken)
+	if err == nil {
+		t.Fatal("Expected banned user to be denied connecting")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 handshake response, got %v", resp)
+	}
+}
+
+func TestJoinRoomThenModerationDisconnects(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	owner := createTestUser(t, "ariel", "password123")
+	member := createTestUser(t, "boris", "password123")
+
+	lobby := models.Room{Name: "lobby", MaxMembers: 10, CreatorID: owner.ID}
+	database.DB.Create(&lobby)
+	database.DB.Create(&models.RoomMember{RoomID: lobby.ID, UserID: member.ID, Role: "member", JoinedAt: time.Now()})
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", handlers.HandleWebSocket(hub))
+	api := router.Group("/", middleware.AuthMiddleware())
+	api.DELETE("/rooms/:id", handlers.DeleteRoom(hub))
+	api.DELETE("/rooms/:id/members/:user_id", handlers.KickMember(hub))
+	api.POST("/rooms/:id/members/:user_id/ban", handlers.BanMember(hub))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	ownerToken, _ := auth.GenerateToken(owner.ID, owner.Username, owner.Email)
+	memberToken, _ := auth.GenerateToken(member.ID, member.Username, member.Email)
+	ownerAuth := map[string]string{"Authorization": "Bearer " + ownerToken}
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"kick", http.MethodDelete, "/rooms/%d/members/" + fmt.Sprint(member.ID), ""},
+		{"ban", http.MethodPost, "/rooms/%d/members/" + fmt.Sprint(member.ID) + "/ban", `{}`},
+		{"delete", http.MethodDelete, "/rooms/%d", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			room := models.Room{Name: tc.name, MaxMembers: 10, CreatorID: owner.ID}
+			database.DB.Create(&room)
+			database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: owner.ID, Role: "admin", JoinedAt: time.Now()})
+
+			conn, _, err := dialWebSocket(server, fmt.Sprintf("?room_id=%d", lobby.ID), memberToken)
+			if err != nil {
+				t.Fatalf("Failed to connect websocket: %v", err)
+			}
+			defer conn.Close()
+
+			// 切换房间后接收新房间的广播
+			conn.WriteJSON(map[string]interface{}{"type": "join_room", "room_id": room.ID})
+			for i := 0; i < 100 && len(hub.OnlineUserIDs(room.ID)) == 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			hub.BroadcastMessage(room.ID, services.WebSocketMessage{Type: "notice", RoomID: room.ID})
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			for {
+				var msg services.WebSocketMessage
+				if err := conn.ReadJSON(&msg); err != nil {
+					t.Fatalf("Expected broadcast to the joined room: %v", err)
+				}
+				if msg.Type == "notice" {
+					break
+				}
+			}
+
+			w := performJSON(router, tc.method, fmt.Sprintf(tc.path, room.ID), tc.body, ownerAuth)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			// 切换到的房间中的连接被断开
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+						t.Fatal("Expected websocket in the joined room to be closed")
+					}
+					break
+				}
+			}
+		})
+	}
+}
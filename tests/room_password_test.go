@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/passwordhash"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRoomPassword(t *testing.T) {
+	setupTestDB(t)
+
+	tokenFor := func(name string) map[string]string {
+		user := createTestUser(t, name, "password123")
+		token, _ := auth.GenerateToken(user.ID, user.Username, user.Email)
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+	ownerAuth := tokenFor("judy")
+	memberAuth := tokenFor("ken")
+	lateAuth := tokenFor("liam")
+	lastAuth := tokenFor("mallory")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/", middleware.AuthMiddleware())
+	api.POST("/rooms", handlers.CreateRoom)
+	api.POST("/rooms/:id/join", handlers.JoinRoom)
+	api.PUT("/rooms/:id/password", handlers.UpdateRoomPassword)
+	api.DELETE("/rooms/:id/password", handlers.RemoveRoomPassword)
+
+	w := performJSON(router, http.MethodPost, "/rooms", `{"name":"vault","is_private":true,"password":"open sesame"}`, ownerAuth)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Room map[string]interface{} `json:"room"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Room["has_password"] != true {
+		t.Error("Expected has_password to be true")
+	}
+	roomID := uint(created.Room["id"].(float64))
+
+	// 数据库中只保存哈希
+	var room models.Room
+	database.DB.First(&room, roomID)
+	if room.Password == "open sesame" || !passwordhash.IsHash(room.Password) {
+		t.Fatalf("Expected room password to be hashed, got %q", room.Password)
+	}
+
+	joinPath := fmt.Sprintf("/rooms/%d/join", roomID)
+	if w := performJSON(router, http.MethodPost, joinPath, `{"password":"wrong"}`, memberAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for wrong room password, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, joinPath, `{"password":"open sesame"}`, memberAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for correct room password, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 只有房间管理员可以修改密码
+	passwordPath := fmt.Sprintf("/rooms/%d/password", roomID)
+	if w := performJSON(router, http.MethodPut, passwordPath, `{"password":"new secret"}`, memberAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non-admin, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPut, passwordPath, `{"password":"new secret"}`, ownerAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := performJSON(router, http.MethodPost, joinPath, `{"password":"open sesame"}`, lateAuth); w.Code != http.StatusForbidden {
+		t.Errorf("Expected old password to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, joinPath, `{"password":"new secret"}`, lateAuth); w.Code != http.StatusOK {
+		t.Errorf("Expected new password to be accepted, got %d", w.Code)
+	}
+
+	// 取消密码后不需要密码即可加入
+	if w := performJSON(router, http.MethodDelete, passwordPath, "", ownerAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, joinPath, `{}`, lastAuth); w.Code != http.StatusOK {
+		t.Errorf("Expected to join without password, got %d", w.Code)
+	}
+
+	// 公开房间不能设置密码
+	w = performJSON(router, http.MethodPost, "/rooms", `{"name":"plaza"}`, ownerAuth)
+	json.Unmarshal(w.Body.Bytes(), &created)
+	publicPath := fmt.Sprintf("/rooms/%d/password", uint(created.Room["id"].(float64)))
+	if w := performJSON(router, http.MethodPut, publicPath, `{"password":"secret"}`, ownerAuth); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for public room, got %d", w.Code)
+	}
+}
+
+func TestMigrateRoomPasswords(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "nina", "password123")
+
+	legacy := models.Room{Name: "legacy", IsPrivate: true, CreatorID: owner.ID, Password: "plaintext"}
+	database.DB.Create(&legacy)
+
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+
+	var room models.Room
+	database.DB.First(&room, legacy.ID)
+	if !passwordhash.IsHash(room.Password) || !room.CheckPassword("plaintext") {
+		t.Errorf("Expected legacy room password to be hashed, got %q", room.Password)
+	}
+
+	// 再次迁移不会重复哈希
+	hashed := room.Password
+	database.AutoMigrate()
+	database.DB.First(&room, legacy.ID)
+	if room.Password != hashed {
+		t.Error("Expected already hashed password to be left unchanged")
+	}
+}
@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/rbac"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRoomOwnershipTransfer(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	users := make(map[string]*models.User)
+	tokens := make(map[string]string)
+	for _, name := range []string{"dana", "eli", "fay", "gus", "hal"} {
+		users[name] = createTestUser(t, name, "password123")
+		tokens[name], _ = auth.GenerateToken(users[name].ID, users[name].Username, users[name].Email)
+	}
+	database.DB.Model(users["gus"]).Update("role", string(rbac.RoleSiteAdmin))
+	authFor := func(name string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + tokens[name]}
+	}
+
+	room := models.Room{Name: "team", MaxMembers: 10, CreatorID: users["dana"].ID}
+	database.DB.Create(&room)
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: users["dana"].ID, Role: "admin", JoinedAt: time.Now()})
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: users["eli"].ID, Role: "member", JoinedAt: time.Now()})
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: users["fay"].ID, Role: "member", JoinedAt: time.Now()})
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/", middleware.AuthMiddleware())
+	api.POST("/rooms/:id/leave", handlers.LeaveRoom)
+	api.POST("/rooms/:id/transfer", handlers.TransferRoom)
+	api.GET("/rooms/:id/transfer", handlers.GetRoomTransfer)
+	api.DELETE("/rooms/:id/transfer", handlers.CancelRoomTransfer)
+	api.POST("/rooms/:id/transfer/accept", handlers.AcceptRoomTransfer(hub))
+	api.PUT("/admin/rooms/:id/owner", middleware.RequirePermission(rbac.PermRoomsManage), handlers.AssignRoomOwner(hub))
+
+	transferPath := fmt.Sprintf("/rooms/%d/transfer", room.ID)
+	nominate := func(from, to string) int {
+		return performJSON(router, http.MethodPost, transferPath, fmt.Sprintf(`{"user_id":%d}`, users[to].ID), authFor(from)).Code
+	}
+
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/leave", room.ID), "", authFor("dana")); w.Code != http.StatusForbidden {
+		t.Errorf("Expected creator to be denied leaving, got %d", w.Code)
+	}
+
+	// 发起转让
+	if code := nominate("eli", "fay"); code != http.StatusForbidden {
+		t.Errorf("Expected non-creator to be denied transferring, got %d", code)
+	}
+	if code := nominate("dana", "dana"); code != http.StatusBadRequest {
+		t.Errorf("Expected transfer to yourself to be rejected, got %d", code)
+	}
+	if code := nominate("dana", "hal"); code != http.StatusBadRequest {
+		t.Errorf("Expected transfer to a non-member to be rejected, got %d", code)
+	}
+	if code := nominate("dana", "eli"); code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+	if w := performJSON(router, http.MethodGet, transferPath, "", authFor("fay")); w.Code != http.StatusForbidden {
+		t.Errorf("Expected other members to be denied viewing the transfer, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodGet, transferPath, "", authFor("eli")); w.Code != http.StatusOK {
+		t.Errorf("Expected nominee to view the transfer, got %d", w.Code)
+	}
+
+	// 接受转让
+	if w := performJSON(router, http.MethodPost, transferPath+"/accept", "", authFor("fay")); w.Code != http.StatusForbidden {
+		t.Errorf("Expected other members to be denied accepting, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, transferPath+"/accept", "", authFor("eli")); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	database.DB.First(&room, room.ID)
+	if room.CreatorID != users["eli"].ID {
+		t.Fatalf("Expected eli to own the room, got creator %d", room.CreatorID)
+	}
+	for _, name := range []string{"dana", "eli"} {
+		var member models.RoomMember
+		database.DB.Where("room_id = ? AND user_id = ?", room.ID, users[name].ID).First(&member)
+		if member.Role != "admin" {
+			t.Errorf("Expected %s to be a room admin, got %q", name, member.Role)
+		}
+	}
+	if w := performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/leave", room.ID), "", authFor("dana")); w.Code != http.StatusOK {
+		t.Errorf("Expected former creator to leave, got %d", w.Code)
+	}
+
+	// 过期的转让不能接受，被指定的成员可以拒绝
+	if code := nominate("eli", "fay"); code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+	database.DB.Model(&models.RoomOwnershipTransfer{}).Where("room_id = ?", room.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	if w := performJSON(router, http.MethodPost, transferPath+"/accept", "", authFor("fay")); w.Code != http.StatusNotFound {
+		t.Errorf("Expected expired transfer to be rejected, got %d", w.Code)
+	}
+	if code := nominate("eli", "fay"); code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+	if w := performJSON(router, http.MethodDelete, transferPath, "", authFor("fay")); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodGet, transferPath, "", authFor("eli")); w.Code != http.StatusNotFound {
+		t.Errorf("Expected declined transfer to be removed, got %d", w.Code)
+	}
+
+	// 管理员为归档的无主房间指定创建者
+	orphan := models.Room{Name: "orphan", MaxMembers: 10, CreatorID: 9999}
+	database.DB.Create(&orphan)
+	database.DB.Model(&orphan).Update("archived_at", time.Now())
+	ownerPath := fmt.Sprintf("/admin/rooms/%d/owner", orphan.ID)
+	body := fmt.Sprintf(`{"user_id":%d}`, users["hal"].ID)
+	if w := performJSON(router, http.MethodPut, ownerPath, body, authFor("eli")); w.Code != http.StatusForbidden {
+		t.Errorf("Expected regular user to be denied, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPut, ownerPath, body, authFor("gus")); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var restored models.Room
+	database.DB.First(&restored, orphan.ID)
+	if restored.CreatorID != users["hal"].ID || restored.IsArchived() || !restored.IsAdmin(database.DB, users["hal"].ID) || !restored.IsMember(database.DB, users["hal"].ID) {
+		t.Errorf("Expected hal to own the restored room, got creator %d, archived %v", restored.CreatorID, restored.IsArchived())
+	}
+}
+
+func TestRoomOwnerMustBeActiveAccount(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	owner := createTestUser(t, "ivy", "password123")
+	admin := createTestUser(t, "jon", "password123")
+	placeholder := createTestUser(t, "ghost", "password123")
+	leaving := createTestUser(t, "kim", "password123")
+	database.DB.Model(admin).Update("role", string(rbac.RoleSiteAdmin))
+	database.DB.Model(placeholder).Update("is_placeholder", true)
+	database.DB.Model(leaving).Update("deletion_scheduled_at", time.Now().Add(24*time.Hour))
+	ownerToken, _ := auth.GenerateToken(owner.ID, owner.Username, owner.Email)
+	adminToken, _ := auth.GenerateToken(admin.ID, admin.Username, admin.Email)
+
+	room := models.Room{Name: "handover", MaxMembers: 10, CreatorID: owner.ID}
+	database.DB.Create(&room)
+	for _, user := range []*models.User{owner, placeholder, leaving} {
+		database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: user.ID, Role: "member", JoinedAt: time.Now()})
+	}
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/", middleware.AuthMiddleware())
+	api.POST("/rooms/:id/transfer", handlers.TransferRoom)
+	api.PUT("/admin/rooms/:id/owner", middleware.RequirePermission(rbac.PermRoomsManage), handlers.AssignRoomOwner(hub))
+
+	// 占位账号和等待注销的账号不能被指定为创建者
+	for _, target := range []*models.User{placeholder, leaving} {
+		body := fmt.Sprintf(`{"user_id":%d}`, target.ID)
+		if w := performJSON(router, http.MethodPost, fmt.Sprintf("/rooms/%d/transfer", room.ID), body, map[string]string{"Authorization": "Bearer " + ownerToken}); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected transfer to %s to be rejected, got %d", target.Username, w.Code)
+		}
+		if w := performJSON(router, http.MethodPut, fmt.Sprintf("/admin/rooms/%d/owner", room.ID), body, map[string]string{"Authorization": "Bearer " + adminToken}); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected assigning %s as owner to be rejected, got %d", target.Username, w.Code)
+		}
+	}
+
+	database.DB.First(&room, room.ID)
+	if room.CreatorID != owner.ID {
+		t.Errorf("Expected owner to be unchanged, got creator %d", room.CreatorID)
+	}
+}
+
+func TestRoomSuccessionOnAccountDeletion(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "ivy", "password123")
+	member := createTestUser(t, "jon", "password123")
+	admin := createTestUser(t, "kim", "password123")
+
+	room := models.Room{Name: "legacy", MaxMembers: 10, CreatorID: owner.ID}
+	database.DB.Create(&room)
+	joined := time.Now().Add(-time.Hour)
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: owner.ID, Role: "admin", JoinedAt: joined})
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: member.ID, Role: "member", JoinedAt: joined.Add(time.Minute)})
+	database.DB.Create(&models.RoomMember{RoomID: room.ID, UserID: admin.ID, Role: "admin", JoinedAt: joined.Add(2 * time.Minute)})
+	database.DB.Create(&models.RoomOwnershipTransfer{RoomID: room.ID, FromUserID: owner.ID, ToUserID: member.ID, ExpiresAt: time.Now().Add(time.Hour)})
+
+	if err := services.DeleteAccount(owner.ID); err != nil {
+		t.Fatalf("Failed to delete account: %v", err)
+	}
+
+	// 房间管理员优先于更早加入的普通成员
+	database.DB.First(&room, room.ID)
+	if room.CreatorID != admin.ID {
+		t.Errorf("Expected room to pass to the oldest admin, got creator %d", room.CreatorID)
+	}
+	var count int64
+	database.DB.Model(&models.RoomOwnershipTransfer{}).Where("room_id = ?", room.ID).Count(&count)
+	if count != 0 {
+		t.Error("Expected pending transfer to be cancelled")
+	}
+}
@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"encoding/json"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestSessionManagement(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	alice := createTestUser(t, "alice", "password123")
+	database.DB.Create(&models.Room{Name: "大厅", CreatorID: alice.ID})
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/login", handlers.Login)
+	router.POST("/auth/refresh", handlers.RefreshToken)
+	router.GET("/ws", handlers.HandleWebSocket(hub))
+	protected := router.Group("/", middleware.AuthMiddleware())
+	protected.GET("/sessions", handlers.GetSessions)
+	protected.DELETE("/sessions", handlers.RevokeOtherSessions(hub))
+	protected.DELETE("/sessions/:id", handlers.RevokeSession(hub))
+
+	login := func(userAgent string) handlers.AuthResponse {
+		w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123"}`, map[string]string{"User-Agent": userAgent})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp handlers.AuthResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	laptop := login("Laptop")
+	phone := login("Phone")
+	laptopAuth := map[string]string{"Authorization": "Bearer " + laptop.Token}
+	phoneAuth := map[string]string{"Authorization": "Bearer " + phone.Token}
+
+	w := performJSON(router, http.MethodGet, "/sessions", "", laptopAuth)
+	var list struct {
+		Sessions []struct {
+			ID        string `json:"id"`
+			UserAgent string `json:"user_agent"`
+			Current   bool   `json:"current"`
+		} `json:"sessions"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d: %s", len(list.Sessions), w.Body.String())
+	}
+	var laptopSessionID string
+	for _, session := range list.Sessions {
+		if session.Current {
+			laptopSessionID = session.ID
+			if session.UserAgent != "Laptop" {
+				t.Errorf("Expected current session to be the laptop, got %s", session.UserAgent)
+			}
+		}
+	}
+	if laptopSessionID == "" {
+		t.Fatal("Current session is not marked")
+	}
+
+	// 手机上打开 WebSocket 连接
+	server := httptest.NewServer(router)
+	defer server.Close()
+	conn, _, err := dialWebSocket(server, "", phone.Token)
+	if err != nil {
+		t.Fatalf("Failed to connect websocket: %v", err)
+	}
+	defer conn.Close()
+
+	// 在其他设备上退出登录
+	w = performJSON(router, http.MethodDelete, "/sessions", "", laptopAuth)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 被注销会话的 WebSocket 连接被断开
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+				t.Fatal("Expected websocket of revoked session to be closed")
+			}
+			break
+		}
+	}
+
+	if w := performJSON(router, http.MethodGet, "/sessions", "", phoneAuth); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked session token to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, "/auth/refresh", `{"refresh_token":"`+phone.RefreshToken+`"}`, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected refresh token of revoked session to be rejected, got %d", w.Code)
+	}
+
+	// 当前会话不受影响，刷新后的 token 仍属于同一会话
+	w = performJSON(router, http.MethodPost, "/auth/refresh", `{"refresh_token":"`+laptop.RefreshToken+`"}`, nil)
+	var refreshed handlers.AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &refreshed)
+	refreshedAuth := map[string]string{"Authorization": "Bearer " + refreshed.Token}
+	if w := performJSON(router, http.MethodGet, "/sessions", "", refreshedAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected current session to remain valid, got %d", w.Code)
+	}
+
+	// 注销当前会话后，旧 token 和刷新得到的 token 都失效
+	if w := performJSON(router, http.MethodDelete, "/sessions/"+laptopSessionID, "", refreshedAuth); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodGet, "/sessions", "", laptopAuth); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected original token to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodGet, "/sessions", "", refreshedAuth); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected refreshed token to be rejected, got %d", w.Code)
+	}
+}
+
+func TestLogoutClosesWebSocket(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	bruce := createTestUser(t, "bruce", "password123")
+	database.DB.Create(&models.Room{Name: "大厅", CreatorID: bruce.ID})
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/login", handlers.Login)
+	router.POST("/auth/logout", middleware.AuthMiddleware(), handlers.Logout(hub))
+	router.GET("/ws", handlers.HandleWebSocket(hub))
+
+	login := func() handlers.AuthResponse {
+		w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"bruce","password":"password123"}`, nil)
+		var resp handlers.AuthResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+	laptop := login()
+	phone := login()
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	dial := func(token string) *websocket.Conn {
+		conn, _, err := dialWebSocket(server, "", token)
+		if err != nil {
+			t.Fatalf("Failed to connect websocket: %v", err)
+		}
+		return conn
+	}
+	laptopConn := dial(laptop.Token)
+	defer laptopConn.Close()
+	phoneConn := dial(phone.Token)
+	defer phoneConn.Close()
+
+	if w := performJSON(router, http.MethodPost, "/auth/logout", "", map[string]string{"Authorization": "Bearer " + laptop.Token}); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 退出登录的会话连接被断开
+	laptopConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := laptopConn.ReadMessage(); err != nil {
+			if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+				t.Fatal("Expected websocket of logged out session to be closed")
+			}
+			break
+		}
+	}
+
+	// 其他会话的连接不受影响
+	phoneConn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	for {
+		if _, _, err := phoneConn.ReadMessage(); err != nil {
+			if netErr, ok := err.(interface{ Timeout() bool }); !ok || !netErr.Timeout() {
+				t.Fatalf("Expected websocket of other session to stay open: %v", err)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"gin-chat-room/config"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/mailer"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestConfig 初始化测试配置
+func setupTestConfig() {
+	config.AppConfig = &config.Config{
+		Server: config.ServerConfig{
+			Mode:    "test",
+			BaseURL: "http://localhost:8080",
+		},
+		JWT: config.JWTConfig{
+			Secret:            "test-secret",
+			ExpireTime:        24,
+			RefreshExpireTime: 720,
+		},
+		Auth: config.AuthConfig{
+			PasswordResetExpire:      30,
+			EmailVerificationExpire:  24,
+			LoginMaxFailures:         5,
+			LoginIPMaxFailures:       20,
+			LoginLockoutBase:         1,
+			LoginLockoutMax:          60,
+			RegisterIPLimit:          100,
+			PasswordMinLength:        8,
+			PasswordMaxLength:        64,
+			PasswordCheckSimilarity:  true,
+			AccountDeletionGraceDays: 14,
+		},
+		Room: config.RoomConfig{
+			RestoreDays:       30,
+			JoinRequestExpire: 72,
+		},
+	}
+}
+
+// testProxyAddr httptest 请求的来源地址
+const testProxyAddr = "192.0.2.1"
+
+// trustTestProxy 将测试请求的来源地址设为可信代理，以便通过 X-Forwarded-For 模拟不同的客户端 IP
+func trustTestProxy(t *testing.T, router *gin.Engine) {
+	if err := router.SetTrustedProxies([]string{testProxyAddr}); err != nil {
+		t.Fatalf("Failed to set trusted proxies: %v", err)
+	}
+}
+
+// startTestHub 启动 WebSocket Hub，测试结束时断开所有连接并等待 Hub 停止
+// 在 setupTestDB 之后调用，保证 Hub 停止前数据库仍然可用
+func startTestHub(t *testing.T) *services.Hub {
+	hub := services.NewHub()
+	go hub.Run()
+	t.Cleanup(hub.Stop)
+	return hub
+}
+
+// dialWebSocket 使用 Authorization 头连接测试服务器的 WebSocket，query 为包括问号的查询字符串
+func dialWebSocket(server *httptest.Server, query, token string) (*websocket.Conn, *http.Response, error) {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws" + query
+	return websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer " + token}})
+}
+
+// setupTestDB 初始化内存数据库并完成表迁移
+func setupTestDB(t *testing.T) {
+	setupTestConfig()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+
+	// 内存数据库在每个连接上都是独立的，限制为单连接
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("Failed to get sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	database.DB = db
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+}
+
+// createTestUser 创建测试用户
+func createTestUser(t *testing.T, username, password string) *models.User {
+	user := &models.User{
+		Username: username,
+		Email:    username + "@example.com",
+	}
+	if err := user.SetPassword(password); err != nil {
+		t.Fatalf("Failed to set password: %v", err)
+	}
+	if err := database.DB.Create(user).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return user
+}
+
+// performJSON 向路由发送 JSON 请求并返回响应
+func performJSON(router http.Handler, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// useMailFile 将邮件写入临时文件，返回文件路径
+func useMailFile(t *testing.T) string {
+	mailFile := filepath.Join(t.TempDir(), "mail.log")
+	mailer.DefaultMailer = &mailer.LogMailer{Path: mailFile}
+	t.Cleanup(func() {
+		mailer.DefaultMailer = &mailer.LogMailer{}
+	})
+	return mailFile
+}
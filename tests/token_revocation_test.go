@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"gin-chat-room/config"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestTokenHasUniqueID(t *testing.T) {
+	setupTestConfig()
+
+	first, err := auth.GenerateToken(1, "testuser", "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	second, err := auth.GenerateToken(1, "testuser", "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	firstClaims, _ := auth.ParseToken(first)
+	secondClaims, _ := auth.ParseToken(second)
+
+	if firstClaims.ID == "" {
+		t.Fatal("Token should contain a jti")
+	}
+
+	if firstClaims.ID == secondClaims.ID {
+		t.Error("Each token should have a unique jti")
+	}
+}
+
+func TestRevokeTokenWithoutRedis(t *testing.T) {
+	services.RedisClient = nil
+
+	jti := "test-jti-revoke"
+	if revoked, _ := services.IsTokenRevoked(jti); revoked {
+		t.Fatal("Token should not be revoked before logout")
+	}
+
+	if err := services.RevokeToken(jti, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to revoke token: %v", err)
+	}
+
+	if revoked, _ := services.IsTokenRevoked(jti); !revoked {
+		t.Error("Token should be revoked after logout")
+	}
+
+	// 已过期的 token 不需要进入黑名单
+	expiredJTI := "test-jti-expired"
+	services.RevokeToken(expiredJTI, time.Now().Add(-time.Minute))
+	if revoked, _ := services.IsTokenRevoked(expiredJTI); revoked {
+		t.Error("Expired token should not be stored in the denylist")
+	}
+}
+
+func TestClaimTokenWithoutRedis(t *testing.T) {
+	services.RedisClient = nil
+
+	// 并发认领同一个 jti 时只有一个请求成功
+	jti := "test-jti-claim"
+	var wg sync.WaitGroup
+	var claimed int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := services.ClaimToken(jti, time.Now().Add(time.Hour)); ok {
+				atomic.AddInt32(&claimed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if claimed != 1 {
+		t.Fatalf("Expected exactly one successful claim, got %d", claimed)
+	}
+	if revoked, _ := services.IsTokenRevoked(jti); !revoked {
+		t.Error("Claimed token should be revoked")
+	}
+
+	// 释放后可以重新认领
+	if err := services.ReleaseToken(jti); err != nil {
+		t.Fatalf("Failed to release token: %v", err)
+	}
+	if ok, _ := services.ClaimToken(jti, time.Now().Add(time.Hour)); !ok {
+		t.Error("Released token should be claimable again")
+	}
+
+	// 已吊销的 token 不能再认领
+	services.RevokeToken("test-jti-claim-revoked", time.Now().Add(time.Hour))
+	if ok, _ := services.ClaimToken("test-jti-claim-revoked", time.Now().Add(time.Hour)); ok {
+		t.Error("Revoked token should not be claimable")
+	}
+}
+
+func TestLegacyTokenWithoutID(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+	user := createTestUser(t, "legacy", "password123")
+
+	// 旧版本签发的 token 没有 jti
+	sign := func(expiresAt *jwt.NumericDate) string {
+		claims := &auth.Claims{
+			UserID:   user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: expiresAt,
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				Issuer:    "gin-chat-room",
+				Subject:   user.Username,
+			},
+		}
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.AppConfig.JWT.Secret))
+		return token
+	}
+	legacy := sign(jwt.NewNumericDate(time.Now().Add(time.Hour)))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/profile", middleware.AuthMiddleware(), handlers.GetProfile)
+	router.POST("/auth/logout", middleware.AuthMiddleware(), handlers.Logout(services.NewHub()))
+	headers := map[string]string{"Authorization": "Bearer " + legacy}
+
+	if w := performJSON(router, http.MethodGet, "/profile", "", headers); w.Code != http.StatusOK {
+		t.Fatalf("Expected legacy token to be accepted until it expires, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, _, authErr := middleware.Authenticate(sign(nil)); authErr == nil {
+		t.Error("Expected token without jti or expiry to be rejected")
+	}
+
+	// 退出登录后旧 token 同样失效
+	if w := performJSON(router, http.MethodPost, "/auth/logout", "", headers); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := performJSON(router, http.MethodGet, "/profile", "", headers); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected logged out legacy token to be rejected, got %d", w.Code)
+	}
+}
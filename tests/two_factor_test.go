@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"encoding/json"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 测试向量（SHA1），secret 为 "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	code, err := auth.TOTPCode(secret, auth.TOTPStep(time.Unix(59, 0)))
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+	if code != "287082" {
+		t.Errorf("Expected 287082, got %s", code)
+	}
+
+	if _, ok := auth.ValidateTOTP(secret, "287082", time.Unix(59+30, 0)); !ok {
+		t.Error("Code from the previous step should be accepted")
+	}
+	if _, ok := auth.ValidateTOTP(secret, "287082", time.Unix(59+90, 0)); ok {
+		t.Error("Code outside the allowed skew should be rejected")
+	}
+}
+
+func TestTwoFactorLoginFlow(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	user := createTestUser(t, "alice", "password123")
+	token, _ := auth.GenerateToken(user.ID, user.Username, user.Email)
+	authHeader := map[string]string{"Authorization": "Bearer " + token}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/login", handlers.Login)
+	router.POST("/auth/2fa/verify", handlers.VerifyTwoFactorLogin)
+	protected := router.Group("/", middleware.AuthMiddleware())
+	protected.POST("/2fa/enroll", handlers.EnrollTwoFactor)
+	protected.POST("/2fa/confirm", handlers.ConfirmTwoFactor)
+	protected.POST("/2fa/disable", handlers.DisableTwoFactor)
+
+	// 启用前需要确认密码
+	if w := performJSON(router, http.MethodPost, "/2fa/enroll", `{"password":"wrong"}`, authHeader); w.Code != http.StatusForbidden {
+		t.Errorf("Expected wrong password to be rejected, got %d", w.Code)
+	}
+	w := performJSON(router, http.MethodPost, "/2fa/enroll", `{"password":"password123"}`, authHeader)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var enroll struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &enroll)
+	if enroll.Secret == "" || enroll.OTPAuthURI == "" {
+		t.Fatalf("Enrollment response is incomplete: %s", w.Body.String())
+	}
+
+	// 数据库中的密钥是加密的
+	var enrolled models.User
+	database.DB.First(&enrolled, user.ID)
+	if enrolled.TOTPSecret == enroll.Secret || strings.Contains(enrolled.TOTPSecret, enroll.Secret) {
+		t.Errorf("Expected two-factor secret to be encrypted at rest, got %q", enrolled.TOTPSecret)
+	}
+
+	// 确认之前登录不需要第二步
+	w = performJSON(router, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var direct handlers.AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &direct)
+	if direct.Token == "" {
+		t.Fatal("Login before confirmation should return a token")
+	}
+
+	code, _ := auth.TOTPCode(enroll.Secret, auth.TOTPStep(time.Now()))
+	w = performJSON(router, http.MethodPost, "/2fa/confirm", `{"code":"`+code+`"}`, authHeader)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var confirm struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &confirm)
+	if len(confirm.RecoveryCodes) != 10 {
+		t.Fatalf("Expected 10 recovery codes, got %d", len(confirm.RecoveryCodes))
+	}
+
+	login := func() string {
+		w := performJSON(router, http.MethodPost, "/auth/login", `{"username":"alice","password":"password123"}`, nil)
+		var resp struct {
+			Token             string `json:"token"`
+			TwoFactorRequired bool   `json:"two_factor_required"`
+			ChallengeToken    string `json:"challenge_token"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != http.StatusOK || !resp.TwoFactorRequired || resp.Token != "" {
+			t.Fatalf("Expected a two-factor challenge, got %d: %s", w.Code, w.Body.String())
+		}
+		return resp.ChallengeToken
+	}
+
+	// 挑战令牌不能作为访问令牌使用
+	challenge := login()
+	if _, err := auth.ParseToken(challenge); err == nil {
+		t.Error("Challenge token should not be accepted as an access token")
+	}
+
+	// 确认时使用的验证码不能重放
+	w = performJSON(router, http.MethodPost, "/auth/2fa/verify", `{"challenge_token":"`+challenge+`","code":"`+code+`"}`, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected replayed code to be rejected, got %d", w.Code)
+	}
+
+	// 使用恢复码登录
+	w = performJSON(router, http.MethodPost, "/auth/2fa/verify", `{"challenge_token":"`+challenge+`","code":"`+confirm.RecoveryCodes[0]+`"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp handlers.AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Token == "" || resp.RefreshToken == "" {
+		t.Error("Expected tokens after second factor")
+	}
+
+	// 挑战令牌只能成功使用一次
+	w = performJSON(router, http.MethodPost, "/auth/2fa/verify", `{"challenge_token":"`+challenge+`","code":"`+confirm.RecoveryCodes[1]+`"}`, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected used challenge to be rejected, got %d", w.Code)
+	}
+
+	// 恢复码只能使用一次
+	challenge = login()
+	w = performJSON(router, http.MethodPost, "/auth/2fa/verify", `{"challenge_token":"`+challenge+`","code":"`+confirm.RecoveryCodes[0]+`"}`, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected used recovery code to be rejected, got %d", w.Code)
+	}
+
+	var stored models.User
+	database.DB.First(&stored, user.ID)
+	if !stored.TOTPEnabled {
+		t.Error("Two-factor authentication should be enabled")
+	}
+
+	// 关闭时需要密码和验证码
+	if w := performJSON(router, http.MethodPost, "/2fa/disable", `{"password":"wrong","code":"`+confirm.RecoveryCodes[2]+`"}`, authHeader); w.Code != http.StatusForbidden {
+		t.Errorf("Expected wrong password to be rejected, got %d", w.Code)
+	}
+	if w := performJSON(router, http.MethodPost, "/2fa/disable", `{"password":"password123","code":"`+confirm.RecoveryCodes[2]+`"}`, authHeader); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	database.DB.First(&stored, user.ID)
+	if stored.TOTPEnabled || stored.TOTPSecret != "" {
+		t.Error("Two-factor authentication should be disabled")
+	}
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-chat-room/internal/auth"
+	"gin-chat-room/internal/database"
+	"gin-chat-room/internal/handlers"
+	"gin-chat-room/internal/middleware"
+	"gin-chat-room/internal/models"
+	"gin-chat-room/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketTicketIsSingleUse(t *testing.T) {
+	services.RedisClient = nil
+
+	ticket, err := services.IssueWebSocketTicket("access-token")
+	if err != nil {
+		t.Fatalf("Failed to issue ticket: %v", err)
+	}
+
+	accessToken, err := services.RedeemWebSocketTicket(ticket)
+	if err != nil {
+		t.Fatalf("Failed to redeem ticket: %v", err)
+	}
+
+	if accessToken != "access-token" {
+		t.Errorf("Expected access token to be returned, got %s", accessToken)
+	}
+
+	// 票据只能使用一次
+	if _, err := services.RedeemWebSocketTicket(ticket); err != services.ErrInvalidWebSocketTicket {
+		t.Errorf("Expected ErrInvalidWebSocketTicket on reuse, got %v", err)
+	}
+
+	if _, err := services.RedeemWebSocketTicket("unknown"); err != services.ErrInvalidWebSocketTicket {
+		t.Errorf("Expected ErrInvalidWebSocketTicket for unknown ticket, got %v", err)
+	}
+}
+
+func TestWebSocketHandshakeCredentials(t *testing.T) {
+	setupTestDB(t)
+	services.RedisClient = nil
+
+	user := createTestUser(t, "wendy", "password123")
+	token, _ := auth.GenerateToken(user.ID, user.Username, user.Email)
+	room := models.Room{Name: "大厅", CreatorID: user.ID}
+	database.DB.Create(&room)
+
+	hub := startTestHub(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", handlers.HandleWebSocket(hub))
+	router.POST("/ws/ticket", middleware.AuthMiddleware(), handlers.CreateWebSocketTicket)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	wsURL := fmt.Sprintf("ws%s/ws?room_id=%d", strings.TrimPrefix(server.URL, "http"), room.ID)
+
+	// 票据只能用于一次握手
+	w := performJSON(router, http.MethodPost, "/ws/ticket", "", map[string]string{"Authorization": "Bearer " + token})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var ticketResp struct {
+		Ticket string `json:"ticket"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &ticketResp)
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"&ticket="+ticketResp.Ticket, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect with ticket: %v", err)
+	}
+	conn.Close()
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL+"&ticket="+ticketResp.Ticket, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected reused ticket to be rejected with 401, got %v", resp)
+	}
+
+	// 通过子协议传递 token，握手响应回传选中的子协议
+	dialer := websocket.Dialer{Subprotocols: []string{"access_token", token}}
+	conn, resp, err := dialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect with subprotocol token: %v", err)
+	}
+	conn.Close()
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "access_token" || conn.Subprotocol() != "access_token" {
+		t.Errorf("Expected access_token subprotocol to be echoed, got %q", got)
+	}
+
+	// 不接受 token 查询参数
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL+"&token="+token, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected token query parameter to be rejected with 401, got %v", resp)
+	}
+}
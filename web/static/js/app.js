@@ -2,6 +2,8 @@
 class ChatApp {
   constructor() {
     this.token = localStorage.getItem('token')
+    this.refreshToken = localStorage.getItem('refresh_token')
+    this.refreshPromise = null
     this.user = JSON.parse(localStorage.getItem('user') || 'null')
     this.currentRoom = null
     this.ws = null
@@ -13,6 +15,12 @@ class ChatApp {
 
   init() {
     this.bindEvents()
+    this.loadLoginProviders()
+
+    // 外部登录回调跳转回来时，使用登录码完成登录
+    if (this.handleOIDCRedirect()) {
+      return
+    }
 
     // 检查是否已登录
     if (this.token && this.user) {
@@ -140,15 +148,7 @@ class ChatApp {
       const data = await response.json()
 
       if (response.ok) {
-        this.token = data.token
-        this.user = data.user
-
-        localStorage.setItem('token', this.token)
-        localStorage.setItem('user', JSON.stringify(this.user))
-
-        this.showToast('登录成功', 'success')
-        this.showRoomsPage()
-        this.loadRooms()
+        await this.finishLogin(data)
       } else {
         this.showToast(data.error || '登录失败', 'error')
       }
@@ -171,11 +171,6 @@ class ChatApp {
       return
     }
 
-    if (password.length < 6) {
-      this.showToast('密码至少6位', 'error')
-      return
-    }
-
     this.showLoading()
 
     try {
@@ -190,17 +185,17 @@ class ChatApp {
       const data = await response.json()
 
       if (response.ok) {
-        this.token = data.token
-        this.user = data.user
-
-        localStorage.setItem('token', this.token)
-        localStorage.setItem('user', JSON.stringify(this.user))
+        this.saveSession(data)
 
         this.showToast('注册成功', 'success')
         this.showRoomsPage()
         this.loadRooms()
       } else {
-        this.showToast(data.error || '注册失败', 'error')
+        // 密码不符合策略时一次显示所有未通过的规则
+        const message = data.violations
+          ? data.violations.map((v) => v.message).join('; ')
+          : data.error
+        this.showToast(message || '注册失败', 'error')
       }
     } catch (error) {
       console.error('Register error:', error)
@@ -210,32 +205,207 @@ class ChatApp {
     }
   }
 
+  // 完成登录，开启两步验证的账号需要再输入验证码
+  async finishLogin(data) {
+    if (data.two_factor_required) {
+      const code = window.prompt('请输入验证器中的 6 位验证码或恢复码')
+      if (!code) {
+        this.showToast('已取消登录', 'error')
+        return
+      }
+
+      const response = await fetch('/api/v1/auth/2fa/verify', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ challenge_token: data.challenge_token, code: code.trim() }),
+      })
+      data = await response.json()
+
+      if (!response.ok) {
+        this.showToast(data.error || '验证码错误', 'error')
+        return
+      }
+    }
+
+    this.saveSession(data)
+
+    this.showToast('登录成功', 'success')
+    this.showRoomsPage()
+    this.loadRooms()
+  }
+
+  // 加载外部登录提供方，显示对应的登录按钮
+  async loadLoginProviders() {
+    try {
+      const response = await fetch('/api/v1/auth/oidc/providers')
+      const data = await response.json()
+      if (!response.ok) {
+        return
+      }
+
+      const container = document.getElementById('oidc-providers')
+      container.innerHTML = ''
+      data.providers.forEach((provider) => {
+        const link = document.createElement('a')
+        link.className = 'btn btn-secondary'
+        link.href = provider.login_url
+        link.textContent = `使用 ${provider.display_name} 登录`
+        container.appendChild(link)
+      })
+    } catch (error) {
+      console.error('Load login providers error:', error)
+    }
+  }
+
+  // 处理外部登录回调跳转，返回是否正在处理外部登录
+  handleOIDCRedirect() {
+    const params = new URLSearchParams(window.location.search)
+    const loginCode = params.get('oidc_login')
+    const loginError = params.get('oidc_error')
+    if (!loginCode && !loginError) {
+      return false
+    }
+
+    // 登录码只能使用一次，从地址栏中移除
+    window.history.replaceState({}, document.title, window.location.pathname)
+    this.showLoginPage()
+
+    if (loginError) {
+      this.showToast(loginError, 'error')
+      return true
+    }
+
+    this.showLoading()
+    fetch('/api/v1/auth/oidc/token', {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ code: loginCode }),
+    })
+      .then(async (response) => {
+        const data = await response.json()
+        if (response.ok) {
+          await this.finishLogin(data)
+        } else {
+          this.showToast(data.error || '登录失败', 'error')
+        }
+      })
+      .catch((error) => {
+        console.error('OIDC login error:', error)
+        this.showToast('网络错误，请重试', 'error')
+      })
+      .finally(() => this.hideLoading())
+
+    return true
+  }
+
   handleLogout() {
     if (this.ws) {
       this.ws.close()
       this.ws = null
     }
 
+    // 通知服务器吊销当前令牌，失败时不影响本地退出
+    fetch('/api/v1/auth/logout', {
+      method: 'POST',
+      headers: {
+        Authorization: `Bearer ${this.token}`,
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ refresh_token: this.refreshToken }),
+    }).catch((error) => console.error('Logout error:', error))
+
+    this.clearSession()
+
+    this.showLoginPage()
+    this.showToast('已退出登录', 'info')
+  }
+
+  // 会话相关方法
+  saveSession(data) {
+    this.token = data.token
+    this.refreshToken = data.refresh_token
+    this.user = data.user
+
+    localStorage.setItem('token', this.token)
+    localStorage.setItem('refresh_token', this.refreshToken)
+    localStorage.setItem('user', JSON.stringify(this.user))
+  }
+
+  clearSession() {
     this.token = null
+    this.refreshToken = null
     this.user = null
     this.currentRoom = null
 
     localStorage.removeItem('token')
+    localStorage.removeItem('refresh_token')
     localStorage.removeItem('user')
+  }
 
-    this.showLoginPage()
-    this.showToast('已退出登录', 'info')
+  async refreshAccessToken() {
+    if (!this.refreshToken) {
+      return false
+    }
+
+    // 合并并发的刷新请求，避免同一个刷新令牌被使用两次
+    if (!this.refreshPromise) {
+      this.refreshPromise = fetch('/api/v1/auth/refresh', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ refresh_token: this.refreshToken }),
+      })
+        .then(async (response) => {
+          if (!response.ok) {
+            return false
+          }
+          this.saveSession(await response.json())
+          return true
+        })
+        .catch(() => false)
+        .finally(() => {
+          this.refreshPromise = null
+        })
+    }
+
+    return this.refreshPromise
   }
 
-  // 房间相关方法
-  async loadRooms() {
-    try {
-      const response = await fetch('/api/v1/rooms', {
+  async authFetch(url, options = {}) {
+    const request = () =>
+      fetch(url, {
+        ...options,
         headers: {
+          ...(options.headers || {}),
           Authorization: `Bearer ${this.token}`,
         },
       })
 
+    let response = await request()
+
+    // 访问令牌过期时使用刷新令牌重试一次
+    if (response.status === 401) {
+      if (await this.refreshAccessToken()) {
+        response = await request()
+      } else {
+        this.clearSession()
+        this.showLoginPage()
+      }
+    }
+
+    return response
+  }
+
+  // 房间相关方法
+  async loadRooms() {
+    try {
+      const response = await this.authFetch('/api/v1/rooms')
+
       const data = await response.json()
 
       if (response.ok) {
@@ -301,10 +471,9 @@ class ChatApp {
 
     try {
       // 先尝试加入房间
-      const response = await fetch(`/api/v1/rooms/${roomId}/join`, {
+      const response = await this.authFetch(`/api/v1/rooms/${roomId}/join`, {
         method: 'POST',
         headers: {
-          Authorization: `Bearer ${this.token}`,
           'Content-Type': 'application/json',
         },
         body: JSON.stringify({}),
@@ -313,11 +482,7 @@ class ChatApp {
       if (response.ok || response.status === 409) {
         // 409 表示已经是成员
         // 获取房间信息
-        const roomResponse = await fetch(`/api/v1/rooms/${roomId}`, {
-          headers: {
-            Authorization: `Bearer ${this.token}`,
-          },
-        })
+        const roomResponse = await this.authFetch(`/api/v1/rooms/${roomId}`)
 
         const roomData = await roomResponse.json()
 
@@ -358,10 +523,9 @@ class ChatApp {
     this.showLoading()
 
     try {
-      const response = await fetch('/api/v1/rooms', {
+      const response = await this.authFetch('/api/v1/rooms', {
         method: 'POST',
         headers: {
-          Authorization: `Bearer ${this.token}`,
           'Content-Type': 'application/json',
         },
         body: JSON.stringify({
@@ -404,13 +568,32 @@ class ChatApp {
   }
 
   // WebSocket 相关方法
-  connectWebSocket() {
+  async connectWebSocket() {
     if (this.ws) {
       this.ws.close()
     }
 
+    // 浏览器无法在握手时设置 Authorization 头，先换取一次性连接票据
+    let ticket
+    try {
+      const response = await this.authFetch('/api/v1/ws/ticket', { method: 'POST' })
+      const data = await response.json()
+      if (!response.ok) {
+        this.showToast(data.error || '连接聊天服务失败', 'error')
+        return
+      }
+      ticket = data.ticket
+    } catch (error) {
+      console.error('WebSocket ticket error:', error)
+      return
+    }
+
+    if (!this.currentRoom) {
+      return
+    }
+
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
-    const wsUrl = `${protocol}//${window.location.host}/api/v1/ws?room_id=${this.currentRoom.id}`
+    const wsUrl = `${protocol}//${window.location.host}/api/v1/ws?room_id=${this.currentRoom.id}&ticket=${encodeURIComponent(ticket)}`
 
     this.ws = new WebSocket(wsUrl)
     this.ws.onopen = () => {
@@ -451,6 +634,9 @@ class ChatApp {
       case 'online_users':
         this.updateOnlineUsers(message.data.users)
         break
+      case 'error':
+        this.showToast(message.data.error, 'error')
+        break
     }
   }
 
@@ -474,11 +660,7 @@ class ChatApp {
 
   async loadMessages() {
     try {
-      const response = await fetch(`/api/v1/rooms/${this.currentRoom.id}/messages`, {
-        headers: {
-          Authorization: `Bearer ${this.token}`,
-        },
-      })
+      const response = await this.authFetch(`/api/v1/rooms/${this.currentRoom.id}/messages`)
 
       const data = await response.json()
 